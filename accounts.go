@@ -0,0 +1,154 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lucoand/chirpy/internal/database"
+)
+
+const (
+	accountActive       = "active"
+	accountSuspended    = "suspended"
+	accountBanned       = "banned"
+	accountShadowbanned = "shadowbanned"
+)
+
+const auditAccountStatus = "admin.account_status_changed"
+
+// accountRestriction explains why a user may not use their account, or
+// returns an empty string if they may. Shadowbanned users are deliberately
+// not restricted: they should not be able to tell.
+func accountRestriction(user database.User) string {
+	switch user.AccountStatus {
+	case accountBanned:
+		return "Account banned"
+	case accountSuspended:
+		if user.SuspendedUntil.Valid && user.SuspendedUntil.Time.After(time.Now()) {
+			return fmt.Sprintf("Account suspended until %s", user.SuspendedUntil.Time.Format(time.RFC3339))
+		}
+	}
+	return ""
+}
+
+// viewer returns the ID of the user making the request, if any. A request
+// without an Authorization header is anonymous; one with a bad token is an
+// error.
+func (cfg *apiConfig) viewer(r *http.Request) (uuid.NullUUID, error) {
+	if r.Header.Get("Authorization") == "" {
+		return uuid.NullUUID{}, nil
+	}
+	user, err := cfg.authenticate(r)
+	if err != nil {
+		return uuid.NullUUID{}, err
+	}
+	return actor(user.ID), nil
+}
+
+func (cfg *apiConfig) handleSetAccountStatus(w http.ResponseWriter, r *http.Request, admin database.User) {
+	type parameters struct {
+		Status string     `json:"status"`
+		Reason string     `json:"reason"`
+		Until  *time.Time `json:"until"`
+	}
+	userID, err := uuid.Parse(r.PathValue("user_id"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid user_id")
+		return
+	}
+	if userID == admin.ID {
+		respondWithError(w, 400, "Admins cannot change their own account status")
+		return
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, "Invalid JSON")
+		return
+	}
+	params.Reason = strings.TrimSpace(params.Reason)
+	if params.Reason == "" {
+		respondWithError(w, 400, "A reason is required")
+		return
+	}
+	query := database.SetUserAccountStatusParams{
+		ID:            userID,
+		AccountStatus: params.Status,
+		StatusReason:  sql.NullString{String: params.Reason, Valid: true},
+	}
+	switch params.Status {
+	case accountSuspended:
+		if params.Until == nil || !params.Until.After(time.Now()) {
+			respondWithError(w, 400, "Suspensions need an until time in the future")
+			return
+		}
+		query.SuspendedUntil = sql.NullTime{Time: params.Until.UTC(), Valid: true}
+	case accountActive, accountBanned, accountShadowbanned:
+	default:
+		respondWithError(w, 400, "status must be one of active, suspended, banned or shadowbanned")
+		return
+	}
+
+	oldUser, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		return
+	} else if err != nil {
+		log.Printf("Error retrieving user from database: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	user, err := cfg.dbQueries.SetUserAccountStatus(r.Context(), query)
+	if err != nil {
+		log.Printf("Error setting account status for user %v: %s", userID, err)
+		w.WriteHeader(500)
+		return
+	}
+	metadata := map[string]interface{}{
+		"previous_status": oldUser.AccountStatus,
+		"status":          user.AccountStatus,
+		"reason":          params.Reason,
+	}
+	if params.Until != nil {
+		metadata["until"] = params.Until
+	}
+	cfg.recordAudit(r, auditEntry{
+		Action:     auditAccountStatus,
+		ActorID:    actor(admin.ID),
+		TargetType: "user",
+		TargetID:   user.ID.String(),
+		Metadata:   metadata,
+	})
+
+	type response struct {
+		userJSON
+		AccountStatus  string     `json:"account_status"`
+		StatusReason   *string    `json:"status_reason"`
+		SuspendedUntil *time.Time `json:"suspended_until"`
+	}
+	ent, err := cfg.entitlements(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error checking subscription for user %v: %s", user.ID, err)
+		w.WriteHeader(500)
+		return
+	}
+	resp := response{
+		userJSON:      userToJSON(user, ent),
+		AccountStatus: user.AccountStatus,
+	}
+	if user.StatusReason.Valid {
+		resp.StatusReason = &user.StatusReason.String
+	}
+	if user.SuspendedUntil.Valid {
+		resp.SuspendedUntil = &user.SuspendedUntil.Time
+	}
+	respondWithJSON(w, 200, resp)
+}
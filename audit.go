@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lucoand/chirpy/internal/database"
+)
+
+const (
+	auditLogin          = "login"
+	auditLoginFailed    = "login.failed"
+	auditTokenRefresh   = "token.refresh"
+	auditTokenRevoke    = "token.revoke"
+	auditPasswordChange = "user.password_changed"
+	auditEmailChange    = "user.email_changed"
+	auditChirpDelete    = "chirp.deleted"
+	auditAdminReset     = "admin.reset"
+	auditRoleGrant      = "admin.role_granted"
+	auditRoleRevoke     = "admin.role_revoked"
+)
+
+type contextKey string
+
+const requestIDKey contextKey = "request_id"
+
+// middlewareRequestID tags every request with an ID, reusing the caller's
+// X-Request-ID if it looks sane, and echoes it back in the response.
+func middlewareRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if requestID == "" || len(requestID) > 128 {
+			requestID = uuid.NewString()
+		}
+		w.Header().Set("X-Request-ID", requestID)
+		ctx := context.WithValue(r.Context(), requestIDKey, requestID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey).(string)
+	return id
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+type auditEntry struct {
+	Action     string
+	ActorID    uuid.NullUUID
+	TargetType string
+	TargetID   string
+	Metadata   map[string]interface{}
+}
+
+func actor(id uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{UUID: id, Valid: true}
+}
+
+// recordAudit appends an entry to the audit log. Failing to write the entry
+// is logged but never fails the request that triggered it.
+func (cfg *apiConfig) recordAudit(r *http.Request, e auditEntry) {
+	cfg.writeAuditEvent(r.Context(), clientIP(r), requestID(r), e)
+}
+
+// recordSystemAudit is recordAudit for actions taken by background jobs,
+// which have no client IP or request ID.
+func (cfg *apiConfig) recordSystemAudit(ctx context.Context, e auditEntry) {
+	cfg.writeAuditEvent(ctx, "", "", e)
+}
+
+func (cfg *apiConfig) writeAuditEvent(ctx context.Context, ip string, reqID string, e auditEntry) {
+	if e.Metadata == nil {
+		e.Metadata = map[string]interface{}{}
+	}
+	metadata, err := json.Marshal(e.Metadata)
+	if err != nil {
+		log.Printf("Error marshaling audit metadata: %s", err)
+		return
+	}
+	query := database.CreateAuditEventParams{
+		Action:     e.Action,
+		ActorID:    e.ActorID,
+		TargetType: sql.NullString{String: e.TargetType, Valid: e.TargetType != ""},
+		TargetID:   sql.NullString{String: e.TargetID, Valid: e.TargetID != ""},
+		Ip:         ip,
+		RequestID:  reqID,
+		Metadata:   metadata,
+	}
+	err = cfg.dbQueries.CreateAuditEvent(ctx, query)
+	if err != nil {
+		log.Printf("Error recording audit event %s: %s", e.Action, err)
+	}
+}
+
+type auditEventJSON struct {
+	ID         uuid.UUID       `json:"id"`
+	CreatedAt  time.Time       `json:"created_at"`
+	Action     string          `json:"action"`
+	ActorID    *uuid.UUID      `json:"actor_id"`
+	TargetType *string         `json:"target_type"`
+	TargetID   *string         `json:"target_id"`
+	IP         string          `json:"ip"`
+	RequestID  string          `json:"request_id"`
+	Metadata   json.RawMessage `json:"metadata"`
+}
+
+func auditEventToJSON(e database.AuditEvent) auditEventJSON {
+	j := auditEventJSON{
+		ID:        e.ID,
+		CreatedAt: e.CreatedAt,
+		Action:    e.Action,
+		IP:        e.Ip,
+		RequestID: e.RequestID,
+		Metadata:  e.Metadata,
+	}
+	if e.ActorID.Valid {
+		j.ActorID = &e.ActorID.UUID
+	}
+	if e.TargetType.Valid {
+		j.TargetType = &e.TargetType.String
+	}
+	if e.TargetID.Valid {
+		j.TargetID = &e.TargetID.String
+	}
+	return j
+}
+
+func (cfg *apiConfig) handleListAuditEvents(w http.ResponseWriter, r *http.Request, admin database.User) {
+	q := r.URL.Query()
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	query := database.ListAuditEventsParams{
+		Action:     sql.NullString{String: q.Get("action"), Valid: q.Get("action") != ""},
+		TargetType: sql.NullString{String: q.Get("target_type"), Valid: q.Get("target_type") != ""},
+		TargetID:   sql.NullString{String: q.Get("target_id"), Valid: q.Get("target_id") != ""},
+		Limit:      limit,
+		Offset:     offset,
+	}
+	if s := q.Get("actor_id"); s != "" {
+		actorID, err := uuid.Parse(s)
+		if err != nil {
+			respondWithError(w, 400, "Invalid actor_id")
+			return
+		}
+		query.ActorID = actor(actorID)
+	}
+	if s := q.Get("since"); s != "" {
+		since, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			respondWithError(w, 400, "since must be an RFC 3339 timestamp")
+			return
+		}
+		query.Since = sql.NullTime{Time: since.UTC(), Valid: true}
+	}
+	if s := q.Get("until"); s != "" {
+		until, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			respondWithError(w, 400, "until must be an RFC 3339 timestamp")
+			return
+		}
+		query.Until = sql.NullTime{Time: until.UTC(), Valid: true}
+	}
+	events, err := cfg.dbQueries.ListAuditEvents(r.Context(), query)
+	if err != nil {
+		log.Printf("Error retrieving audit events: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	resp := make([]auditEventJSON, len(events))
+	for i, e := range events {
+		resp[i] = auditEventToJSON(e)
+	}
+	respondWithJSON(w, 200, resp)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lucoand/chirpy/internal/database"
+)
+
+type blockJSON struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (cfg *apiConfig) handleListBlocks(w http.ResponseWriter, r *http.Request, user database.User) {
+	blocks, err := cfg.dbQueries.GetUserBlocks(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error retrieving blocks: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	resp := make([]blockJSON, len(blocks))
+	for i, block := range blocks {
+		resp[i] = blockJSON{
+			UserID:    block.BlockedID,
+			CreatedAt: block.CreatedAt,
+		}
+	}
+	respondWithJSON(w, 200, resp)
+}
+
+func (cfg *apiConfig) handleCreateBlock(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		UserID uuid.UUID `json:"user_id"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, "Invalid JSON")
+		return
+	}
+	if params.UserID == user.ID {
+		respondWithError(w, 400, "You cannot block yourself")
+		return
+	}
+	_, err = cfg.dbQueries.GetUserByID(r.Context(), params.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		return
+	} else if err != nil {
+		log.Printf("Error retrieving user from database: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	// Blocking someone ends any follow between the two of you.
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+	query := database.CreateUserBlockParams{
+		BlockerID: user.ID,
+		BlockedID: params.UserID,
+	}
+	err = qtx.CreateUserBlock(r.Context(), query)
+	if err != nil {
+		log.Printf("Error creating block: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	err = qtx.DeleteFollowsBetween(r.Context(), database.DeleteFollowsBetweenParams{
+		FollowerID: user.ID,
+		FolloweeID: params.UserID,
+	})
+	if err != nil {
+		log.Printf("Error deleting follows: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("Error committing block: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handleDeleteBlock(w http.ResponseWriter, r *http.Request, user database.User) {
+	blockedID, err := uuid.Parse(r.PathValue("user_id"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid user_id")
+		return
+	}
+	query := database.DeleteUserBlockParams{
+		BlockerID: user.ID,
+		BlockedID: blockedID,
+	}
+	deleted, err := cfg.dbQueries.DeleteUserBlock(r.Context(), query)
+	if err != nil {
+		log.Printf("Error deleting block: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	if deleted == 0 {
+		w.WriteHeader(404)
+		return
+	}
+	w.WriteHeader(204)
+}
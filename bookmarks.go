@@ -0,0 +1,102 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/lucoand/chirpy/internal/database"
+)
+
+// visibleChirp looks up the chirp named in the path as the user would see
+// it, or responds with an error.
+func (cfg *apiConfig) visibleChirp(w http.ResponseWriter, r *http.Request, user database.User) (database.Chirp, bool) {
+	chirpID, err := uuid.Parse(r.PathValue("chirp_id"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid chirp ID")
+		return database.Chirp{}, false
+	}
+	chirp, err := cfg.dbQueries.GetChirp(r.Context(), database.GetChirpParams{
+		ID:       chirpID,
+		ViewerID: actor(user.ID),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Chirp not found")
+		return database.Chirp{}, false
+	} else if err != nil {
+		log.Printf("Error retrieving chirp: %s", err)
+		w.WriteHeader(500)
+		return database.Chirp{}, false
+	}
+	return chirp, true
+}
+
+// handleCreateBookmark bookmarks a chirp. Bookmarks are private, and
+// bookmarking a chirp twice is the same as bookmarking it once.
+func (cfg *apiConfig) handleCreateBookmark(w http.ResponseWriter, r *http.Request, user database.User) {
+	chirp, ok := cfg.visibleChirp(w, r, user)
+	if !ok {
+		return
+	}
+	err := cfg.dbQueries.CreateBookmark(r.Context(), database.CreateBookmarkParams{
+		UserID:  user.ID,
+		ChirpID: chirp.ID,
+	})
+	if err != nil {
+		log.Printf("Error creating bookmark: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handleDeleteBookmark(w http.ResponseWriter, r *http.Request, user database.User) {
+	chirpID, err := uuid.Parse(r.PathValue("chirp_id"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid chirp ID")
+		return
+	}
+	err = cfg.dbQueries.DeleteBookmark(r.Context(), database.DeleteBookmarkParams{
+		UserID:  user.ID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		log.Printf("Error deleting bookmark: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// handleListBookmarks lists the user's bookmarked chirps, most recently
+// bookmarked first. Chirps that have since been hidden are left out.
+func (cfg *apiConfig) handleListBookmarks(w http.ResponseWriter, r *http.Request, user database.User) {
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	chirps, err := cfg.dbQueries.GetBookmarkedChirps(r.Context(), database.GetBookmarkedChirpsParams{
+		UserID: user.ID,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		log.Printf("Error retrieving bookmarks: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	resp := make([]chirpJSON, len(chirps))
+	for i, chirp := range chirps {
+		resp[i] = chirpToJSON(chirp)
+	}
+	err = cfg.withAttachments(r.Context(), actor(user.ID), resp)
+	if err != nil {
+		log.Printf("Error retrieving attachments: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	respondWithJSON(w, 200, resp)
+}
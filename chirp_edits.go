@@ -0,0 +1,180 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lucoand/chirpy/internal/database"
+	"github.com/lucoand/chirpy/internal/entitlements"
+)
+
+const auditChirpEdit = "chirp.edited"
+
+// chirpEditJSON is a previous version of a chirp, and who replaced it. An
+// edit can be a moderator marking the chirp sensitive rather than a new
+// body.
+type chirpEditJSON struct {
+	ID             uuid.UUID  `json:"id"`
+	Body           string     `json:"body"`
+	ContentWarning *string    `json:"content_warning"`
+	Sensitive      bool       `json:"sensitive"`
+	EditedAt       time.Time  `json:"edited_at"`
+	EditedBy       *uuid.UUID `json:"edited_by"`
+}
+
+func chirpEditToJSON(e database.ChirpEdit) chirpEditJSON {
+	j := chirpEditJSON{
+		ID:        e.ID,
+		Body:      e.Body,
+		Sensitive: e.Sensitive,
+		EditedAt:  e.EditedAt,
+	}
+	if e.ContentWarning.Valid {
+		j.ContentWarning = &e.ContentWarning.String
+	}
+	if e.EditedBy.Valid {
+		j.EditedBy = &e.EditedBy.UUID
+	}
+	return j
+}
+
+func (cfg *apiConfig) handleEditChirp(w http.ResponseWriter, r *http.Request, user database.User) {
+	chirpID, err := uuid.Parse(r.PathValue("chirp_id"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid chirp ID")
+		return
+	}
+	type parameters struct {
+		Body string `json:"body"`
+	}
+	params := parameters{}
+	err = json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		w.WriteHeader(400)
+		return
+	}
+	ent, err := cfg.entitlements(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error checking entitlements for user %v: %s", user.ID, err)
+		w.WriteHeader(500)
+		return
+	}
+	if !ent.Has(entitlements.EditChirps) {
+		respondWithError(w, 403, "Editing chirps requires Chirpy Red")
+		return
+	}
+	chirp, err := cfg.dbQueries.GetChirp(r.Context(), database.GetChirpParams{
+		ID:       chirpID,
+		ViewerID: actor(user.ID),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		return
+	} else if err != nil {
+		log.Printf("Error retrieving chirp: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	if chirp.UserID != user.ID {
+		w.WriteHeader(403)
+		return
+	}
+	filtered, problem := cfg.validateChirp(ent, params.Body)
+	if problem != "" {
+		respondWithError(w, 400, problem)
+		return
+	}
+	if filtered.Body == chirp.Body {
+		cfg.respondWithChirp(w, r, actor(user.ID), chirp)
+		return
+	}
+
+	// The previous body is kept so the chirp's history can be shown.
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+	_, err = qtx.CreateChirpEdit(r.Context(), database.CreateChirpEditParams{
+		ChirpID:        chirp.ID,
+		Body:           chirp.Body,
+		EditedBy:       actor(user.ID),
+		ContentWarning: chirp.ContentWarning,
+		Sensitive:      chirp.Sensitive,
+	})
+	if err != nil {
+		log.Printf("Error recording edit of chirp %v: %s", chirp.ID, err)
+		w.WriteHeader(500)
+		return
+	}
+	updated, err := qtx.UpdateChirpBody(r.Context(), database.UpdateChirpBodyParams{
+		ID:          chirp.ID,
+		Body:        filtered.Body,
+		NeedsReview: filtered.Flagged,
+	})
+	if err != nil {
+		log.Printf("Error updating chirp %v: %s", chirp.ID, err)
+		w.WriteHeader(500)
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("Error committing edit of chirp %v: %s", chirp.ID, err)
+		w.WriteHeader(500)
+		return
+	}
+	cfg.recordAudit(r, auditEntry{
+		Action:     auditChirpEdit,
+		ActorID:    actor(user.ID),
+		TargetType: "chirp",
+		TargetID:   chirp.ID.String(),
+	})
+	cfg.queueLinkPreview(r.Context(), chirpToJSON(updated))
+	cfg.respondWithChirp(w, r, actor(user.ID), updated)
+}
+
+func (cfg *apiConfig) handleGetChirpEdits(w http.ResponseWriter, r *http.Request) {
+	viewerID, err := cfg.viewer(r)
+	if err != nil {
+		log.Printf("Error authenticating request: %s", err)
+		w.WriteHeader(401)
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirp_id"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid chirp ID")
+		return
+	}
+	_, err = cfg.dbQueries.GetChirp(r.Context(), database.GetChirpParams{
+		ID:       chirpID,
+		ViewerID: viewerID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		return
+	} else if err != nil {
+		log.Printf("Error retrieving chirp: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	edits, err := cfg.dbQueries.GetChirpEdits(r.Context(), chirpID)
+	if err != nil {
+		log.Printf("Error retrieving edits of chirp %v: %s", chirpID, err)
+		w.WriteHeader(500)
+		return
+	}
+	resp := make([]chirpEditJSON, len(edits))
+	for i, e := range edits {
+		resp[i] = chirpEditToJSON(e)
+	}
+	respondWithJSON(w, 200, resp)
+}
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lucoand/chirpy/internal/database"
+)
+
+const (
+	// maxConversationParticipants includes the user who starts the
+	// conversation.
+	maxConversationParticipants = 10
+	maxMessageLength            = 1000
+)
+
+type participantJSON struct {
+	UserID     uuid.UUID  `json:"user_id"`
+	JoinedAt   time.Time  `json:"joined_at"`
+	LastReadAt *time.Time `json:"last_read_at"`
+}
+
+type messageJSON struct {
+	ID             uuid.UUID  `json:"id"`
+	ConversationID uuid.UUID  `json:"conversation_id"`
+	SenderID       *uuid.UUID `json:"sender_id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	Body           string     `json:"body"`
+	// ReadBy lists the other participants who have read the message.
+	ReadBy []uuid.UUID `json:"read_by"`
+}
+
+type conversationJSON struct {
+	ID           uuid.UUID         `json:"id"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+	Participants []participantJSON `json:"participants"`
+	LastMessage  *messageJSON      `json:"last_message"`
+	UnreadCount  int64             `json:"unread_count"`
+}
+
+func participantsToJSON(participants []database.ConversationParticipant) []participantJSON {
+	resp := make([]participantJSON, len(participants))
+	for i, p := range participants {
+		resp[i] = participantJSON{UserID: p.UserID, JoinedAt: p.JoinedAt}
+		if p.LastReadAt.Valid {
+			resp[i].LastReadAt = &participants[i].LastReadAt.Time
+		}
+	}
+	return resp
+}
+
+// messageToJSON works out the message's read receipts from when each
+// participant last read the conversation.
+func messageToJSON(m database.Message, participants []database.ConversationParticipant) messageJSON {
+	resp := messageJSON{
+		ID:             m.ID,
+		ConversationID: m.ConversationID,
+		CreatedAt:      m.CreatedAt,
+		Body:           m.Body,
+		ReadBy:         []uuid.UUID{},
+	}
+	if m.SenderID.Valid {
+		resp.SenderID = &m.SenderID.UUID
+	}
+	for _, p := range participants {
+		if m.SenderID.Valid && p.UserID == m.SenderID.UUID {
+			continue
+		}
+		if p.LastReadAt.Valid && !m.CreatedAt.After(p.LastReadAt.Time) {
+			resp.ReadBy = append(resp.ReadBy, p.UserID)
+		}
+	}
+	return resp
+}
+
+// directKey identifies the one-to-one conversation between two users.
+func directKey(a uuid.UUID, b uuid.UUID) string {
+	ids := []string{a.String(), b.String()}
+	sort.Strings(ids)
+	return ids[0] + ":" + ids[1]
+}
+
+// conversationParticipants returns a conversation's participants, and the
+// IDs of everyone in it but userID.
+func (cfg *apiConfig) conversationParticipants(ctx context.Context, conversationID uuid.UUID, userID uuid.UUID) ([]database.ConversationParticipant, []uuid.UUID, error) {
+	participants, err := cfg.dbQueries.GetConversationParticipants(ctx, []uuid.UUID{conversationID})
+	if err != nil {
+		return nil, nil, err
+	}
+	others := []uuid.UUID{}
+	for _, p := range participants {
+		if p.UserID != userID {
+			others = append(others, p.UserID)
+		}
+	}
+	return participants, others, nil
+}
+
+// conversationForUser returns a conversation the user takes part in. Anyone
+// else gets a 404, so conversations can't be discovered by ID.
+func (cfg *apiConfig) conversationForUser(w http.ResponseWriter, r *http.Request, user database.User) (database.Conversation, bool) {
+	conversationID, err := uuid.Parse(r.PathValue("conversation_id"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid conversation ID")
+		return database.Conversation{}, false
+	}
+	conversation, err := cfg.dbQueries.GetConversation(r.Context(), database.GetConversationParams{
+		ID:     conversationID,
+		UserID: user.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Conversation not found")
+		return database.Conversation{}, false
+	} else if err != nil {
+		log.Printf("Error retrieving conversation: %s", err)
+		w.WriteHeader(500)
+		return database.Conversation{}, false
+	}
+	return conversation, true
+}
+
+// handleCreateConversation starts a conversation. Starting a one-to-one
+// conversation that already exists returns it instead.
+func (cfg *apiConfig) handleCreateConversation(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		ParticipantIDs []uuid.UUID `json:"participant_ids"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, "Invalid JSON")
+		return
+	}
+	seen := map[uuid.UUID]bool{user.ID: true}
+	others := []uuid.UUID{}
+	for _, id := range params.ParticipantIDs {
+		if !seen[id] {
+			seen[id] = true
+			others = append(others, id)
+		}
+	}
+	if len(others) == 0 {
+		respondWithError(w, 400, "A conversation needs at least one other participant")
+		return
+	}
+	if len(others)+1 > maxConversationParticipants {
+		respondWithError(w, 400, "A conversation can have at most 10 participants")
+		return
+	}
+	found, err := cfg.dbQueries.CountUsersByIDs(r.Context(), others)
+	if err != nil {
+		log.Printf("Error retrieving users from database: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	if found != int64(len(others)) {
+		respondWithError(w, 404, "User not found")
+		return
+	}
+	blocked, err := cfg.dbQueries.IsBlockedBetween(r.Context(), database.IsBlockedBetweenParams{
+		UserID:   user.ID,
+		OtherIds: others,
+	})
+	if err != nil {
+		log.Printf("Error checking blocks: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	if blocked {
+		respondWithError(w, 403, "You can't message users you have blocked or who have blocked you")
+		return
+	}
+	key := sql.NullString{}
+	if len(others) == 1 {
+		key = sql.NullString{String: directKey(user.ID, others[0]), Valid: true}
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+	status := 201
+	conversation, err := qtx.CreateConversation(r.Context(), database.CreateConversationParams{
+		CreatedBy: actor(user.ID),
+		DirectKey: key,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		status = 200
+		conversation, err = qtx.GetDirectConversation(r.Context(), key)
+	} else if err == nil {
+		err = qtx.AddConversationParticipants(r.Context(), database.AddConversationParticipantsParams{
+			ConversationID: conversation.ID,
+			UserIds:        append(others, user.ID),
+		})
+	}
+	if err != nil {
+		log.Printf("Error creating conversation: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("Error committing conversation: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	participants, _, err := cfg.conversationParticipants(r.Context(), conversation.ID, user.ID)
+	if err != nil {
+		log.Printf("Error retrieving conversation participants: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	respondWithJSON(w, status, conversationJSON{
+		ID:           conversation.ID,
+		CreatedAt:    conversation.CreatedAt,
+		UpdatedAt:    conversation.UpdatedAt,
+		Participants: participantsToJSON(participants),
+	})
+}
+
+// handleListConversations lists the user's conversations, most recently
+// active first, with their last message and how many messages the user
+// hasn't read.
+func (cfg *apiConfig) handleListConversations(w http.ResponseWriter, r *http.Request, user database.User) {
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	rows, err := cfg.dbQueries.ListConversations(r.Context(), database.ListConversationsParams{
+		UserID: user.ID,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		log.Printf("Error retrieving conversations: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	conversationIDs := make([]uuid.UUID, len(rows))
+	for i, row := range rows {
+		conversationIDs[i] = row.ID
+	}
+	participants, err := cfg.dbQueries.GetConversationParticipants(r.Context(), conversationIDs)
+	if err != nil {
+		log.Printf("Error retrieving conversation participants: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	byConversation := map[uuid.UUID][]database.ConversationParticipant{}
+	for _, p := range participants {
+		byConversation[p.ConversationID] = append(byConversation[p.ConversationID], p)
+	}
+	resp := make([]conversationJSON, len(rows))
+	for i, row := range rows {
+		resp[i] = conversationJSON{
+			ID:           row.ID,
+			CreatedAt:    row.CreatedAt,
+			UpdatedAt:    row.UpdatedAt,
+			Participants: participantsToJSON(byConversation[row.ID]),
+			UnreadCount:  row.UnreadCount,
+		}
+		if row.LastMessageID.Valid {
+			last := messageToJSON(database.Message{
+				ID:             row.LastMessageID.UUID,
+				ConversationID: row.ID,
+				SenderID:       row.LastMessageSenderID,
+				CreatedAt:      row.LastMessageCreatedAt.Time,
+				Body:           row.LastMessageBody.String,
+			}, byConversation[row.ID])
+			resp[i].LastMessage = &last
+		}
+	}
+	respondWithJSON(w, 200, resp)
+}
+
+func (cfg *apiConfig) handleGetMessages(w http.ResponseWriter, r *http.Request, user database.User) {
+	conversation, ok := cfg.conversationForUser(w, r, user)
+	if !ok {
+		return
+	}
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	messages, err := cfg.dbQueries.GetMessages(r.Context(), database.GetMessagesParams{
+		ConversationID: conversation.ID,
+		Limit:          limit,
+		Offset:         offset,
+	})
+	if err != nil {
+		log.Printf("Error retrieving messages: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	participants, _, err := cfg.conversationParticipants(r.Context(), conversation.ID, user.ID)
+	if err != nil {
+		log.Printf("Error retrieving conversation participants: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	resp := make([]messageJSON, len(messages))
+	for i, m := range messages {
+		resp[i] = messageToJSON(m, participants)
+	}
+	respondWithJSON(w, 200, resp)
+}
+
+// validateMessage checks a message body like a chirp's and returns the
+// filtered body to store, or why the message was refused. Moderators don't
+// read direct messages, so a message that matches a flag rule is refused as if
+// the rule rejected it, instead of waiting for a review that never happens.
+func (cfg *apiConfig) validateMessage(body string) (string, string) {
+	filtered, problem := cfg.validateText("Message", body, maxMessageLength)
+	if problem != "" {
+		return "", problem
+	}
+	if filtered.Flagged {
+		return "", "Message contains prohibited language"
+	}
+	return filtered.Body, ""
+}
+
+// handleSendMessage posts a message to a conversation. Messages go through
+// the same profanity rules as chirps, and can't be sent while the sender and
+// anyone else in the conversation block each other.
+func (cfg *apiConfig) handleSendMessage(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+	conversation, ok := cfg.conversationForUser(w, r, user)
+	if !ok {
+		return
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, "Invalid JSON")
+		return
+	}
+	participants, others, err := cfg.conversationParticipants(r.Context(), conversation.ID, user.ID)
+	if err != nil {
+		log.Printf("Error retrieving conversation participants: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	blocked, err := cfg.dbQueries.IsBlockedBetween(r.Context(), database.IsBlockedBetweenParams{
+		UserID:   user.ID,
+		OtherIds: others,
+	})
+	if err != nil {
+		log.Printf("Error checking blocks: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	if blocked {
+		respondWithError(w, 403, "You can't message users you have blocked or who have blocked you")
+		return
+	}
+	body, problem := cfg.validateMessage(params.Body)
+	if problem != "" {
+		respondWithError(w, 400, problem)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+	message, err := qtx.CreateMessage(r.Context(), database.CreateMessageParams{
+		ConversationID: conversation.ID,
+		SenderID:       actor(user.ID),
+		Body:           body,
+	})
+	if err == nil {
+		err = qtx.TouchConversation(r.Context(), conversation.ID)
+	}
+	if err == nil {
+		// Sending a message means the sender has read everything before it.
+		err = qtx.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+			ConversationID: conversation.ID,
+			UserID:         user.ID,
+		})
+	}
+	if err != nil {
+		log.Printf("Error creating message: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("Error committing message: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	respondWithJSON(w, 201, messageToJSON(message, participants))
+}
+
+// handleMarkConversationRead records that the user has read every message in
+// the conversation so far, which the other participants see as read
+// receipts.
+func (cfg *apiConfig) handleMarkConversationRead(w http.ResponseWriter, r *http.Request, user database.User) {
+	conversation, ok := cfg.conversationForUser(w, r, user)
+	if !ok {
+		return
+	}
+	err := cfg.dbQueries.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+		ConversationID: conversation.ID,
+		UserID:         user.ID,
+	})
+	if err != nil {
+		log.Printf("Error marking conversation read: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.WriteHeader(204)
+}
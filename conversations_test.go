@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/lucoand/chirpy/internal/profanity"
+)
+
+func TestValidateMessage(t *testing.T) {
+	cfg := &apiConfig{}
+	cfg.profanity.set(profanity.NewFilter([]profanity.Rule{
+		{Word: "kerfuffle", Mode: profanity.ModeCensor},
+		{Word: "sharbert", Mode: profanity.ModeReject},
+		{Word: "fornax", Mode: profanity.ModeFlag},
+	}))
+	tests := []struct {
+		body      string
+		want      string
+		wantAllow bool
+	}{
+		{"hello there", "hello there", true},
+		{"what a kerfuffle", "what a ****", true},
+		{"you sharbert", "", false},
+		{"you fornax", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		body, problem := cfg.validateMessage(tt.body)
+		if (problem == "") != tt.wantAllow {
+			t.Errorf("TestValidateMessage %q: expected allowed to be %t, got %q", tt.body, tt.wantAllow, problem)
+		}
+		if body != tt.want {
+			t.Errorf("TestValidateMessage %q: expected body %q, got %q", tt.body, tt.want, body)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/lucoand/chirpy/internal/database"
+	"github.com/lucoand/chirpy/internal/events"
+)
+
+// maxDraftBytes bounds drafts, which may be longer than a chirp while they
+// are being worked on but shouldn't be used as free storage.
+const maxDraftBytes = 10000
+
+type draftJSON struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Body      string    `json:"body"`
+}
+
+func draftToJSON(d database.Draft) draftJSON {
+	return draftJSON{
+		ID:        d.ID,
+		CreatedAt: d.CreatedAt,
+		UpdatedAt: d.UpdatedAt,
+		Body:      d.Body,
+	}
+}
+
+// decodeDraftBody reads a draft's body from the request, or responds with
+// an error.
+func decodeDraftBody(w http.ResponseWriter, r *http.Request) (string, bool) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, "Invalid JSON")
+		return "", false
+	}
+	if !utf8.ValidString(params.Body) {
+		respondWithError(w, 400, "Draft is not valid UTF-8")
+		return "", false
+	}
+	if len(params.Body) > maxDraftBytes {
+		respondWithError(w, 400, "Draft is too long")
+		return "", false
+	}
+	return params.Body, true
+}
+
+func parseDraftID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	draftID, err := uuid.Parse(r.PathValue("draft_id"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid draft ID")
+		return uuid.UUID{}, false
+	}
+	return draftID, true
+}
+
+func (cfg *apiConfig) handleCreateDraft(w http.ResponseWriter, r *http.Request, user database.User) {
+	body, ok := decodeDraftBody(w, r)
+	if !ok {
+		return
+	}
+	draft, err := cfg.dbQueries.CreateDraft(r.Context(), database.CreateDraftParams{
+		UserID: user.ID,
+		Body:   body,
+	})
+	if err != nil {
+		log.Printf("Error creating draft: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	respondWithJSON(w, 201, draftToJSON(draft))
+}
+
+func (cfg *apiConfig) handleListDrafts(w http.ResponseWriter, r *http.Request, user database.User) {
+	drafts, err := cfg.dbQueries.GetDrafts(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error retrieving drafts: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	resp := make([]draftJSON, len(drafts))
+	for i, d := range drafts {
+		resp[i] = draftToJSON(d)
+	}
+	respondWithJSON(w, 200, resp)
+}
+
+func (cfg *apiConfig) handleGetDraft(w http.ResponseWriter, r *http.Request, user database.User) {
+	draftID, ok := parseDraftID(w, r)
+	if !ok {
+		return
+	}
+	draft, err := cfg.dbQueries.GetDraft(r.Context(), database.GetDraftParams{
+		ID:     draftID,
+		UserID: user.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Draft not found")
+		return
+	} else if err != nil {
+		log.Printf("Error retrieving draft: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	respondWithJSON(w, 200, draftToJSON(draft))
+}
+
+func (cfg *apiConfig) handleUpdateDraft(w http.ResponseWriter, r *http.Request, user database.User) {
+	draftID, ok := parseDraftID(w, r)
+	if !ok {
+		return
+	}
+	body, ok := decodeDraftBody(w, r)
+	if !ok {
+		return
+	}
+	draft, err := cfg.dbQueries.UpdateDraft(r.Context(), database.UpdateDraftParams{
+		ID:     draftID,
+		UserID: user.ID,
+		Body:   body,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Draft not found")
+		return
+	} else if err != nil {
+		log.Printf("Error updating draft: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	respondWithJSON(w, 200, draftToJSON(draft))
+}
+
+func (cfg *apiConfig) handleDeleteDraft(w http.ResponseWriter, r *http.Request, user database.User) {
+	draftID, ok := parseDraftID(w, r)
+	if !ok {
+		return
+	}
+	n, err := cfg.dbQueries.DeleteDraft(r.Context(), database.DeleteDraftParams{
+		ID:     draftID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		log.Printf("Error deleting draft: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	if n == 0 {
+		respondWithError(w, 404, "Draft not found")
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// handlePublishDraft turns a draft into a chirp. The draft goes through the
+// same checks as POST /api/chirps, and is deleted in the same transaction
+// that creates the chirp, so publishing twice at once can't post it twice.
+func (cfg *apiConfig) handlePublishDraft(w http.ResponseWriter, r *http.Request, user database.User) {
+	draftID, ok := parseDraftID(w, r)
+	if !ok {
+		return
+	}
+	ent, err := cfg.entitlements(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error checking entitlements for user %v: %s", user.ID, err)
+		w.WriteHeader(500)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+	// Locking the draft makes concurrent edits and publishes wait, so the
+	// body that is checked is the one that is published.
+	draft, err := qtx.GetDraftForUpdate(r.Context(), database.GetDraftForUpdateParams{
+		ID:     draftID,
+		UserID: user.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Draft not found")
+		return
+	} else if err != nil {
+		log.Printf("Error retrieving draft: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	filtered, problem := cfg.validateChirp(ent, draft.Body)
+	if problem != "" {
+		respondWithError(w, 400, problem)
+		return
+	}
+	_, err = qtx.DeleteDraft(r.Context(), database.DeleteDraftParams{
+		ID:     draft.ID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		log.Printf("Error deleting draft: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	chirp, err := qtx.CreateChirp(r.Context(), database.CreateChirpParams{
+		Body:        filtered.Body,
+		UserID:      user.ID,
+		NeedsReview: filtered.Flagged,
+		Visibility:  visibilityPublic,
+	})
+	if err != nil {
+		log.Printf("Error creating Chirp: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	resp := chirpToJSON(chirp)
+	event, err := queueWebhookDeliveries(r.Context(), qtx, events.Event{
+		Type:   events.ChirpCreated,
+		UserID: chirp.UserID,
+		Data:   resp,
+	})
+	if err != nil {
+		log.Printf("Error queueing webhooks for chirp %v: %s", chirp.ID, err)
+		w.WriteHeader(500)
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("Error committing published draft: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	cfg.events.Publish(r.Context(), event)
+	respondWithJSON(w, 201, resp)
+}
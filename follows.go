@@ -0,0 +1,139 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lucoand/chirpy/internal/database"
+	"github.com/lucoand/chirpy/internal/events"
+)
+
+// followJSON is a follow as seen by one side of it: UserID is the other user.
+type followJSON struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// followCreatedJSON is the data of a follow.created event.
+type followCreatedJSON struct {
+	FollowerID uuid.UUID `json:"follower_id"`
+	FolloweeID uuid.UUID `json:"followee_id"`
+}
+
+func (cfg *apiConfig) handleListFollowing(w http.ResponseWriter, r *http.Request, user database.User) {
+	follows, err := cfg.dbQueries.GetFollowing(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error retrieving follows: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	resp := make([]followJSON, len(follows))
+	for i, follow := range follows {
+		resp[i] = followJSON{
+			UserID:    follow.FolloweeID,
+			CreatedAt: follow.CreatedAt,
+		}
+	}
+	respondWithJSON(w, 200, resp)
+}
+
+func (cfg *apiConfig) handleListFollowers(w http.ResponseWriter, r *http.Request, user database.User) {
+	follows, err := cfg.dbQueries.GetFollowers(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error retrieving followers: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	resp := make([]followJSON, len(follows))
+	for i, follow := range follows {
+		resp[i] = followJSON{
+			UserID:    follow.FollowerID,
+			CreatedAt: follow.CreatedAt,
+		}
+	}
+	respondWithJSON(w, 200, resp)
+}
+
+func (cfg *apiConfig) handleCreateFollow(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		UserID uuid.UUID `json:"user_id"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, "Invalid JSON")
+		return
+	}
+	if params.UserID == user.ID {
+		respondWithError(w, 400, "You cannot follow yourself")
+		return
+	}
+	_, err = cfg.dbQueries.GetUserByID(r.Context(), params.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		return
+	} else if err != nil {
+		log.Printf("Error retrieving user from database: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	blocked, err := cfg.dbQueries.IsBlockedBetween(r.Context(), database.IsBlockedBetweenParams{
+		UserID:   user.ID,
+		OtherIds: []uuid.UUID{params.UserID},
+	})
+	if err != nil {
+		log.Printf("Error checking blocks: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	if blocked {
+		respondWithError(w, 403, "You can't follow users you have blocked or who have blocked you")
+		return
+	}
+	created, err := cfg.dbQueries.CreateFollow(r.Context(), database.CreateFollowParams{
+		FollowerID: user.ID,
+		FolloweeID: params.UserID,
+	})
+	if err != nil {
+		log.Printf("Error creating follow: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	// Following someone again doesn't notify them again.
+	if created > 0 {
+		cfg.events.Publish(r.Context(), events.Event{
+			Type:   events.FollowCreated,
+			UserID: params.UserID,
+			Data:   followCreatedJSON{FollowerID: user.ID, FolloweeID: params.UserID},
+		})
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handleDeleteFollow(w http.ResponseWriter, r *http.Request, user database.User) {
+	followeeID, err := uuid.Parse(r.PathValue("user_id"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid user_id")
+		return
+	}
+	deleted, err := cfg.dbQueries.DeleteFollow(r.Context(), database.DeleteFollowParams{
+		FollowerID: user.ID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		log.Printf("Error deleting follow: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	if deleted == 0 {
+		w.WriteHeader(404)
+		return
+	}
+	w.WriteHeader(204)
+}
@@ -48,6 +48,26 @@ func TestTokenGood(t *testing.T) {
 	}
 }
 
+func TestTokenExpiry(t *testing.T) {
+	userID := uuid.New()
+	tokenSecret := "abcdefg"
+	before := time.Now().Truncate(time.Second)
+	tokenString, err := MakeJWT(userID, tokenSecret, 15*time.Minute)
+	if err != nil {
+		t.Errorf("TestTokenExpiry: Could not generate token string: %s", err)
+	}
+	validateID, expiresAt, err := ValidateJWTWithExpiry(tokenString, tokenSecret)
+	if err != nil {
+		t.Errorf("TestTokenExpiry: Could not validate token: %s", err)
+	}
+	if userID != validateID {
+		t.Errorf("TestTokenExpiry: Expected %v but got %v", userID, validateID)
+	}
+	if expiresAt.Before(before.Add(15*time.Minute)) || expiresAt.After(time.Now().Add(15*time.Minute)) {
+		t.Errorf("TestTokenExpiry: Expected the token to expire in 15 minutes, got %v", expiresAt)
+	}
+}
+
 func TestTokenBad(t *testing.T) {
 	userID := uuid.New()
 	goodSecret := "good"
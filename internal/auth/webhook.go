@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const signatureVersion = "v1"
+
+// SignWebhook returns the signature of a webhook body sent at timestamp (Unix
+// seconds), in the form used by the signature header: "v1=<hex HMAC-SHA256>".
+// The signed message is the timestamp, a period, then the raw body.
+func SignWebhook(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signatureVersion + "=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook checks that signatureHeader holds a valid signature of body by
+// any of secrets, and that timestamp is within tolerance of now. The header
+// may carry several comma-separated signatures so that senders can sign with
+// both the old and new secret while rotating.
+func VerifyWebhook(secrets []string, signatureHeader string, timestamp string, body []byte, tolerance time.Duration, now time.Time) error {
+	if len(secrets) == 0 {
+		return fmt.Errorf("No webhook secrets configured.")
+	}
+	if signatureHeader == "" {
+		return fmt.Errorf("Missing webhook signature.")
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("Bad webhook timestamp.")
+	}
+	sentAt := time.Unix(seconds, 0)
+	if sentAt.Before(now.Add(-tolerance)) || sentAt.After(now.Add(tolerance)) {
+		return fmt.Errorf("Webhook timestamp outside of tolerance.")
+	}
+	for _, signature := range strings.Split(signatureHeader, ",") {
+		signature = strings.TrimSpace(signature)
+		for _, secret := range secrets {
+			expected := SignWebhook(secret, timestamp, body)
+			if hmac.Equal([]byte(signature), []byte(expected)) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("Invalid webhook signature.")
+}
+
+// GetWebhookSignature returns the signature and timestamp headers sent with a
+// webhook, prefixed with the sender's name, e.g. X-Polka-Signature.
+func GetWebhookSignature(headers http.Header, sender string) (signature string, timestamp string) {
+	return headers.Get("X-" + sender + "-Signature"), headers.Get("X-" + sender + "-Timestamp")
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerifyWebhookGood(t *testing.T) {
+	now := time.Now()
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	body := []byte(`{"event":"user.upgraded"}`)
+	signature := SignWebhook("secret", timestamp, body)
+	err := VerifyWebhook([]string{"secret"}, signature, timestamp, body, 5*time.Minute, now)
+	if err != nil {
+		t.Errorf("TestVerifyWebhookGood: valid signature was rejected: %s", err)
+	}
+}
+
+func TestVerifyWebhookRotation(t *testing.T) {
+	now := time.Now()
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	body := []byte(`{"event":"user.upgraded"}`)
+	oldSignature := SignWebhook("old", timestamp, body)
+	newSignature := SignWebhook("new", timestamp, body)
+
+	err := VerifyWebhook([]string{"new", "old"}, oldSignature, timestamp, body, 5*time.Minute, now)
+	if err != nil {
+		t.Errorf("TestVerifyWebhookRotation: signature with an older active secret was rejected: %s", err)
+	}
+	err = VerifyWebhook([]string{"new"}, oldSignature+","+newSignature, timestamp, body, 5*time.Minute, now)
+	if err != nil {
+		t.Errorf("TestVerifyWebhookRotation: header with both signatures was rejected: %s", err)
+	}
+	err = VerifyWebhook([]string{"new"}, oldSignature, timestamp, body, 5*time.Minute, now)
+	if err == nil {
+		t.Errorf("TestVerifyWebhookRotation: signature with a retired secret was accepted")
+	}
+}
+
+func TestVerifyWebhookBad(t *testing.T) {
+	now := time.Now()
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	body := []byte(`{"event":"user.upgraded"}`)
+	signature := SignWebhook("secret", timestamp, body)
+
+	err := VerifyWebhook([]string{"secret"}, signature, timestamp, []byte(`{"event":"user.downgraded"}`), 5*time.Minute, now)
+	if err == nil {
+		t.Errorf("TestVerifyWebhookBad: signature for a different body was accepted")
+	}
+	err = VerifyWebhook([]string{"secret"}, "", timestamp, body, 5*time.Minute, now)
+	if err == nil {
+		t.Errorf("TestVerifyWebhookBad: missing signature was accepted")
+	}
+	err = VerifyWebhook([]string{"secret"}, signature, "yesterday", body, 5*time.Minute, now)
+	if err == nil {
+		t.Errorf("TestVerifyWebhookBad: bad timestamp was accepted")
+	}
+}
+
+func TestVerifyWebhookReplay(t *testing.T) {
+	sentAt := time.Now().Add(-10 * time.Minute)
+	timestamp := strconv.FormatInt(sentAt.Unix(), 10)
+	body := []byte(`{"event":"user.upgraded"}`)
+	signature := SignWebhook("secret", timestamp, body)
+	err := VerifyWebhook([]string{"secret"}, signature, timestamp, body, 5*time.Minute, time.Now())
+	if err == nil {
+		t.Errorf("TestVerifyWebhookReplay: signature older than the tolerance was accepted")
+	}
+}
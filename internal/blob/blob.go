@@ -0,0 +1,51 @@
+// Package blob stores uploaded files. A Store can be a directory on the
+// server's disk or a bucket on any S3-compatible service, and hands out the
+// public URL each file is served from.
+package blob
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// ErrNotFound is returned when a key has nothing stored under it.
+var ErrNotFound = errors.New("blob not found")
+
+// ErrInvalidKey is returned for keys a Store refuses to use.
+var ErrInvalidKey = errors.New("invalid blob key")
+
+// A Store keeps blobs by key.
+type Store interface {
+	// Put stores data under key, replacing whatever was there.
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get returns the data stored under key.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Delete removes key. Deleting a key that doesn't exist isn't an error.
+	Delete(ctx context.Context, key string) error
+	// URL is where clients can download key from.
+	URL(key string) string
+}
+
+// validKey reports whether key is safe to use as a file name or object name
+// in every Store: slash-separated segments of letters, digits, '.', '-' and
+// '_', with no empty, "." or ".." segments.
+func validKey(key string) bool {
+	if key == "" || len(key) > 512 {
+		return false
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+		for _, c := range segment {
+			switch {
+			case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+			case c == '.', c == '-', c == '_':
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
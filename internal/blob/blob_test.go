@@ -0,0 +1,186 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testStore runs the same checks against any Store.
+func testStore(t *testing.T, name string, s Store) {
+	ctx := context.Background()
+	err := s.Put(ctx, "media/a.png", []byte("first"), "image/png")
+	if err != nil {
+		t.Fatalf("%s: unexpected error from Put: %s", name, err)
+	}
+	err = s.Put(ctx, "media/a.png", []byte("second"), "image/png")
+	if err != nil {
+		t.Fatalf("%s: unexpected error replacing blob: %s", name, err)
+	}
+	data, err := s.Get(ctx, "media/a.png")
+	if err != nil || string(data) != "second" {
+		t.Errorf("%s: expected \"second\", got %q, %v", name, data, err)
+	}
+	err = s.Delete(ctx, "media/a.png")
+	if err != nil {
+		t.Errorf("%s: unexpected error from Delete: %s", name, err)
+	}
+	_, err = s.Get(ctx, "media/a.png")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("%s: expected ErrNotFound after Delete, got %v", name, err)
+	}
+	err = s.Delete(ctx, "media/a.png")
+	if err != nil {
+		t.Errorf("%s: expected deleting a missing blob to succeed, got %s", name, err)
+	}
+	for _, key := range []string{"", "../escape", "a//b", "/abs", "a/./b", "spaces here"} {
+		err = s.Put(ctx, key, []byte("x"), "text/plain")
+		if !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("%s: expected ErrInvalidKey for %q, got %v", name, key, err)
+		}
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	s, err := NewLocalStore(t.TempDir(), "http://localhost:8080/media/")
+	if err != nil {
+		t.Fatalf("TestLocalStore: unexpected error: %s", err)
+	}
+	testStore(t, "TestLocalStore", s)
+	if got := s.URL("media/a.png"); got != "http://localhost:8080/media/media/a.png" {
+		t.Errorf("TestLocalStore: unexpected URL %q", got)
+	}
+}
+
+// fakeS3 stands in for an S3-compatible service. It checks every request's
+// signature and keeps objects in memory.
+type fakeS3 struct {
+	secret  string
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if !f.validSignature(r, body) {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, "<Error><Code>SignatureDoesNotMatch</Code></Error>")
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		f.objects[r.URL.Path] = body
+	case http.MethodGet:
+		data, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// validSignature signs a copy of the request with the headers the client
+// said it signed, and compares the result with what the client sent.
+func (f *fakeS3) validSignature(r *http.Request, body []byte) bool {
+	if r.Header.Get("X-Amz-Content-Sha256") != hashHex(body) {
+		return false
+	}
+	auth := r.Header.Get("Authorization")
+	_, signed, ok := strings.Cut(auth, "SignedHeaders=")
+	if !ok {
+		return false
+	}
+	signed, _, _ = strings.Cut(signed, ",")
+	t, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return false
+	}
+	check, _ := http.NewRequest(r.Method, "http://"+r.Host+r.URL.RequestURI(), nil)
+	for _, name := range strings.Split(signed, ";") {
+		if name != "host" && name != "x-amz-date" {
+			check.Header[http.CanonicalHeaderKey(name)] = r.Header.Values(name)
+		}
+	}
+	signV4(check, hashHex(body), "AKIDEXAMPLE", f.secret, "us-east-1", "s3", t)
+	return check.Header.Get("Authorization") == auth
+}
+
+func TestS3Store(t *testing.T) {
+	fake := &fakeS3{secret: "secret", objects: map[string][]byte{}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	s, err := NewS3Store(S3Config{
+		Endpoint:        srv.URL,
+		Region:          "us-east-1",
+		Bucket:          "chirpy",
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "secret",
+		PublicURL:       "https://cdn.example.com/",
+	})
+	if err != nil {
+		t.Fatalf("TestS3Store: unexpected error: %s", err)
+	}
+	testStore(t, "TestS3Store", s)
+	if got := s.URL("media/a.png"); got != "https://cdn.example.com/media/a.png" {
+		t.Errorf("TestS3Store: unexpected URL %q", got)
+	}
+
+	err = s.Put(context.Background(), "b.png", []byte("data"), "image/png")
+	if err != nil {
+		t.Fatalf("TestS3Store: unexpected error: %s", err)
+	}
+	if !bytes.Equal(fake.objects["/chirpy/b.png"], []byte("data")) {
+		t.Errorf("TestS3Store: expected the object at /chirpy/b.png, got %v", fake.objects)
+	}
+}
+
+func TestS3StoreRejectedSignature(t *testing.T) {
+	fake := &fakeS3{secret: "secret", objects: map[string][]byte{}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	s, err := NewS3Store(S3Config{
+		Endpoint:        srv.URL,
+		Region:          "us-east-1",
+		Bucket:          "chirpy",
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wrong",
+	})
+	if err != nil {
+		t.Fatalf("TestS3StoreRejectedSignature: unexpected error: %s", err)
+	}
+	err = s.Put(context.Background(), "a.png", []byte("data"), "image/png")
+	if err == nil || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Errorf("TestS3StoreRejectedSignature: expected the service's error, got %v", err)
+	}
+	if got := s.URL("a.png"); got != srv.URL+"/chirpy/a.png" {
+		t.Errorf("TestS3StoreRejectedSignature: unexpected default URL %q", got)
+	}
+}
+
+// TestSignV4 checks the signer against the example in AWS's Signature
+// Version 4 documentation.
+func TestSignV4(t *testing.T) {
+	req, _ := http.NewRequest("GET", "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	date := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	signV4(req, hashHex(nil), "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "us-east-1", "iam", date)
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date, " +
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("TestSignV4: expected\n%s\ngot\n%s", want, got)
+	}
+}
@@ -0,0 +1,89 @@
+// Package chirptext normalizes chirp bodies and measures their length the way
+// a reader would count it, in user-perceived characters rather than bytes.
+package chirptext
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
+)
+
+// URLWeight is how many characters a URL counts as, however long it is.
+const URLWeight = 23
+
+var (
+	ErrEmpty            = errors.New("chirp is empty")
+	ErrInvalidUTF8      = errors.New("chirp is not valid UTF-8")
+	ErrControlCharacter = errors.New("chirp contains control characters")
+)
+
+// urlPattern matches http and https URLs. Trailing punctuation is trimmed
+// separately, since "see https://example.com." shouldn't include the period.
+var urlPattern = regexp.MustCompile(`(?i)\bhttps?://[^\s<>"]+`)
+
+// Prepare returns the body in the form it should be stored: NFC normalized,
+// with Windows line endings replaced by newlines. It rejects bodies that are
+// empty or only whitespace, that aren't valid UTF-8, and that contain control
+// characters other than newlines and tabs.
+func Prepare(body string) (string, error) {
+	if !utf8.ValidString(body) {
+		return "", ErrInvalidUTF8
+	}
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+	for _, r := range body {
+		if unicode.IsControl(r) && r != '\n' && r != '\t' {
+			return "", ErrControlCharacter
+		}
+	}
+	body = norm.NFC.String(body)
+	if isBlank(body) {
+		return "", ErrEmpty
+	}
+	return body, nil
+}
+
+// isBlank reports whether the body has nothing visible in it. Invisible
+// format characters such as zero-width spaces don't count as content.
+func isBlank(body string) bool {
+	for _, r := range body {
+		if !unicode.IsSpace(r) && !unicode.Is(unicode.Cf, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// A URL is a link found in a body. Start and End are byte offsets into the
+// body, so body[Start:End] == URL.
+type URL struct {
+	URL   string
+	Start int
+	End   int
+}
+
+// URLs returns the http and https links in a body, in order.
+func URLs(body string) []URL {
+	urls := []URL{}
+	for _, loc := range urlPattern.FindAllStringIndex(body, -1) {
+		end := loc[0] + len(strings.TrimRight(body[loc[0]:loc[1]], `.,:;!?)]}'`))
+		urls = append(urls, URL{URL: body[loc[0]:end], Start: loc[0], End: end})
+	}
+	return urls
+}
+
+// Length returns the length of a prepared body: the number of grapheme
+// clusters, with each URL counted as URLWeight.
+func Length(body string) int {
+	length := 0
+	last := 0
+	for _, u := range URLs(body) {
+		length += uniseg.GraphemeClusterCount(body[last:u.Start]) + URLWeight
+		last = u.End
+	}
+	return length + uniseg.GraphemeClusterCount(body[last:])
+}
@@ -0,0 +1,132 @@
+package chirptext
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLength(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"ascii", "Hello, Chirpy!", 14},
+		{"emoji", strings.Repeat("\U0001F600", 50), 50},
+		{"emoji zwj sequence", "\U0001F469\u200d\U0001F469\u200d\U0001F467\u200d\U0001F466", 1},
+		{"emoji skin tone", "\U0001F44D\U0001F3FD", 1},
+		{"flag", "\U0001F1EF\U0001F1F5", 1},
+		{"keycap", "1\ufe0f\u20e3", 1},
+		{"japanese", "日本語のつぶやき", 8},
+		{"chinese", "你好，世界", 5},
+		{"korean", "안녕하세요", 5},
+		{"arabic", "مرحبا بالعالم", 13},
+		{"hebrew with points", "שָׁלוֹם", 4},
+		// Extended grapheme clusters keep a vowel sign with its consonant, but
+		// split conjuncts: न म स् ते.
+		{"hindi", "नमस्ते", 4},
+		{"thai", "สวัสดี", 4},
+		{"russian", "Привет, мир", 11},
+		{"greek", "Καλημέρα", 8},
+		{"combining accent", "cafe\u0301", 4},
+		{"newline", "line one\nline two", 17},
+		{"crlf", "a\r\nb", 3},
+		{"url", "https://example.com/a/very/long/path?with=query&and=more", URLWeight},
+		{"short url", "http://a.io", URLWeight},
+		{"url in text", "see https://example.com/page for more", 4 + URLWeight + 9},
+		{"url trailing period", "go to https://example.com.", 6 + URLWeight + 1},
+		{"url in parentheses", "(https://example.com)", 1 + URLWeight + 1},
+		{"two urls", "https://a.example https://b.example", URLWeight + 1 + URLWeight},
+		{"uppercase scheme", "HTTPS://EXAMPLE.COM", URLWeight},
+		{"not a url", "https: not a link", 17},
+		{"empty", "", 0},
+	}
+	for _, tt := range tests {
+		got := Length(tt.body)
+		if got != tt.want {
+			t.Errorf("TestLength %s: expected %d, got %d", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestURLs(t *testing.T) {
+	body := "see https://example.com/page, or (http://a.io) — ünïcode first https://b.example."
+	want := []URL{
+		{URL: "https://example.com/page", Start: 4, End: 28},
+		{URL: "http://a.io", Start: 34, End: 45},
+		{URL: "https://b.example", Start: 67, End: 84},
+	}
+	got := URLs(body)
+	if len(got) != len(want) {
+		t.Fatalf("TestURLs: expected %d URLs, got %v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("TestURLs: URL %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+		if body[got[i].Start:got[i].End] != got[i].URL {
+			t.Errorf("TestURLs: URL %d: offsets don't match %q", i, got[i].URL)
+		}
+	}
+	if got := URLs("no links here"); len(got) != 0 {
+		t.Errorf("TestURLs: expected no URLs, got %v", got)
+	}
+}
+
+func TestPrepareGood(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"ascii", "Hello", "Hello"},
+		{"nfc composes accents", "cafe\u0301", "caf\u00e9"},
+		{"nfc composes hangul", "\u1112\u1161\u11ab", "\ud55c"},
+		{"nfc keeps compatibility characters", "\ufb01ne", "\ufb01ne"},
+		{"crlf becomes newline", "a\r\nb", "a\nb"},
+		{"tabs and newlines", "a\tb\nc", "a\tb\nc"},
+		{"emoji zwj sequence", "\U0001F469\u200d\U0001F4BB", "\U0001F469\u200d\U0001F4BB"},
+		{"surrounding whitespace is kept", "  hi  ", "  hi  "},
+		{"arabic", "مرحبا", "مرحبا"},
+	}
+	for _, tt := range tests {
+		got, err := Prepare(tt.body)
+		if err != nil {
+			t.Errorf("TestPrepareGood %s: unexpected error: %s", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("TestPrepareGood %s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestPrepareBad(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want error
+	}{
+		{"empty", "", ErrEmpty},
+		{"spaces", "   ", ErrEmpty},
+		{"whitespace", " \t\n ", ErrEmpty},
+		{"ideographic space", "\u3000", ErrEmpty},
+		{"no-break space", "\u00a0\u00a0", ErrEmpty},
+		{"zero-width space", "\u200b", ErrEmpty},
+		{"zero-width joiners", " \u200d\u200c ", ErrEmpty},
+		{"null", "hi\x00there", ErrControlCharacter},
+		{"bell", "\a", ErrControlCharacter},
+		{"escape", "\x1b[31mred", ErrControlCharacter},
+		{"lone carriage return", "a\rb", ErrControlCharacter},
+		{"delete", "a\x7f", ErrControlCharacter},
+		{"c1 control", "a\u0085b", ErrControlCharacter},
+		{"invalid utf-8", "a\xffb", ErrInvalidUTF8},
+	}
+	for _, tt := range tests {
+		_, err := Prepare(tt.body)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("TestPrepareBad %s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+}
@@ -7,29 +7,56 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
 )
 
+const clearChirpReview = `-- name: ClearChirpReview :exec
+UPDATE chirps
+SET needs_review = FALSE
+WHERE id = $1
+`
+
+func (q *Queries) ClearChirpReview(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, clearChirpReview, id)
+	return err
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, needs_review, visibility, content_warning, sensitive)
 VALUES (
 	gen_random_uuid(),
 	NOW(),
 	NOW(),
 	$1,
-	$2
+	$2,
+	$3,
+	$4,
+	$5,
+	$6
 	)
-	RETURNING id, created_at, updated_at, body, user_id
+	RETURNING id, created_at, updated_at, body, user_id, needs_review, hidden_at, visibility, content_warning, sensitive
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body           string
+	UserID         uuid.UUID
+	NeedsReview    bool
+	Visibility     string
+	ContentWarning sql.NullString
+	Sensitive      bool
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.NeedsReview,
+		arg.Visibility,
+		arg.ContentWarning,
+		arg.Sensitive,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,6 +64,11 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.NeedsReview,
+		&i.HiddenAt,
+		&i.Visibility,
+		&i.ContentWarning,
+		&i.Sensitive,
 	)
 	return i, err
 }
@@ -52,12 +84,59 @@ func (q *Queries) DeleteChirpByID(ctx context.Context, id uuid.UUID) error {
 }
 
 const getChirp = `-- name: GetChirp :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, needs_review, hidden_at, visibility, content_warning, sensitive FROM chirps
+WHERE id = $1 AND hidden_at IS NULL
+AND (
+	user_id = $2
+	OR NOT EXISTS (
+		SELECT 1 FROM users
+		WHERE users.id = chirps.user_id AND users.account_status = 'shadowbanned'
+	)
+)
+AND (
+	visibility IN ('public', 'unlisted')
+	OR user_id = $2
+	OR (visibility = 'followers_only' AND EXISTS (
+		SELECT 1 FROM follows
+		WHERE follows.follower_id = $2 AND follows.followee_id = chirps.user_id
+	))
+	OR (visibility IN ('followers_only', 'mentioned_only') AND EXISTS (
+		SELECT 1 FROM chirp_mentions
+		WHERE chirp_mentions.chirp_id = chirps.id AND chirp_mentions.user_id = $2
+	))
+)
+`
+
+type GetChirpParams struct {
+	ID       uuid.UUID
+	ViewerID uuid.NullUUID
+}
+
+func (q *Queries) GetChirp(ctx context.Context, arg GetChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirp, arg.ID, arg.ViewerID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.NeedsReview,
+		&i.HiddenAt,
+		&i.Visibility,
+		&i.ContentWarning,
+		&i.Sensitive,
+	)
+	return i, err
+}
+
+const getChirpIncludingHidden = `-- name: GetChirpIncludingHidden :one
+SELECT id, created_at, updated_at, body, user_id, needs_review, hidden_at, visibility, content_warning, sensitive FROM chirps
 WHERE id = $1
 `
 
-func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, getChirp, id)
+func (q *Queries) GetChirpIncludingHidden(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpIncludingHidden, id)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -65,17 +144,75 @@ func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.NeedsReview,
+		&i.HiddenAt,
+		&i.Visibility,
+		&i.ContentWarning,
+		&i.Sensitive,
 	)
 	return i, err
 }
 
 const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-ORDER BY created_at ASC
+SELECT id, created_at, updated_at, body, user_id, needs_review, hidden_at, visibility, content_warning, sensitive FROM chirps
+WHERE hidden_at IS NULL
+AND (
+	user_id = $1
+	OR NOT EXISTS (
+		SELECT 1 FROM users
+		WHERE users.id = chirps.user_id AND users.account_status = 'shadowbanned'
+	)
+)
+AND (
+	visibility = 'public'
+	OR user_id = $1
+	OR (visibility = 'followers_only' AND EXISTS (
+		SELECT 1 FROM follows
+		WHERE follows.follower_id = $1 AND follows.followee_id = chirps.user_id
+	))
+	OR (visibility IN ('followers_only', 'mentioned_only') AND EXISTS (
+		SELECT 1 FROM chirp_mentions
+		WHERE chirp_mentions.chirp_id = chirps.id AND chirp_mentions.user_id = $1
+	))
+)
+AND NOT EXISTS (
+	SELECT 1 FROM user_blocks
+	WHERE user_blocks.blocker_id = $1 AND user_blocks.blocked_id = chirps.user_id
+)
+AND NOT EXISTS (
+	SELECT 1 FROM user_mutes
+	WHERE user_mutes.user_id = $1 AND user_mutes.user_id <> chirps.user_id
+	AND chirps.body ~* user_mutes.pattern
+)
+AND NOT (
+	chirps.sensitive AND chirps.user_id <> $1
+	AND EXISTS (
+		SELECT 1 FROM user_preferences
+		WHERE user_preferences.user_id = $1 AND user_preferences.hide_sensitive
+	)
+)
+ORDER BY
+	CASE WHEN $2::boolean THEN created_at END DESC,
+	CASE WHEN $2::boolean THEN id END DESC,
+	created_at ASC,
+	id ASC
+LIMIT $3 OFFSET $4
 `
 
-func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps)
+type GetChirpsParams struct {
+	ViewerID uuid.NullUUID
+	SortDesc bool
+	Limit    sql.NullInt32
+	Offset   int32
+}
+
+func (q *Queries) GetChirps(ctx context.Context, arg GetChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirps,
+		arg.ViewerID,
+		arg.SortDesc,
+		arg.Limit,
+		arg.Offset,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -89,6 +226,11 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.NeedsReview,
+			&i.HiddenAt,
+			&i.Visibility,
+			&i.ContentWarning,
+			&i.Sensitive,
 		); err != nil {
 			return nil, err
 		}
@@ -104,13 +246,73 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirpsByUserID = `-- name: GetChirpsByUserID :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-WHERE user_id = $1
-ORDER BY created_at ASC
+SELECT id, created_at, updated_at, body, user_id, needs_review, hidden_at, visibility, content_warning, sensitive FROM chirps
+WHERE user_id = $1 AND hidden_at IS NULL
+AND (
+	user_id = $2
+	OR NOT EXISTS (
+		SELECT 1 FROM users
+		WHERE users.id = chirps.user_id AND users.account_status = 'shadowbanned'
+	)
+)
+AND (
+	visibility IN ('public', 'unlisted')
+	OR user_id = $2
+	OR (visibility = 'followers_only' AND EXISTS (
+		SELECT 1 FROM follows
+		WHERE follows.follower_id = $2 AND follows.followee_id = chirps.user_id
+	))
+	OR (visibility IN ('followers_only', 'mentioned_only') AND EXISTS (
+		SELECT 1 FROM chirp_mentions
+		WHERE chirp_mentions.chirp_id = chirps.id AND chirp_mentions.user_id = $2
+	))
+)
+AND NOT EXISTS (
+	SELECT 1 FROM user_blocks
+	WHERE user_blocks.blocker_id = $2 AND user_blocks.blocked_id = chirps.user_id
+)
+AND NOT EXISTS (
+	SELECT 1 FROM user_mutes
+	WHERE user_mutes.user_id = $2 AND user_mutes.user_id <> chirps.user_id
+	AND chirps.body ~* user_mutes.pattern
+)
+AND NOT (
+	chirps.sensitive AND chirps.user_id <> $2
+	AND EXISTS (
+		SELECT 1 FROM user_preferences
+		WHERE user_preferences.user_id = $2 AND user_preferences.hide_sensitive
+	)
+)
+ORDER BY
+	CASE WHEN $3::boolean THEN (
+		SELECT pinned_at FROM pinned_chirps
+		WHERE pinned_chirps.chirp_id = chirps.id
+	) END DESC NULLS LAST,
+	CASE WHEN $4::boolean THEN created_at END DESC,
+	CASE WHEN $4::boolean THEN id END DESC,
+	created_at ASC,
+	id ASC
+LIMIT $5 OFFSET $6
 `
 
-func (q *Queries) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsByUserID, userID)
+type GetChirpsByUserIDParams struct {
+	UserID      uuid.UUID
+	ViewerID    uuid.NullUUID
+	PinnedFirst bool
+	SortDesc    bool
+	Limit       sql.NullInt32
+	Offset      int32
+}
+
+func (q *Queries) GetChirpsByUserID(ctx context.Context, arg GetChirpsByUserIDParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByUserID,
+		arg.UserID,
+		arg.ViewerID,
+		arg.PinnedFirst,
+		arg.SortDesc,
+		arg.Limit,
+		arg.Offset,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -124,6 +326,11 @@ func (q *Queries) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Ch
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.NeedsReview,
+			&i.HiddenAt,
+			&i.Visibility,
+			&i.ContentWarning,
+			&i.Sensitive,
 		); err != nil {
 			return nil, err
 		}
@@ -137,3 +344,77 @@ func (q *Queries) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Ch
 	}
 	return items, nil
 }
+
+const hideChirp = `-- name: HideChirp :exec
+UPDATE chirps
+SET hidden_at = NOW(), needs_review = FALSE, updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) HideChirp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, hideChirp, id)
+	return err
+}
+
+const markChirpSensitive = `-- name: MarkChirpSensitive :one
+UPDATE chirps
+SET sensitive = TRUE,
+	content_warning = COALESCE($1, content_warning),
+	updated_at = NOW()
+WHERE id = $2
+RETURNING id, created_at, updated_at, body, user_id, needs_review, hidden_at, visibility, content_warning, sensitive
+`
+
+type MarkChirpSensitiveParams struct {
+	ID             uuid.UUID
+	ContentWarning sql.NullString
+}
+
+func (q *Queries) MarkChirpSensitive(ctx context.Context, arg MarkChirpSensitiveParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, markChirpSensitive, arg.ID, arg.ContentWarning)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.NeedsReview,
+		&i.HiddenAt,
+		&i.Visibility,
+		&i.ContentWarning,
+		&i.Sensitive,
+	)
+	return i, err
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2, needs_review = needs_review OR $3, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, needs_review, hidden_at, visibility, content_warning, sensitive
+`
+
+type UpdateChirpBodyParams struct {
+	ID          uuid.UUID
+	Body        string
+	NeedsReview bool
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.ID, arg.Body, arg.NeedsReview)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.NeedsReview,
+		&i.HiddenAt,
+		&i.Visibility,
+		&i.ContentWarning,
+		&i.Sensitive,
+	)
+	return i, err
+}
@@ -6,17 +6,188 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type AuditEvent struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	Action     string
+	ActorID    uuid.NullUUID
+	TargetType sql.NullString
+	TargetID   sql.NullString
+	Ip         string
+	RequestID  string
+	Metadata   json.RawMessage
+}
+
+type Bookmark struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
 type Chirp struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Body           string
+	UserID         uuid.UUID
+	NeedsReview    bool
+	HiddenAt       sql.NullTime
+	Visibility     string
+	ContentWarning sql.NullString
+	Sensitive      bool
+}
+
+type ChirpEdit struct {
+	ID             uuid.UUID
+	ChirpID        uuid.UUID
+	Body           string
+	EditedAt       time.Time
+	EditedBy       uuid.NullUUID
+	ContentWarning sql.NullString
+	Sensitive      bool
+}
+
+type ChirpMention struct {
+	ChirpID uuid.UUID
+	UserID  uuid.UUID
+}
+
+type ChirpReport struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	ChirpID    uuid.UUID
+	ReporterID uuid.UUID
+	Reason     string
+	Status     string
+	Resolution sql.NullString
+	ResolvedBy uuid.NullUUID
+	ResolvedAt sql.NullTime
+}
+
+type Conversation struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	CreatedBy uuid.NullUUID
+	DirectKey sql.NullString
+}
+
+type ConversationParticipant struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+	JoinedAt       time.Time
+	LastReadAt     sql.NullTime
+}
+
+type Draft struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
 	Body      string
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type LinkPreview struct {
+	Url           string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Status        string
+	Title         sql.NullString
+	Description   sql.NullString
+	ImageUrl      sql.NullString
+	LastError     sql.NullString
+	FetchedAt     sql.NullTime
+	NextAttemptAt time.Time
+}
+
+type Medium struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UserID          uuid.UUID
+	ChirpID         uuid.NullUUID
+	Position        int32
+	Key             string
+	ThumbnailKey    string
+	ContentType     string
+	Width           int32
+	Height          int32
+	ThumbnailWidth  int32
+	ThumbnailHeight int32
+	SizeBytes       int32
+}
+
+type Message struct {
+	ID             uuid.UUID
+	ConversationID uuid.UUID
+	SenderID       uuid.NullUUID
+	CreatedAt      time.Time
+	Body           string
+}
+
+type Notification struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
 	UserID    uuid.UUID
+	Type      string
+	EventID   uuid.UUID
+	EventType string
+	ActorID   uuid.NullUUID
+	ChirpID   uuid.NullUUID
+	Data      json.RawMessage
+	ReadAt    sql.NullTime
+}
+
+type NotificationPreference struct {
+	UserID    uuid.UUID
+	Type      string
+	Enabled   bool
+	UpdatedAt time.Time
+}
+
+type PinnedChirp struct {
+	ChirpID  uuid.UUID
+	UserID   uuid.UUID
+	PinnedAt time.Time
+}
+
+type Poll struct {
+	ChirpID  uuid.UUID
+	ClosesAt time.Time
+}
+
+type PollOption struct {
+	ChirpID  uuid.UUID
+	Position int32
+	Text     string
+}
+
+type PollVote struct {
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	Position  int32
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type ProfanityRule struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Word      string
+	Mode      string
 }
 
 type RefreshToken struct {
@@ -28,11 +199,102 @@ type RefreshToken struct {
 	UserID    uuid.UUID
 }
 
+type ScheduledChirp struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	UserID         uuid.UUID
+	Body           string
+	NeedsReview    bool
+	PublishAt      time.Time
+	Status         string
+	LastError      sql.NullString
+	Visibility     string
+	ContentWarning sql.NullString
+	Sensitive      bool
+}
+
+type Subscription struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	UserID           uuid.UUID
+	Plan             string
+	Status           string
+	CurrentPeriodEnd time.Time
+	CanceledAt       sql.NullTime
+}
+
 type User struct {
 	ID             uuid.UUID
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
 	Email          string
 	HashedPassword string
-	IsChirpyRed    bool
+	Role           string
+	SuspendedUntil sql.NullTime
+	AccountStatus  string
+	StatusReason   sql.NullString
+}
+
+type UserBlock struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
+type UserMute struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Phrase    string
+	Pattern   string
+}
+
+type UserPreference struct {
+	UserID        uuid.UUID
+	UpdatedAt     time.Time
+	HideSensitive bool
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	EndpointID     uuid.UUID
+	EventID        uuid.UUID
+	EventType      string
+	Payload        json.RawMessage
+	Status         string
+	Attempts       int32
+	ResponseStatus sql.NullInt32
+	LastError      sql.NullString
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	NextAttemptAt  time.Time
+	DeliveredAt    sql.NullTime
+}
+
+type WebhookEndpoint struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	OwnerID   uuid.UUID
+	Url       string
+	Secret    string
+	Events    []string
+	AllUsers  bool
+}
+
+type WebhookEvent struct {
+	ID            uuid.UUID
+	Source        string
+	EventID       string
+	EventType     string
+	Payload       json.RawMessage
+	Status        string
+	Attempts      int32
+	LastError     sql.NullString
+	ReceivedAt    time.Time
+	UpdatedAt     time.Time
+	NextAttemptAt time.Time
+	ProcessedAt   sql.NullTime
 }
@@ -7,11 +7,25 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const countUsersByIDs = `-- name: CountUsersByIDs :one
+SELECT COUNT(*) FROM users
+WHERE id = ANY($1::uuid[])
+`
+
+func (q *Queries) CountUsersByIDs(ctx context.Context, ids []uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUsersByIDs, pq.Array(ids))
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (id, created_at, updated_at, email, hashed_password)
 VALUES (
@@ -21,7 +35,7 @@ VALUES (
 	$1,
 	$2
 	)
-	RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+	RETURNING id, created_at, updated_at, email, hashed_password, role, suspended_until, account_status, status_reason
 `
 
 type CreateUserParams struct {
@@ -38,7 +52,10 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.AccountStatus,
+		&i.StatusReason,
 	)
 	return i, err
 }
@@ -53,7 +70,7 @@ func (q *Queries) DeleteUsers(ctx context.Context) error {
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red from users
+SELECT id, created_at, updated_at, email, hashed_password, role, suspended_until, account_status, status_reason from users
 WHERE email = $1
 `
 
@@ -66,33 +83,79 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.AccountStatus,
+		&i.StatusReason,
 	)
 	return i, err
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, created_at, updated_at, email, is_chirpy_red
-FROM users WHERE id = $1
+SELECT id, created_at, updated_at, email, hashed_password, role, suspended_until, account_status, status_reason FROM users
+WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.AccountStatus,
+		&i.StatusReason,
+	)
+	return i, err
+}
+
+const lockUser = `-- name: LockUser :exec
+SELECT id FROM users
+WHERE id = $1
+FOR UPDATE
 `
 
-type GetUserByIDRow struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Email       string
-	IsChirpyRed bool
+func (q *Queries) LockUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, lockUser, id)
+	return err
 }
 
-func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (GetUserByIDRow, error) {
-	row := q.db.QueryRowContext(ctx, getUserByID, id)
-	var i GetUserByIDRow
+const setUserAccountStatus = `-- name: SetUserAccountStatus :one
+UPDATE users
+SET account_status = $2, status_reason = $3, suspended_until = $4, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, role, suspended_until, account_status, status_reason
+`
+
+type SetUserAccountStatusParams struct {
+	ID             uuid.UUID
+	AccountStatus  string
+	StatusReason   sql.NullString
+	SuspendedUntil sql.NullTime
+}
+
+func (q *Queries) SetUserAccountStatus(ctx context.Context, arg SetUserAccountStatusParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserAccountStatus,
+		arg.ID,
+		arg.AccountStatus,
+		arg.StatusReason,
+		arg.SuspendedUntil,
+	)
+	var i User
 	err := row.Scan(
 		&i.ID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Email,
-		&i.IsChirpyRed,
+		&i.HashedPassword,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.AccountStatus,
+		&i.StatusReason,
 	)
 	return i, err
 }
@@ -101,7 +164,7 @@ const updateUserEmailAndPasswordFromID = `-- name: UpdateUserEmailAndPasswordFro
 UPDATE users
 SET email = $2, hashed_password = $3, updated_at = NOW()
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, is_chirpy_red
+RETURNING id, created_at, updated_at, email, role
 `
 
 type UpdateUserEmailAndPasswordFromIDParams struct {
@@ -111,11 +174,11 @@ type UpdateUserEmailAndPasswordFromIDParams struct {
 }
 
 type UpdateUserEmailAndPasswordFromIDRow struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Email       string
-	IsChirpyRed bool
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Email     string
+	Role      string
 }
 
 func (q *Queries) UpdateUserEmailAndPasswordFromID(ctx context.Context, arg UpdateUserEmailAndPasswordFromIDParams) (UpdateUserEmailAndPasswordFromIDRow, error) {
@@ -126,18 +189,36 @@ func (q *Queries) UpdateUserEmailAndPasswordFromID(ctx context.Context, arg Upda
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Email,
-		&i.IsChirpyRed,
+		&i.Role,
 	)
 	return i, err
 }
 
-const upgradeUserToChirpyRed = `-- name: UpgradeUserToChirpyRed :exec
+const updateUserRole = `-- name: UpdateUserRole :one
 UPDATE users
-SET is_chirpy_red = TRUE, updated_at = NOW()
+SET role = $2, updated_at = NOW()
 WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, role, suspended_until, account_status, status_reason
 `
 
-func (q *Queries) UpgradeUserToChirpyRed(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, upgradeUserToChirpyRed, id)
-	return err
+type UpdateUserRoleParams struct {
+	ID   uuid.UUID
+	Role string
+}
+
+func (q *Queries) UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserRole, arg.ID, arg.Role)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.AccountStatus,
+		&i.StatusReason,
+	)
+	return i, err
 }
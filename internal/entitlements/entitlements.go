@@ -0,0 +1,112 @@
+// Package entitlements maps subscription plans to the features and limits
+// they unlock. Handlers ask an Entitlements value what a user may do instead
+// of checking which plan they are on.
+package entitlements
+
+// Plans. A user with no current subscription is on PlanFree.
+const (
+	PlanFree      = "free"
+	PlanChirpyRed = "chirpy_red"
+)
+
+// A Feature is something a plan either includes or doesn't.
+type Feature string
+
+const (
+	// EditChirps allows a user to edit their own chirps.
+	EditChirps Feature = "edit_chirps"
+	// ProfileBadge shows the plan's badge on the user's profile.
+	ProfileBadge Feature = "profile_badge"
+)
+
+// A Limit is a number that varies by plan.
+type Limit string
+
+const (
+	// ChirpLength is the longest chirp a user may post.
+	ChirpLength Limit = "chirp_length"
+	// ScheduledChirps is how many chirps a user may have waiting to be
+	// published at once.
+	ScheduledChirps Limit = "scheduled_chirps"
+	// PinnedChirps is how many of their chirps a user may pin to their
+	// profile.
+	PinnedChirps Limit = "pinned_chirps"
+)
+
+type plan struct {
+	features map[Feature]bool
+	limits   map[Limit]int
+}
+
+var plans = map[string]plan{
+	PlanFree: {
+		features: map[Feature]bool{},
+		limits: map[Limit]int{
+			ChirpLength:     140,
+			ScheduledChirps: 5,
+			PinnedChirps:    1,
+		},
+	},
+	PlanChirpyRed: {
+		features: map[Feature]bool{
+			EditChirps:   true,
+			ProfileBadge: true,
+		},
+		limits: map[Limit]int{
+			ChirpLength:     280,
+			ScheduledChirps: 100,
+			PinnedChirps:    5,
+		},
+	},
+}
+
+// Entitlements are what a user's plan allows.
+type Entitlements struct {
+	Plan string
+}
+
+// ForPlan returns the entitlements for a plan. Unknown plans get the free
+// plan's entitlements.
+func ForPlan(name string) Entitlements {
+	if _, ok := plans[name]; !ok {
+		return Entitlements{Plan: PlanFree}
+	}
+	return Entitlements{Plan: name}
+}
+
+// Has reports whether the plan includes a feature.
+func (e Entitlements) Has(f Feature) bool {
+	return plans[e.plan()].features[f]
+}
+
+// Limit returns the plan's value for a limit.
+func (e Entitlements) Limit(l Limit) int {
+	return plans[e.plan()].limits[l]
+}
+
+// Features lists the features the plan includes, in a stable order.
+func (e Entitlements) Features() []Feature {
+	features := []Feature{}
+	for _, f := range []Feature{EditChirps, ProfileBadge} {
+		if e.Has(f) {
+			features = append(features, f)
+		}
+	}
+	return features
+}
+
+// Limits returns every limit for the plan.
+func (e Entitlements) Limits() map[Limit]int {
+	limits := make(map[Limit]int, len(plans[e.plan()].limits))
+	for l, v := range plans[e.plan()].limits {
+		limits[l] = v
+	}
+	return limits
+}
+
+func (e Entitlements) plan() string {
+	if _, ok := plans[e.Plan]; !ok {
+		return PlanFree
+	}
+	return e.Plan
+}
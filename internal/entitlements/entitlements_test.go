@@ -0,0 +1,40 @@
+package entitlements
+
+import "testing"
+
+func TestEntitlementsFree(t *testing.T) {
+	e := ForPlan(PlanFree)
+	if e.Has(EditChirps) || e.Has(ProfileBadge) {
+		t.Errorf("TestEntitlementsFree: free plan should not include paid features, got %v", e.Features())
+	}
+	if e.Limit(ChirpLength) != 140 {
+		t.Errorf("TestEntitlementsFree: expected chirp length 140, got %d", e.Limit(ChirpLength))
+	}
+}
+
+func TestEntitlementsChirpyRed(t *testing.T) {
+	e := ForPlan(PlanChirpyRed)
+	if !e.Has(EditChirps) || !e.Has(ProfileBadge) {
+		t.Errorf("TestEntitlementsChirpyRed: expected paid features, got %v", e.Features())
+	}
+	if e.Limit(ChirpLength) <= ForPlan(PlanFree).Limit(ChirpLength) {
+		t.Errorf("TestEntitlementsChirpyRed: expected a higher chirp length limit than free, got %d", e.Limit(ChirpLength))
+	}
+	if e.Limit(ScheduledChirps) <= ForPlan(PlanFree).Limit(ScheduledChirps) {
+		t.Errorf("TestEntitlementsChirpyRed: expected more scheduled chirps than free, got %d", e.Limit(ScheduledChirps))
+	}
+	if e.Limit(PinnedChirps) <= ForPlan(PlanFree).Limit(PinnedChirps) {
+		t.Errorf("TestEntitlementsChirpyRed: expected more pinned chirps than free, got %d", e.Limit(PinnedChirps))
+	}
+}
+
+func TestEntitlementsUnknownPlan(t *testing.T) {
+	for _, e := range []Entitlements{ForPlan("platinum"), {Plan: "platinum"}, {}} {
+		if e.Has(EditChirps) {
+			t.Errorf("TestEntitlementsUnknownPlan: %q should not include paid features", e.Plan)
+		}
+		if e.Limit(ChirpLength) != ForPlan(PlanFree).Limit(ChirpLength) {
+			t.Errorf("TestEntitlementsUnknownPlan: %q should get free limits, got %d", e.Plan, e.Limit(ChirpLength))
+		}
+	}
+}
@@ -0,0 +1,81 @@
+// Package events is an in-process bus for domain events such as a chirp being
+// created. Handlers publish an event once the change it describes has been
+// committed, and subscribers react to it. Nothing a subscriber does survives
+// a crash before it runs, so anything that must not be lost, like outbound
+// webhooks, is recorded in the same transaction as the change instead.
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event types.
+const (
+	ChirpCreated   = "chirp.created"
+	ChirpDeleted   = "chirp.deleted"
+	UserUpgraded   = "user.upgraded"
+	UserDowngraded = "user.downgraded"
+	// FollowCreated is published when a user starts following another, about
+	// the user followed.
+	FollowCreated = "follow.created"
+	// ReportResolved is published for each report a moderator resolves,
+	// about the user who filed it.
+	ReportResolved = "report.resolved"
+	// NotificationCreated is published when a user gets a notification in
+	// their inbox, so that it can be pushed to them.
+	NotificationCreated = "notification.created"
+)
+
+// An Event is something that happened. UserID is the user the event is about,
+// e.g. the author of a created chirp, and decides who may be told about it.
+type Event struct {
+	ID         uuid.UUID
+	Type       string
+	UserID     uuid.UUID
+	OccurredAt time.Time
+	Data       interface{}
+}
+
+// A Handler reacts to an event. It runs on the publisher's goroutine, so it
+// should hand anything slow off to a queue.
+type Handler func(ctx context.Context, e Event)
+
+// Bus delivers published events to every subscribed handler.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers []Handler
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe adds a handler that is called for every event published from
+// now on.
+func (b *Bus) Subscribe(h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, h)
+}
+
+// Publish fills in the event's ID and time if they are unset, then calls each
+// handler in the order they subscribed.
+func (b *Bus) Publish(ctx context.Context, e Event) Event {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	if e.OccurredAt.IsZero() {
+		e.OccurredAt = time.Now().UTC()
+	}
+	b.mu.RLock()
+	handlers := b.handlers
+	b.mu.RUnlock()
+	for _, h := range handlers {
+		h(ctx, e)
+	}
+	return e
+}
@@ -0,0 +1,42 @@
+package events
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestBusPublish(t *testing.T) {
+	bus := NewBus()
+	var got []string
+	bus.Subscribe(func(ctx context.Context, e Event) {
+		got = append(got, "first:"+e.Type)
+	})
+	bus.Subscribe(func(ctx context.Context, e Event) {
+		got = append(got, "second:"+e.Type)
+	})
+	e := bus.Publish(context.Background(), Event{Type: ChirpCreated})
+	if len(got) != 2 || got[0] != "first:chirp.created" || got[1] != "second:chirp.created" {
+		t.Errorf("TestBusPublish: expected both handlers in order, got %v", got)
+	}
+	if e.ID == uuid.Nil {
+		t.Errorf("TestBusPublish: expected an event ID to be assigned")
+	}
+	if e.OccurredAt.IsZero() {
+		t.Errorf("TestBusPublish: expected an event time to be assigned")
+	}
+}
+
+func TestBusPublishKeepsID(t *testing.T) {
+	bus := NewBus()
+	id := uuid.New()
+	var seen uuid.UUID
+	bus.Subscribe(func(ctx context.Context, e Event) {
+		seen = e.ID
+	})
+	bus.Publish(context.Background(), Event{ID: id, Type: ChirpDeleted})
+	if seen != id {
+		t.Errorf("TestBusPublishKeepsID: expected %v, got %v", id, seen)
+	}
+}
@@ -0,0 +1,156 @@
+// Package linkpreview fetches the title, description and image of web pages
+// linked from chirps, for clients to show as preview cards. Pages are
+// fetched from the server, so the fetcher refuses to connect to private
+// addresses and limits how long it waits and how much it reads.
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"time"
+
+	"github.com/lucoand/chirpy/internal/netguard"
+	"golang.org/x/net/html/charset"
+)
+
+var (
+	// ErrInvalidURL is returned for anything but absolute http and https
+	// URLs.
+	ErrInvalidURL = errors.New("linkpreview: invalid URL")
+	// ErrBlocked is returned when a URL, or a redirect it leads to, points
+	// at an address the fetcher isn't allowed to connect to.
+	ErrBlocked = netguard.ErrBlocked
+	// ErrNotHTML is returned for pages that aren't HTML.
+	ErrNotHTML = errors.New("linkpreview: page is not HTML")
+	// ErrNoPreview is returned for pages without so much as a title.
+	ErrNoPreview = errors.New("linkpreview: page has no preview")
+)
+
+// A Card is what a page says about itself.
+type Card struct {
+	Title       string
+	Description string
+	// ImageURL is absolute, or empty if the page has no image.
+	ImageURL string
+}
+
+// A Fetcher fetches the preview card of a page.
+type Fetcher interface {
+	Fetch(ctx context.Context, rawURL string) (Card, error)
+}
+
+// Options configures an HTTPFetcher. Zero fields get the defaults.
+type Options struct {
+	// Timeout bounds the whole fetch, redirects included. The default is 5
+	// seconds.
+	Timeout time.Duration
+	// MaxBytes is how much of a page is read. Cards come from the head of
+	// the page, so the rest isn't needed. The default is 512 KiB.
+	MaxBytes int64
+	// MaxRedirects defaults to 5.
+	MaxRedirects int
+	// UserAgent defaults to "Chirpy-LinkPreview/1.0".
+	UserAgent string
+	// AllowAddr decides which addresses the fetcher may connect to. It is
+	// checked for every connection, after DNS resolution, so a hostname
+	// can't be used to reach an address that is disallowed. The default is
+	// netguard.PublicAddr.
+	AllowAddr func(netip.Addr) bool
+}
+
+// HTTPFetcher fetches cards over HTTP.
+type HTTPFetcher struct {
+	client    *http.Client
+	maxBytes  int64
+	userAgent string
+}
+
+func NewHTTPFetcher(opts Options) *HTTPFetcher {
+	if opts.Timeout == 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	if opts.MaxBytes == 0 {
+		opts.MaxBytes = 512 << 10
+	}
+	if opts.MaxRedirects == 0 {
+		opts.MaxRedirects = 5
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = "Chirpy-LinkPreview/1.0"
+	}
+	dialer := &net.Dialer{
+		Timeout: opts.Timeout,
+		Control: netguard.Control(opts.AllowAddr),
+	}
+	transport := &http.Transport{
+		// Going through a proxy would hide the real destination from the
+		// dialer.
+		Proxy:                  nil,
+		DialContext:            dialer.DialContext,
+		TLSHandshakeTimeout:    opts.Timeout,
+		ResponseHeaderTimeout:  opts.Timeout,
+		MaxResponseHeaderBytes: 64 << 10,
+		MaxIdleConns:           10,
+		IdleConnTimeout:        30 * time.Second,
+	}
+	maxRedirects := opts.MaxRedirects
+	return &HTTPFetcher{
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   opts.Timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) > maxRedirects {
+					return errors.New("linkpreview: too many redirects")
+				}
+				if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+					return ErrInvalidURL
+				}
+				return nil
+			},
+		},
+		maxBytes:  opts.MaxBytes,
+		userAgent: opts.UserAgent,
+	}
+}
+
+func (f *HTTPFetcher) Fetch(ctx context.Context, rawURL string) (Card, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" || u.User != nil {
+		return Card{}, ErrInvalidURL
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return Card{}, err
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return Card{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Card{}, fmt.Errorf("linkpreview: page responded with %s", resp.Status)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || (mediaType != "text/html" && mediaType != "application/xhtml+xml") {
+		return Card{}, ErrNotHTML
+	}
+	body, err := charset.NewReader(io.LimitReader(resp.Body, f.maxBytes), contentType)
+	if err != nil {
+		return Card{}, err
+	}
+	card := parse(body, resp.Request.URL)
+	if card.Title == "" {
+		return Card{}, ErrNoPreview
+	}
+	return card, nil
+}
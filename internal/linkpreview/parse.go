@@ -0,0 +1,122 @@
+package linkpreview
+
+import (
+	"io"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	maxTitleLength       = 200
+	maxDescriptionLength = 300
+	maxImageURLLength    = 2048
+)
+
+// Meta tags in order of preference. Open Graph is the most widely used, and
+// Twitter's tags are the usual fallback.
+var (
+	titleKeys       = []string{"og:title", "twitter:title"}
+	descriptionKeys = []string{"og:description", "twitter:description", "description"}
+	imageKeys       = []string{"og:image", "og:image:url", "og:image:secure_url", "twitter:image", "twitter:image:src"}
+)
+
+// parse reads a card from the head of a page. It stops at the body, or
+// wherever the page is cut off, and uses whatever it found by then. base is
+// the page's URL, for resolving relative image URLs.
+func parse(r io.Reader, base *url.URL) Card {
+	meta := map[string]string{}
+	title := ""
+	inTitle := false
+	z := html.NewTokenizer(r)
+tokens:
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			break tokens
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch atom.Lookup(name) {
+			case atom.Body:
+				break tokens
+			case atom.Title:
+				inTitle = title == ""
+			case atom.Meta:
+				var key, content string
+				for hasAttr {
+					var k, v []byte
+					k, v, hasAttr = z.TagAttr()
+					switch string(k) {
+					case "property", "name":
+						if key == "" {
+							key = strings.ToLower(strings.TrimSpace(string(v)))
+						}
+					case "content":
+						content = string(v)
+					}
+				}
+				if _, ok := meta[key]; key != "" && !ok {
+					meta[key] = content
+				}
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch atom.Lookup(name) {
+			case atom.Head:
+				break tokens
+			case atom.Title:
+				inTitle = false
+			}
+		case html.TextToken:
+			if inTitle {
+				title += string(z.Text())
+			}
+		}
+	}
+
+	card := Card{
+		Title:       first(meta, titleKeys),
+		Description: first(meta, descriptionKeys),
+	}
+	if card.Title == "" {
+		card.Title = clean(title)
+	}
+	card.Title = truncate(card.Title, maxTitleLength)
+	card.Description = truncate(card.Description, maxDescriptionLength)
+	if image := first(meta, imageKeys); image != "" {
+		u, err := base.Parse(image)
+		if err == nil && (u.Scheme == "http" || u.Scheme == "https") && len(u.String()) <= maxImageURLLength {
+			card.ImageURL = u.String()
+		}
+	}
+	return card
+}
+
+// first returns the first of keys with a non-blank value.
+func first(meta map[string]string, keys []string) string {
+	for _, key := range keys {
+		if v := clean(meta[key]); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// clean collapses runs of whitespace, such as the newlines in a title that
+// is split across lines.
+func clean(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// truncate cuts s to at most n characters, marking the cut with an
+// ellipsis.
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	return strings.TrimSpace(string(runes[:n-1])) + "…"
+}
@@ -0,0 +1,230 @@
+// Package media checks and cleans up uploaded images. Images are decoded and
+// encoded again, which drops metadata such as EXIF (including GPS
+// coordinates) and anything that isn't image data, and a thumbnail is made
+// for each one.
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"golang.org/x/image/draw"
+)
+
+var (
+	// ErrUnsupported is returned for files that aren't a supported image
+	// type.
+	ErrUnsupported = errors.New("unsupported image type")
+	// ErrInvalid is returned for files that look like a supported image
+	// but can't be decoded.
+	ErrInvalid = errors.New("invalid image")
+	// ErrTooLarge is returned for images with too many pixels.
+	ErrTooLarge = errors.New("image is too large")
+)
+
+// Supported image types.
+const (
+	TypeJPEG = "image/jpeg"
+	TypePNG  = "image/png"
+	TypeGIF  = "image/gif"
+)
+
+// Limits bound the images Process accepts and the thumbnails it makes.
+type Limits struct {
+	// MaxPixels bounds width * height. Images are checked before they are
+	// decoded, so a small file can't claim enormous dimensions and use up
+	// the server's memory.
+	MaxPixels int
+	// ThumbnailSize is the largest width and height of a thumbnail.
+	ThumbnailSize int
+}
+
+// An Image is an encoded image and its dimensions.
+type Image struct {
+	Data        []byte
+	ContentType string
+	Width       int
+	Height      int
+}
+
+// Processed is an uploaded image after Process has cleaned it up.
+type Processed struct {
+	Original  Image
+	Thumbnail Image
+}
+
+// Extension is the file extension for a supported content type.
+func Extension(contentType string) string {
+	switch contentType {
+	case TypeJPEG:
+		return ".jpg"
+	case TypePNG:
+		return ".png"
+	case TypeGIF:
+		return ".gif"
+	}
+	return ""
+}
+
+// Process works out what kind of image data is from its contents, ignoring
+// whatever the uploader claimed, and re-encodes it without metadata. JPEGs
+// are rotated to match their EXIF orientation first, since the tag that
+// says how to rotate them is removed. Animated GIFs keep their frames.
+func Process(data []byte, limits Limits) (Processed, error) {
+	contentType := http.DetectContentType(data)
+	if Extension(contentType) == "" {
+		return Processed{}, ErrUnsupported
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Processed{}, ErrInvalid
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return Processed{}, ErrInvalid
+	}
+	if cfg.Width > limits.MaxPixels/cfg.Height {
+		return Processed{}, ErrTooLarge
+	}
+
+	var original Image
+	var still image.Image
+	switch contentType {
+	case TypeJPEG:
+		original, still, err = processJPEG(data)
+	case TypePNG:
+		original, still, err = processPNG(data)
+	case TypeGIF:
+		original, still, err = processGIF(data)
+	}
+	if err != nil {
+		return Processed{}, err
+	}
+	thumbnail, err := makeThumbnail(still, contentType, limits.ThumbnailSize)
+	if err != nil {
+		return Processed{}, err
+	}
+	return Processed{Original: original, Thumbnail: thumbnail}, nil
+}
+
+func processJPEG(data []byte) (Image, image.Image, error) {
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, nil, ErrInvalid
+	}
+	img = orient(img, jpegOrientation(data))
+	encoded, err := encode(img, TypeJPEG)
+	if err != nil {
+		return Image{}, nil, err
+	}
+	return encoded, img, nil
+}
+
+func processPNG(data []byte) (Image, image.Image, error) {
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, nil, ErrInvalid
+	}
+	encoded, err := encode(img, TypePNG)
+	if err != nil {
+		return Image{}, nil, err
+	}
+	return encoded, img, nil
+}
+
+// processGIF keeps the frames, timing and looping of an animation, and
+// drops comments and application extensions other than looping. The
+// thumbnail is made from the first frame.
+func processGIF(data []byte) (Image, image.Image, error) {
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil || len(g.Image) == 0 {
+		return Image{}, nil, ErrInvalid
+	}
+	cleaned := &gif.GIF{
+		Image:           g.Image,
+		Delay:           g.Delay,
+		LoopCount:       g.LoopCount,
+		Disposal:        g.Disposal,
+		Config:          g.Config,
+		BackgroundIndex: g.BackgroundIndex,
+	}
+	var buf bytes.Buffer
+	err = gif.EncodeAll(&buf, cleaned)
+	if err != nil {
+		return Image{}, nil, err
+	}
+	original := Image{
+		Data:        buf.Bytes(),
+		ContentType: TypeGIF,
+		Width:       g.Config.Width,
+		Height:      g.Config.Height,
+	}
+	// The first frame may be smaller than the canvas; draw it in place.
+	first := image.NewRGBA(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+	draw.Draw(first, first.Bounds(), image.Transparent, image.Point{}, draw.Src)
+	draw.Draw(first, g.Image[0].Bounds(), g.Image[0], g.Image[0].Bounds().Min, draw.Over)
+	return original, first, nil
+}
+
+// makeThumbnail scales img to fit in a size by size square, keeping its
+// aspect ratio. Images that already fit aren't enlarged. GIF thumbnails are
+// still PNGs, so a thumbnail never animates.
+func makeThumbnail(img image.Image, contentType string, size int) (Image, error) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > size || h > size {
+		if w >= h {
+			h = max(1, h*size/w)
+			w = size
+		} else {
+			w = max(1, w*size/h)
+			h = size
+		}
+	}
+	thumb := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(thumb, thumb.Bounds(), img, b, draw.Src, nil)
+	if contentType == TypeGIF {
+		contentType = TypePNG
+	}
+	return encode(thumb, contentType)
+}
+
+func encode(img image.Image, contentType string) (Image, error) {
+	var buf bytes.Buffer
+	var err error
+	switch contentType {
+	case TypeJPEG:
+		err = jpeg.Encode(&buf, opaque(img), &jpeg.Options{Quality: 90})
+	case TypePNG:
+		err = png.Encode(&buf, img)
+	default:
+		return Image{}, ErrUnsupported
+	}
+	if err != nil {
+		return Image{}, err
+	}
+	return Image{
+		Data:        buf.Bytes(),
+		ContentType: contentType,
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+	}, nil
+}
+
+// opaque flattens img onto white. JPEGs have no transparency, and the
+// encoder would otherwise turn transparent pixels black.
+func opaque(img image.Image) image.Image {
+	if _, ok := img.(*image.RGBA); !ok {
+		return img
+	}
+	b := img.Bounds()
+	flat := image.NewRGBA(b)
+	draw.Draw(flat, b, image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, b, img, b.Min, draw.Over)
+	return flat
+}
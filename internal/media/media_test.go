@@ -0,0 +1,194 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+var testLimits = Limits{MaxPixels: 4000 * 4000, ThumbnailSize: 320}
+
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	return img
+}
+
+// withEXIF inserts an EXIF segment with the given orientation and a camera
+// model into a JPEG, right after its start marker.
+func withEXIF(jpg []byte, orientation uint16) []byte {
+	model := "SecretCam\x00"
+	tiff := []byte{'M', 'M', 0, 42, 0, 0, 0, 8,
+		0, 2, // two entries
+		0x01, 0x12, 0, 3, 0, 0, 0, 1, byte(orientation >> 8), byte(orientation), 0, 0,
+		0x01, 0x10, 0, 2, 0, 0, 0, byte(len(model)), 0, 0, 0, 38,
+		0, 0, 0, 0, // no next IFD
+	}
+	tiff = append(tiff, model...)
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	length := len(segment) + 2
+	out := append([]byte{}, jpg[:2]...)
+	out = append(out, 0xFF, 0xE1, byte(length>>8), byte(length))
+	out = append(out, segment...)
+	return append(out, jpg[2:]...)
+}
+
+func TestProcessPNG(t *testing.T) {
+	var buf bytes.Buffer
+	png.Encode(&buf, testImage(800, 400))
+	// Content type comes from the data, not the extension or a header.
+	p, err := Process(buf.Bytes(), testLimits)
+	if err != nil {
+		t.Fatalf("TestProcessPNG: unexpected error: %s", err)
+	}
+	if p.Original.ContentType != TypePNG || p.Original.Width != 800 || p.Original.Height != 400 {
+		t.Errorf("TestProcessPNG: unexpected original %s %dx%d", p.Original.ContentType, p.Original.Width, p.Original.Height)
+	}
+	if p.Thumbnail.ContentType != TypePNG || p.Thumbnail.Width != 320 || p.Thumbnail.Height != 160 {
+		t.Errorf("TestProcessPNG: unexpected thumbnail %s %dx%d", p.Thumbnail.ContentType, p.Thumbnail.Width, p.Thumbnail.Height)
+	}
+	cfg, err := png.DecodeConfig(bytes.NewReader(p.Thumbnail.Data))
+	if err != nil || cfg.Width != 320 || cfg.Height != 160 {
+		t.Errorf("TestProcessPNG: thumbnail data doesn't match, got %v, %v", cfg, err)
+	}
+}
+
+func TestProcessSmallImage(t *testing.T) {
+	var buf bytes.Buffer
+	png.Encode(&buf, testImage(40, 100))
+	p, err := Process(buf.Bytes(), testLimits)
+	if err != nil {
+		t.Fatalf("TestProcessSmallImage: unexpected error: %s", err)
+	}
+	if p.Thumbnail.Width != 40 || p.Thumbnail.Height != 100 {
+		t.Errorf("TestProcessSmallImage: expected the thumbnail not to be enlarged, got %dx%d", p.Thumbnail.Width, p.Thumbnail.Height)
+	}
+}
+
+func TestProcessJPEGStripsEXIF(t *testing.T) {
+	var buf bytes.Buffer
+	img := testImage(60, 20)
+	// Mark the top left corner so the rotation can be checked.
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			img.Set(x, y, color.RGBA{255, 0, 0, 255})
+		}
+	}
+	jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100})
+	data := withEXIF(buf.Bytes(), 6)
+	if jpegOrientation(data) != 6 {
+		t.Fatalf("TestProcessJPEGStripsEXIF: expected orientation 6, got %d", jpegOrientation(data))
+	}
+
+	p, err := Process(data, testLimits)
+	if err != nil {
+		t.Fatalf("TestProcessJPEGStripsEXIF: unexpected error: %s", err)
+	}
+	if bytes.Contains(p.Original.Data, []byte("Exif")) || bytes.Contains(p.Original.Data, []byte("SecretCam")) {
+		t.Errorf("TestProcessJPEGStripsEXIF: expected EXIF data to be removed")
+	}
+	if p.Original.ContentType != TypeJPEG || p.Original.Width != 20 || p.Original.Height != 60 {
+		t.Errorf("TestProcessJPEGStripsEXIF: expected an upright 20x60 JPEG, got %s %dx%d", p.Original.ContentType, p.Original.Width, p.Original.Height)
+	}
+	out, err := jpeg.Decode(bytes.NewReader(p.Original.Data))
+	if err != nil {
+		t.Fatalf("TestProcessJPEGStripsEXIF: unexpected error decoding result: %s", err)
+	}
+	// Turned right, the top left corner ends up at the top right.
+	r, g, _, _ := out.At(17, 2).RGBA()
+	if r < 0xc000 || g > 0x4000 {
+		t.Errorf("TestProcessJPEGStripsEXIF: expected the marked corner at the top right")
+	}
+}
+
+func TestProcessGIF(t *testing.T) {
+	palette := color.Palette{color.Black, color.White}
+	g := &gif.GIF{
+		Image: []*image.Paletted{
+			image.NewPaletted(image.Rect(0, 0, 30, 30), palette),
+			image.NewPaletted(image.Rect(0, 0, 30, 30), palette),
+		},
+		Delay: []int{10, 20},
+	}
+	var buf bytes.Buffer
+	gif.EncodeAll(&buf, g)
+	p, err := Process(buf.Bytes(), testLimits)
+	if err != nil {
+		t.Fatalf("TestProcessGIF: unexpected error: %s", err)
+	}
+	out, err := gif.DecodeAll(bytes.NewReader(p.Original.Data))
+	if err != nil || len(out.Image) != 2 || out.Delay[1] != 20 {
+		t.Errorf("TestProcessGIF: expected the animation to be kept, got %v", err)
+	}
+	if p.Thumbnail.ContentType != TypePNG {
+		t.Errorf("TestProcessGIF: expected a PNG thumbnail, got %s", p.Thumbnail.ContentType)
+	}
+}
+
+func TestProcessRejects(t *testing.T) {
+	var big bytes.Buffer
+	png.Encode(&big, image.NewGray(image.Rect(0, 0, 3000, 3000)))
+	var small bytes.Buffer
+	png.Encode(&small, testImage(100, 100))
+	truncated := small.Bytes()[:small.Len()/2]
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"text", []byte("definitely not an image"), ErrUnsupported},
+		{"html", []byte("<html><body>hi</body></html>"), ErrUnsupported},
+		{"truncated", truncated, ErrInvalid},
+		{"too many pixels", big.Bytes(), ErrTooLarge},
+	}
+	limits := Limits{MaxPixels: 1000 * 1000, ThumbnailSize: 320}
+	for _, tc := range tests {
+		_, err := Process(tc.data, limits)
+		if !errors.Is(err, tc.want) {
+			t.Errorf("TestProcessRejects: %s: expected %v, got %v", tc.name, tc.want, err)
+		}
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// A 2x1 image: red on the left, blue on the right.
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.RGBA{255, 0, 0, 255})
+	img.Set(1, 0, color.RGBA{0, 0, 255, 255})
+	tests := []struct {
+		orientation int
+		w, h        int
+		redX, redY  int
+	}{
+		{1, 2, 1, 0, 0},
+		{2, 2, 1, 1, 0},
+		{3, 2, 1, 1, 0},
+		{4, 2, 1, 0, 0},
+		{5, 1, 2, 0, 0},
+		{6, 1, 2, 0, 0},
+		{7, 1, 2, 0, 1},
+		{8, 1, 2, 0, 1},
+	}
+	for _, tc := range tests {
+		out := orient(img, tc.orientation)
+		b := out.Bounds()
+		if b.Dx() != tc.w || b.Dy() != tc.h {
+			t.Errorf("TestOrient: orientation %d: expected %dx%d, got %dx%d", tc.orientation, tc.w, tc.h, b.Dx(), b.Dy())
+			continue
+		}
+		r, _, _, _ := out.At(tc.redX, tc.redY).RGBA()
+		if r != 0xffff {
+			t.Errorf("TestOrient: orientation %d: expected red at (%d, %d)", tc.orientation, tc.redX, tc.redY)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// Package netguard keeps requests the server makes on behalf of users, such
+// as link previews and outbound webhooks, from reaching the server's own
+// network.
+package netguard
+
+import (
+	"errors"
+	"net/netip"
+	"syscall"
+)
+
+// ErrBlocked is returned when a connection is refused because of the
+// address it would go to.
+var ErrBlocked = errors.New("netguard: address is not allowed")
+
+// PublicAddr reports whether addr is a public unicast address: not
+// loopback, private, link-local, multicast or set aside for any other
+// special use.
+func PublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, p := range specialPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// specialPrefixes are the special-use ranges IsGlobalUnicast and IsPrivate
+// don't already cover.
+var specialPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("100::/64"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// Control returns a net.Dialer Control hook that refuses connections to
+// addresses allow rejects. It runs for every connection, after DNS
+// resolution, so a hostname can't be used to reach an address that is
+// disallowed. A nil allow means PublicAddr.
+func Control(allow func(netip.Addr) bool) func(network, address string, c syscall.RawConn) error {
+	if allow == nil {
+		allow = PublicAddr
+	}
+	return func(network, address string, _ syscall.RawConn) error {
+		addr, err := netip.ParseAddrPort(address)
+		if err != nil {
+			return err
+		}
+		if !allow(addr.Addr().Unmap()) {
+			return ErrBlocked
+		}
+		return nil
+	}
+}
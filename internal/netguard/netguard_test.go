@@ -0,0 +1,67 @@
+package netguard
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+)
+
+func TestPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"0.1.2.3", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"198.18.0.1", false},
+		{"::1", false},
+		{"::", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"ff02::1", false},
+		{"::ffff:10.0.0.1", false},
+		{"::ffff:93.184.216.34", true},
+		{"2001:db8::1", false},
+	}
+	for _, tt := range tests {
+		got := PublicAddr(netip.MustParseAddr(tt.addr))
+		if got != tt.want {
+			t.Errorf("TestPublicAddr %s: expected %t, got %t", tt.addr, tt.want, got)
+		}
+	}
+}
+
+func TestControl(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("TestControl: %s", err)
+	}
+	defer l.Close()
+
+	dialer := &net.Dialer{Control: Control(nil)}
+	_, err = dialer.Dial("tcp", l.Addr().String())
+	if !errors.Is(err, ErrBlocked) {
+		t.Errorf("TestControl: expected ErrBlocked, got %v", err)
+	}
+
+	allowLoopback := func(addr netip.Addr) bool {
+		return addr.IsLoopback()
+	}
+	dialer = &net.Dialer{Control: Control(allowLoopback)}
+	conn, err := dialer.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("TestControl: expected loopback to be allowed, got %v", err)
+	}
+	conn.Close()
+}
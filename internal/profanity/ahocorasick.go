@@ -0,0 +1,121 @@
+package profanity
+
+// automaton is an Aho-Corasick automaton over folded runes. Matching runs in
+// time linear in the length of the text plus the number of matches.
+type automaton struct {
+	nodes []node
+}
+
+type node struct {
+	children map[rune]int
+	fail     int
+	// dict is the nearest node on the failure chain that ends a pattern,
+	// or -1.
+	dict int
+	// depth is the number of runes from the root.
+	depth int
+	// modes is a bitmask of the modes of the patterns ending here; zero if
+	// no pattern ends here.
+	modes modeSet
+}
+
+type modeSet uint8
+
+const (
+	censorBit modeSet = 1 << iota
+	rejectBit
+	flagBit
+)
+
+func (m Mode) bit() modeSet {
+	switch m {
+	case ModeCensor:
+		return censorBit
+	case ModeReject:
+		return rejectBit
+	case ModeFlag:
+		return flagBit
+	}
+	return 0
+}
+
+func newAutomaton() *automaton {
+	return &automaton{nodes: []node{{children: map[rune]int{}, dict: -1}}}
+}
+
+func (a *automaton) add(pattern []rune, mode Mode) {
+	cur := 0
+	for _, r := range pattern {
+		next, ok := a.nodes[cur].children[r]
+		if !ok {
+			next = len(a.nodes)
+			a.nodes = append(a.nodes, node{
+				children: map[rune]int{},
+				dict:     -1,
+				depth:    a.nodes[cur].depth + 1,
+			})
+			a.nodes[cur].children[r] = next
+		}
+		cur = next
+	}
+	a.nodes[cur].modes |= mode.bit()
+}
+
+// build computes the failure and dictionary links breadth first. It must be
+// called once after every pattern has been added.
+func (a *automaton) build() {
+	queue := []int{}
+	for _, child := range a.nodes[0].children {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for r, child := range a.nodes[cur].children {
+			fail := a.nodes[cur].fail
+			for {
+				if next, ok := a.nodes[fail].children[r]; ok && next != child {
+					fail = next
+					break
+				}
+				if fail == 0 {
+					break
+				}
+				fail = a.nodes[fail].fail
+			}
+			a.nodes[child].fail = fail
+			if a.nodes[fail].modes != 0 {
+				a.nodes[child].dict = fail
+			} else {
+				a.nodes[child].dict = a.nodes[fail].dict
+			}
+			queue = append(queue, child)
+		}
+	}
+}
+
+func (a *automaton) step(state int, r rune) int {
+	for {
+		if next, ok := a.nodes[state].children[r]; ok {
+			return next
+		}
+		if state == 0 {
+			return 0
+		}
+		state = a.nodes[state].fail
+	}
+}
+
+// match reports every pattern occurrence in text by calling found with the
+// index of its first and last rune and the modes of the pattern.
+func (a *automaton) match(text []rune, found func(first, last int, modes modeSet)) {
+	state := 0
+	for i, r := range text {
+		state = a.step(state, r)
+		for n := state; n > 0; n = a.nodes[n].dict {
+			if a.nodes[n].modes != 0 {
+				found(i-a.nodes[n].depth+1, i, a.nodes[n].modes)
+			}
+		}
+	}
+}
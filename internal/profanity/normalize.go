@@ -0,0 +1,149 @@
+package profanity
+
+import (
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// boundary stands in for any run of whitespace once text has been folded.
+const boundary = ' '
+
+// separator stands in for punctuation and symbols until collapse decides
+// whether each run of them splits two words or, between single letters as
+// in "k.e.r.f", is ignored.
+const separator = '.'
+
+// confusables maps look-alike characters from other scripts, and a few
+// stylised Latin letters that compatibility decomposition leaves alone, to
+// the plain Latin letter they imitate. Keys are lowercase.
+var confusables = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'с': 'c', 'ԁ': 'd', 'е': 'e', 'ё': 'e', 'һ': 'h',
+	'н': 'h', 'і': 'i', 'ї': 'i', 'ј': 'j', 'к': 'k', 'м': 'm', 'о': 'o',
+	'р': 'p', 'ԛ': 'q', 'г': 'r', 'ѕ': 's', 'т': 't', 'ц': 'u', 'ѵ': 'v',
+	'ѡ': 'w', 'х': 'x', 'у': 'y', 'ү': 'y',
+	// Greek
+	'α': 'a', 'β': 'b', 'ϲ': 'c', 'ε': 'e', 'η': 'n', 'ι': 'i', 'κ': 'k',
+	'ν': 'v', 'ο': 'o', 'ρ': 'p', 'τ': 't', 'υ': 'u', 'χ': 'x', 'ω': 'w',
+	'ς': 's',
+	// Latin
+	'ı': 'i', 'ȷ': 'j', 'ø': 'o', 'đ': 'd', 'ħ': 'h', 'ł': 'l', 'ƅ': 'b',
+	'ɑ': 'a', 'ɡ': 'g', 'ʀ': 'r', 'ꜱ': 's', 'ᴄ': 'c', 'ᴏ': 'o', 'ᴜ': 'u',
+	'ᴠ': 'v', 'ᴡ': 'w', 'ᴢ': 'z',
+}
+
+// leetspeak maps digits and symbols commonly used in place of letters.
+// '!' is left out on purpose: it ends far more words than it replaces.
+var leetspeak = map[rune]rune{
+	'0': 'o',
+	'1': 'l',
+	'3': 'e',
+	'4': 'a',
+	'5': 's',
+	'7': 't',
+	'8': 'b',
+	'9': 'g',
+	'@': 'a',
+	'$': 's',
+}
+
+// foldRune appends the folded form of r to dst. Whitespace folds to
+// boundary, letters and digits fold to a canonical lowercase Latin letter
+// where one exists, punctuation and symbols fold to separator, and
+// everything else (combining marks and invisible formatting characters) is
+// dropped.
+func foldRune(dst []rune, r rune) []rune {
+	if unicode.IsSpace(r) {
+		return append(dst, boundary)
+	}
+	if r < unicode.MaxASCII {
+		return appendFolded(dst, r)
+	}
+	for _, d := range norm.NFKD.String(string(r)) {
+		if unicode.IsSpace(d) {
+			dst = append(dst, boundary)
+			continue
+		}
+		dst = appendFolded(dst, d)
+	}
+	return dst
+}
+
+func appendFolded(dst []rune, r rune) []rune {
+	if l, ok := leetspeak[r]; ok {
+		r = l
+	}
+	r = unicode.ToLower(r)
+	if c, ok := confusables[r]; ok {
+		r = c
+	}
+	// i and l are indistinguishable in too many fonts (and both stand in
+	// for 1), so they share a class.
+	if r == 'l' {
+		r = 'i'
+	}
+	if unicode.IsLetter(r) || unicode.IsDigit(r) {
+		return append(dst, r)
+	}
+	if unicode.IsPunct(r) || unicode.IsSymbol(r) {
+		return append(dst, separator)
+	}
+	return dst
+}
+
+// collapse turns each run of separators into a boundary, unless it sits
+// between two single letters, and then collapses runs of boundaries. So
+// "a.kerfuffle" is two words, but "k-e-r-f-u-f-f-l-e" is one.
+func collapse(symbols []symbol) []symbol {
+	out := make([]symbol, 0, len(symbols))
+	for i := 0; i < len(symbols); {
+		s := symbols[i]
+		if s.r != separator {
+			if s.r != boundary || (len(out) > 0 && out[len(out)-1].r != boundary) {
+				out = append(out, s)
+			}
+			i++
+			continue
+		}
+		j := i
+		for j < len(symbols) && symbols[j].r == separator {
+			j++
+		}
+		spelledOut := singleLetter(symbols, i-1, -1) && singleLetter(symbols, j, 1)
+		if !spelledOut && len(out) > 0 && out[len(out)-1].r != boundary {
+			out = append(out, symbol{r: boundary, start: s.start, end: symbols[j-1].end})
+		}
+		i = j
+	}
+	return out
+}
+
+// singleLetter reports whether symbols[i] is a letter with no other letter
+// next to it on the side dir points away from the separator.
+func singleLetter(symbols []symbol, i int, dir int) bool {
+	if i < 0 || i >= len(symbols) || isBreak(symbols[i].r) {
+		return false
+	}
+	next := i + dir
+	return next < 0 || next >= len(symbols) || isBreak(symbols[next].r)
+}
+
+func isBreak(r rune) bool {
+	return r == boundary || r == separator
+}
+
+// Normalize folds s the same way the filter folds text before matching, with
+// runs of whitespace collapsed and trimmed. It is useful for checking that a
+// rule would match anything at all.
+func Normalize(s string) string {
+	symbols := fold(s)
+	out := make([]rune, len(symbols))
+	for i, sym := range symbols {
+		out[i] = sym.r
+	}
+	if len(out) > 0 && out[len(out)-1] == boundary {
+		out = out[:len(out)-1]
+	}
+	return string(out)
+}
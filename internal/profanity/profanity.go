@@ -0,0 +1,147 @@
+package profanity
+
+import (
+	"strings"
+)
+
+// Censor replaces any word matched by a censor rule.
+const Censor = "****"
+
+type Mode string
+
+const (
+	ModeCensor Mode = "censor"
+	ModeReject Mode = "reject"
+	ModeFlag   Mode = "flag"
+)
+
+func (m Mode) Valid() bool {
+	return m == ModeCensor || m == ModeReject || m == ModeFlag
+}
+
+// Rule is a word or phrase and what to do when it appears.
+type Rule struct {
+	Word string
+	Mode Mode
+}
+
+// Result describes what a Filter did to a body of text.
+type Result struct {
+	// Body is the text with every censored word replaced by Censor.
+	Body string
+	// Rejected is true if the text matched a reject rule.
+	Rejected bool
+	// Flagged is true if the text matched a flag rule and should be
+	// reviewed by a moderator.
+	Flagged bool
+}
+
+// Filter matches rules against text after folding both: Unicode
+// compatibility forms, accents, case, look-alike letters from other scripts
+// and leetspeak are all reduced to plain lowercase Latin, and punctuation
+// separates words like whitespace does, except between single letters. A
+// rule only matches whole words, so "kerfuffle" matches "Kerfuffle!",
+// "a.kerfuffle" and "k-e-r-f-u-f-f-l-e" but not "kerfuffles".
+type Filter struct {
+	patterns *automaton
+}
+
+func NewFilter(rules []Rule) *Filter {
+	a := newAutomaton()
+	for _, rule := range rules {
+		pattern := []rune(Normalize(rule.Word))
+		if len(pattern) == 0 {
+			continue
+		}
+		a.add(pattern, rule.Mode)
+	}
+	a.build()
+	return &Filter{patterns: a}
+}
+
+// symbol is a folded rune and the bytes of the original text it came from.
+type symbol struct {
+	r     rune
+	start int
+	end   int
+}
+
+// fold converts text into the sequence of symbols the automaton runs over,
+// with each run of whitespace and punctuation collapsed into a single
+// boundary.
+func fold(text string) []symbol {
+	symbols := make([]symbol, 0, len(text))
+	var buf []rune
+	for i, r := range text {
+		end := i + len(string(r))
+		buf = foldRune(buf[:0], r)
+		for _, f := range buf {
+			symbols = append(symbols, symbol{r: f, start: i, end: end})
+		}
+	}
+	return collapse(symbols)
+}
+
+type span struct {
+	start int
+	end   int
+}
+
+func (f *Filter) Apply(body string) Result {
+	result := Result{}
+	symbols := fold(body)
+	text := make([]rune, len(symbols))
+	for i, s := range symbols {
+		text[i] = s.r
+	}
+
+	censored := []span{}
+	f.patterns.match(text, func(first, last int, modes modeSet) {
+		// only whole words count
+		if first > 0 && text[first-1] != boundary {
+			return
+		}
+		if last < len(text)-1 && text[last+1] != boundary {
+			return
+		}
+		if modes&rejectBit != 0 {
+			result.Rejected = true
+		}
+		if modes&flagBit != 0 {
+			result.Flagged = true
+		}
+		if modes&censorBit != 0 {
+			censored = append(censored, span{start: symbols[first].start, end: symbols[last].end})
+		}
+	})
+	result.Body = censor(body, censored)
+	return result
+}
+
+// censor replaces each span of body with Censor. Spans are reported in order
+// of where they end, so they are merged as they go. Everything outside the
+// spans is copied unchanged.
+func censor(body string, spans []span) string {
+	if len(spans) == 0 {
+		return body
+	}
+	merged := []span{}
+	for _, s := range spans {
+		for len(merged) > 0 && s.start < merged[len(merged)-1].end {
+			if merged[len(merged)-1].start < s.start {
+				s.start = merged[len(merged)-1].start
+			}
+			merged = merged[:len(merged)-1]
+		}
+		merged = append(merged, s)
+	}
+	var b strings.Builder
+	prev := 0
+	for _, s := range merged {
+		b.WriteString(body[prev:s.start])
+		b.WriteString(Censor)
+		prev = s.end
+	}
+	b.WriteString(body[prev:])
+	return b.String()
+}
@@ -0,0 +1,133 @@
+package profanity
+
+import (
+	"testing"
+)
+
+var testRules = []Rule{
+	{Word: "kerfuffle", Mode: ModeCensor},
+	{Word: "sharbert", Mode: ModeReject},
+	{Word: "fornax", Mode: ModeFlag},
+}
+
+func TestApplyCensor(t *testing.T) {
+	f := NewFilter(testRules)
+	result := f.Apply("What a Kerfuffle this is\nkerfuffle")
+	expected := "What a **** this is\n****"
+	if result.Body != expected {
+		t.Errorf("TestApplyCensor: expected %q but got %q", expected, result.Body)
+	}
+	if result.Rejected || result.Flagged {
+		t.Errorf("TestApplyCensor: censor rule should not reject or flag")
+	}
+}
+
+func TestApplyReject(t *testing.T) {
+	f := NewFilter(testRules)
+	result := f.Apply("I had a sharbert")
+	if !result.Rejected {
+		t.Errorf("TestApplyReject: text with a reject rule was not rejected")
+	}
+}
+
+func TestApplyFlag(t *testing.T) {
+	f := NewFilter(testRules)
+	result := f.Apply("fornax is a constellation")
+	if !result.Flagged {
+		t.Errorf("TestApplyFlag: text with a flag rule was not flagged")
+	}
+	if result.Body != "fornax is a constellation" {
+		t.Errorf("TestApplyFlag: flag rule should not change the text, got %q", result.Body)
+	}
+}
+
+func TestApplyClean(t *testing.T) {
+	f := NewFilter(testRules)
+	body := "nothing to see here"
+	result := f.Apply(body)
+	if result.Body != body || result.Rejected || result.Flagged {
+		t.Errorf("TestApplyClean: clean text was changed: %+v", result)
+	}
+}
+
+func TestApplyEvasions(t *testing.T) {
+	f := NewFilter(testRules)
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{"Kerfuffle!", "****!"},
+		{"what a kerfuffle, really", "what a ****, really"},
+		{"k-e-r-f-u-f-f-l-e", "****"},
+		{"k.e.r.f.u.f.f.l.e.", "****."},
+		{"one\tkerfuffle\ttwo", "one\t****\ttwo"},
+		{"KERFUFFLE", "****"},
+		{"k3rfuffl3", "****"},
+		{"kerfuff1e", "****"},
+		{"kérfüfflé", "****"},
+		{"ｋｅｒｆｕｆｆｌｅ", "****"},
+		{"k\u0435rfuffle", "****"},  // Cyrillic ie
+		{"ker\u200bfuffle", "****"}, // zero width space
+		{"(kerfuffle)", "(****)"},
+		{"kerfuffles", "kerfuffles"},
+		{"kerfufflekerfuffle", "kerfufflekerfuffle"},
+		{"  kerfuffle  \n\n kerfuffle", "  ****  \n\n ****"},
+		{"a.kerfuffle", "a.****"},
+		{"x,kerfuffle", "x,****"},
+		{"kerfuffle.x", "****.x"},
+		{"see:kerfuffle", "see:****"},
+		{"ker-fuffle", "ker-fuffle"},
+	}
+	for _, c := range cases {
+		result := f.Apply(c.input)
+		if result.Body != c.expected {
+			t.Errorf("TestApplyEvasions: Apply(%q) = %q, expected %q", c.input, result.Body, c.expected)
+		}
+	}
+}
+
+func TestApplyPhrase(t *testing.T) {
+	f := NewFilter([]Rule{
+		{Word: "big  kerfuffle", Mode: ModeCensor},
+		{Word: "kerfuffle", Mode: ModeFlag},
+	})
+	result := f.Apply("a BIG\tkerfuffle today")
+	if result.Body != "a **** today" {
+		t.Errorf("TestApplyPhrase: expected the phrase to be censored, got %q", result.Body)
+	}
+	if !result.Flagged {
+		t.Errorf("TestApplyPhrase: overlapping flag rule should still flag")
+	}
+	result = f.Apply("big kerfuffles")
+	if result.Body != "big kerfuffles" || result.Flagged {
+		t.Errorf("TestApplyPhrase: phrase should only match on word boundaries, got %+v", result)
+	}
+}
+
+func TestApplyOverlapping(t *testing.T) {
+	f := NewFilter([]Rule{
+		{Word: "fornax sharbert", Mode: ModeCensor},
+		{Word: "sharbert kerfuffle", Mode: ModeCensor},
+	})
+	result := f.Apply("fornax sharbert kerfuffle!")
+	if result.Body != "****!" {
+		t.Errorf("TestApplyOverlapping: overlapping matches should merge, got %q", result.Body)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	cases := map[string]string{
+		"Kerfuffle":      "kerfuffie",
+		"  Big \t Word ": "big word",
+		"!!!":            "",
+		"$h@rb3rt":       "sharbert",
+		"big-word":       "big word",
+		"b.i.g":          "big",
+		"a.big":          "a big",
+	}
+	for input, expected := range cases {
+		if got := Normalize(input); got != expected {
+			t.Errorf("TestNormalize: Normalize(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package realtime
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/coder/websocket"
+)
+
+// Client is a connection to a realtime server. It is not safe for
+// concurrent use.
+type Client struct {
+	ws      *websocket.Conn
+	nextID  int
+	pending []Message
+}
+
+// Dial connects to a realtime server at url (ws:// or wss://) and
+// authenticates with token. It returns the server's ready message.
+func Dial(ctx context.Context, url string, token string) (*Client, Message, error) {
+	ws, _, err := websocket.Dial(ctx, url, nil)
+	if err != nil {
+		return nil, Message{}, err
+	}
+	ws.SetReadLimit(maxMessageBytes)
+	c := &Client{ws: ws}
+	ready, err := c.request(ctx, Message{Type: TypeAuth, Token: token})
+	if err != nil {
+		ws.CloseNow()
+		return nil, Message{}, err
+	}
+	return c, ready, nil
+}
+
+// Subscribe subscribes to a channel.
+func (c *Client) Subscribe(ctx context.Context, channel string) error {
+	_, err := c.request(ctx, Message{Type: TypeSubscribe, Channel: channel})
+	return err
+}
+
+// Unsubscribe unsubscribes from a channel.
+func (c *Client) Unsubscribe(ctx context.Context, channel string) error {
+	_, err := c.request(ctx, Message{Type: TypeUnsubscribe, Channel: channel})
+	return err
+}
+
+// Ping checks that the server is responding.
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.request(ctx, Message{Type: TypePing})
+	return err
+}
+
+// Reauthenticate replaces the connection's token, typically after
+// token_expiring. The token must be for the same user.
+func (c *Client) Reauthenticate(ctx context.Context, token string) (Message, error) {
+	return c.request(ctx, Message{Type: TypeAuth, Token: token})
+}
+
+// Next returns the next event, token_expiring or unsolicited error message.
+// As with the other methods, the connection is closed if ctx is done first.
+func (c *Client) Next(ctx context.Context) (Message, error) {
+	if len(c.pending) > 0 {
+		m := c.pending[0]
+		c.pending = c.pending[1:]
+		return m, nil
+	}
+	return readMessage(ctx, c.ws)
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.ws.Close(websocket.StatusNormalClosure, "")
+}
+
+// request sends a message and waits for the reply with the same ID. Anything
+// that arrives meanwhile is kept for Next.
+func (c *Client) request(ctx context.Context, m Message) (Message, error) {
+	c.nextID++
+	m.ID = strconv.Itoa(c.nextID)
+	err := writeMessage(ctx, c.ws, m)
+	if err != nil {
+		return Message{}, err
+	}
+	for {
+		reply, err := readMessage(ctx, c.ws)
+		if err != nil {
+			return Message{}, err
+		}
+		if reply.ID != m.ID {
+			c.pending = append(c.pending, reply)
+			continue
+		}
+		if reply.Type == TypeError {
+			return Message{}, fmt.Errorf("%s: %s", m.Type, reply.Error)
+		}
+		return reply, nil
+	}
+}
@@ -0,0 +1,79 @@
+// Package realtime implements Chirpy's WebSocket protocol, described in
+// docs/websocket.md, and a Go client for it.
+package realtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/google/uuid"
+)
+
+// Message types. Every message in either direction is a JSON object with a
+// type; requests may carry an id, which the server echoes in its reply.
+const (
+	TypeAuth          = "auth"
+	TypeReady         = "ready"
+	TypeSubscribe     = "subscribe"
+	TypeSubscribed    = "subscribed"
+	TypeUnsubscribe   = "unsubscribe"
+	TypeUnsubscribed  = "unsubscribed"
+	TypePing          = "ping"
+	TypePong          = "pong"
+	TypeEvent         = "event"
+	TypeError         = "error"
+	TypeTokenExpiring = "token_expiring"
+)
+
+// Channels a client can subscribe to. A user's chirps are on
+// ChannelUserPrefix followed by their ID.
+const (
+	ChannelFeed          = "feed"
+	ChannelNotifications = "notifications"
+	ChannelUserPrefix    = "user:"
+)
+
+// Close codes, in the range WebSockets leave to applications.
+const (
+	// CloseUnauthorized means the client didn't authenticate in time, its
+	// token was rejected, or its token expired without being replaced.
+	CloseUnauthorized websocket.StatusCode = 4001
+	// CloseSlowConsumer means the client fell too far behind and should
+	// reconnect.
+	CloseSlowConsumer websocket.StatusCode = 4008
+)
+
+// Message is a single protocol message.
+type Message struct {
+	Type      string          `json:"type"`
+	ID        string          `json:"id,omitempty"`
+	Token     string          `json:"token,omitempty"`
+	Channel   string          `json:"channel,omitempty"`
+	Event     string          `json:"event,omitempty"`
+	EventID   int64           `json:"event_id,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	UserID    *uuid.UUID      `json:"user_id,omitempty"`
+	ExpiresAt *time.Time      `json:"expires_at,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// UserChannel returns the channel carrying a user's chirps.
+func UserChannel(userID uuid.UUID) string {
+	return ChannelUserPrefix + userID.String()
+}
+
+func validChannel(channel string) error {
+	switch channel {
+	case ChannelFeed, ChannelNotifications:
+		return nil
+	}
+	if id, ok := strings.CutPrefix(channel, ChannelUserPrefix); ok {
+		if _, err := uuid.Parse(id); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown channel %q", channel)
+}
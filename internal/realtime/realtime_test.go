@@ -0,0 +1,225 @@
+package realtime
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/google/uuid"
+	"github.com/lucoand/chirpy/internal/stream"
+)
+
+// testServer serves a realtime Server whose tokens are looked up in
+// sessions.
+func testServer(t *testing.T, sessions map[string]Session) (*Server, string) {
+	t.Helper()
+	s := &Server{
+		Hub: stream.NewHub(10, 10),
+		Authenticate: func(ctx context.Context, token string) (Session, error) {
+			session, ok := sessions[token]
+			if !ok {
+				return Session{}, errors.New("invalid token")
+			}
+			return session, nil
+		},
+		Filter: func(ctx context.Context, userID uuid.UUID) (stream.Filter, error) {
+			return func(e stream.Event) bool { return !e.AuthorOnly || e.AuthorID == userID }, nil
+		},
+	}
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	return s, "ws" + strings.TrimPrefix(ts.URL, "http")
+}
+
+func dial(t *testing.T, url string, token string) *Client {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, _, err := Dial(ctx, url, token)
+	if err != nil {
+		t.Fatalf("%s: could not connect: %s", t.Name(), err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func next(t *testing.T, c *Client) Message {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	m, err := c.Next(ctx)
+	if err != nil {
+		t.Fatalf("%s: expected a message: %s", t.Name(), err)
+	}
+	return m
+}
+
+func TestRealtimeAuth(t *testing.T) {
+	userID := uuid.New()
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	_, url := testServer(t, map[string]Session{"good": {UserID: userID, ExpiresAt: expiresAt}})
+	ctx := context.Background()
+
+	_, _, err := Dial(ctx, url, "bad")
+	if err == nil {
+		t.Errorf("TestRealtimeAuth: expected a bad token to be rejected")
+	}
+	c, ready, err := Dial(ctx, url, "good")
+	if err != nil {
+		t.Fatalf("TestRealtimeAuth: could not connect: %s", err)
+	}
+	defer c.Close()
+	if ready.Type != TypeReady || ready.UserID == nil || *ready.UserID != userID {
+		t.Errorf("TestRealtimeAuth: expected ready for %v, got %+v", userID, ready)
+	}
+	if ready.ExpiresAt == nil || !ready.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("TestRealtimeAuth: expected expires_at %v, got %v", expiresAt, ready.ExpiresAt)
+	}
+	err = c.Ping(ctx)
+	if err != nil {
+		t.Errorf("TestRealtimeAuth: ping failed: %s", err)
+	}
+}
+
+func TestRealtimeAuthTimeout(t *testing.T) {
+	s, url := testServer(t, map[string]Session{})
+	s.AuthTimeout = 50 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ws, _, err := websocket.Dial(ctx, url, nil)
+	if err != nil {
+		t.Fatalf("TestRealtimeAuthTimeout: could not connect: %s", err)
+	}
+	defer ws.CloseNow()
+	for err == nil {
+		_, _, err = ws.Read(ctx)
+	}
+	if websocket.CloseStatus(err) != CloseUnauthorized {
+		t.Errorf("TestRealtimeAuthTimeout: expected close code %d, got %s", CloseUnauthorized, err)
+	}
+}
+
+func TestRealtimeChannels(t *testing.T) {
+	alice := uuid.New()
+	bob := uuid.New()
+	expiresAt := time.Now().Add(time.Hour)
+	s, url := testServer(t, map[string]Session{
+		"alice": {UserID: alice, ExpiresAt: expiresAt},
+	})
+	c := dial(t, url, "alice")
+	ctx := context.Background()
+
+	err := c.Subscribe(ctx, "bogus")
+	if err == nil {
+		t.Errorf("TestRealtimeChannels: expected an unknown channel to be rejected")
+	}
+	for _, channel := range []string{UserChannel(bob), ChannelNotifications} {
+		err = c.Subscribe(ctx, channel)
+		if err != nil {
+			t.Fatalf("TestRealtimeChannels: could not subscribe to %s: %s", channel, err)
+		}
+	}
+	s.Hub.Publish(stream.Event{ID: 1, Type: "chirp.created", AuthorID: alice, Data: []byte(`{}`)})
+	s.Hub.Publish(stream.Event{ID: 2, Type: "user.upgraded", AuthorID: bob, Data: []byte(`{}`)})
+	s.Hub.Publish(stream.Event{ID: 3, Type: "chirp.created", AuthorID: bob, AuthorOnly: true, Data: []byte(`{}`)})
+	s.Hub.Publish(stream.Event{ID: 4, Type: "chirp.created", AuthorID: bob, Data: []byte(`{"id":4}`)})
+	s.Hub.Publish(stream.Event{ID: 5, Type: "user.upgraded", AuthorID: alice, Data: []byte(`{}`)})
+
+	m := next(t, c)
+	if m.Type != TypeEvent || m.EventID != 4 || m.Channel != UserChannel(bob) || string(m.Data) != `{"id":4}` {
+		t.Errorf("TestRealtimeChannels: expected event 4 on bob's channel, got %+v", m)
+	}
+	m = next(t, c)
+	if m.EventID != 5 || m.Channel != ChannelNotifications || m.Event != "user.upgraded" {
+		t.Errorf("TestRealtimeChannels: expected alice's notification, got %+v", m)
+	}
+
+	err = c.Unsubscribe(ctx, UserChannel(bob))
+	if err != nil {
+		t.Fatalf("TestRealtimeChannels: could not unsubscribe: %s", err)
+	}
+	err = c.Subscribe(ctx, ChannelFeed)
+	if err != nil {
+		t.Fatalf("TestRealtimeChannels: could not subscribe to the feed: %s", err)
+	}
+	s.Hub.Publish(stream.Event{ID: 6, Type: "chirp.created", AuthorID: bob, Data: []byte(`{}`)})
+	m = next(t, c)
+	if m.EventID != 6 || m.Channel != ChannelFeed {
+		t.Errorf("TestRealtimeChannels: expected event 6 on the feed only, got %+v", m)
+	}
+	err = c.Ping(ctx)
+	if err != nil || len(c.pending) != 0 {
+		t.Errorf("TestRealtimeChannels: expected no further events, got %v (%v)", c.pending, err)
+	}
+}
+
+func TestRealtimeTokenExpiry(t *testing.T) {
+	alice := uuid.New()
+	s, url := testServer(t, map[string]Session{
+		"short":   {UserID: alice, ExpiresAt: time.Now().Add(500 * time.Millisecond)},
+		"renewed": {UserID: alice, ExpiresAt: time.Now().Add(time.Hour)},
+		"other":   {UserID: uuid.New(), ExpiresAt: time.Now().Add(time.Hour)},
+	})
+	s.ExpiryWarning = 400 * time.Millisecond
+	ctx := context.Background()
+
+	c := dial(t, url, "short")
+	m := next(t, c)
+	if m.Type != TypeTokenExpiring {
+		t.Fatalf("TestRealtimeTokenExpiry: expected token_expiring, got %+v", m)
+	}
+	_, err := c.Reauthenticate(ctx, "other")
+	if err == nil {
+		t.Errorf("TestRealtimeTokenExpiry: expected a token for another user to be rejected")
+	}
+	ready, err := c.Reauthenticate(ctx, "renewed")
+	if err != nil {
+		t.Fatalf("TestRealtimeTokenExpiry: could not reauthenticate: %s", err)
+	}
+	if ready.ExpiresAt == nil || time.Until(*ready.ExpiresAt) < time.Minute {
+		t.Errorf("TestRealtimeTokenExpiry: expected the new expiry, got %v", ready.ExpiresAt)
+	}
+	time.Sleep(600 * time.Millisecond)
+	err = c.Ping(ctx)
+	if err != nil {
+		t.Errorf("TestRealtimeTokenExpiry: expected the connection to stay open: %s", err)
+	}
+
+	expired := dial(t, url, "short")
+	var closeErr error
+	for closeErr == nil {
+		_, closeErr = expired.Next(ctx)
+	}
+	if websocket.CloseStatus(closeErr) != CloseUnauthorized {
+		t.Errorf("TestRealtimeTokenExpiry: expected close code %d, got %s", CloseUnauthorized, closeErr)
+	}
+}
+
+func TestRealtimeSlowConsumer(t *testing.T) {
+	alice := uuid.New()
+	s, url := testServer(t, map[string]Session{
+		"alice": {UserID: alice, ExpiresAt: time.Now().Add(time.Hour)},
+	})
+	c := dial(t, url, "alice")
+	ctx := context.Background()
+	err := c.Subscribe(ctx, ChannelFeed)
+	if err != nil {
+		t.Fatalf("TestRealtimeSlowConsumer: could not subscribe: %s", err)
+	}
+	// Far more than fits in the hub's buffer and the socket's, without
+	// reading any of it.
+	data := []byte(`"` + strings.Repeat("x", 8<<10) + `"`)
+	for i := int64(1); i <= 1000; i++ {
+		s.Hub.Publish(stream.Event{ID: i, Type: "chirp.created", AuthorID: alice, Data: data})
+	}
+	for err == nil {
+		_, err = c.Next(ctx)
+	}
+	if websocket.CloseStatus(err) != CloseSlowConsumer {
+		t.Errorf("TestRealtimeSlowConsumer: expected close code %d, got %s", CloseSlowConsumer, err)
+	}
+}
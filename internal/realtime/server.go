@@ -0,0 +1,370 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/google/uuid"
+	"github.com/lucoand/chirpy/internal/stream"
+)
+
+const (
+	defaultPingInterval  = 30 * time.Second
+	defaultAuthTimeout   = 10 * time.Second
+	defaultExpiryWarning = time.Minute
+	writeTimeout         = 10 * time.Second
+	maxMessageBytes      = 16 << 10
+	maxChannels          = 50
+	chirpEventPrefix     = "chirp."
+)
+
+var errAuthTimeout = errors.New("authentication timed out")
+
+// A Session is an authenticated connection's user and when its token
+// expires.
+type Session struct {
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+}
+
+// Server accepts WebSocket connections and relays a hub's events to them.
+type Server struct {
+	Hub *stream.Hub
+	// Authenticate checks an access token.
+	Authenticate func(ctx context.Context, token string) (Session, error)
+	// Filter returns which chirp events a user may see, e.g. leaving out
+	// authors they have blocked.
+	Filter func(ctx context.Context, userID uuid.UUID) (stream.Filter, error)
+	// PingInterval is how often the server pings the client to detect dead
+	// connections. It defaults to 30 seconds.
+	PingInterval time.Duration
+	// AuthTimeout is how long a client has to authenticate after
+	// connecting. It defaults to 10 seconds.
+	AuthTimeout time.Duration
+	// ExpiryWarning is how long before its token expires a client is sent
+	// token_expiring. It defaults to a minute.
+	ExpiryWarning time.Duration
+	// OriginPatterns are the cross-origin hosts allowed to connect, as for
+	// websocket.AcceptOptions.
+	OriginPatterns []string
+}
+
+func durationOr(d time.Duration, def time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return def
+}
+
+// conn is one client connection. Only the goroutine running serve writes
+// messages, so replies and events are never interleaved.
+type conn struct {
+	server *Server
+	ws     *websocket.Conn
+	filter stream.Filter
+
+	// mu guards session and channels, which the hub's filter reads from
+	// the publishing goroutine.
+	mu       sync.Mutex
+	session  Session
+	channels map[string]bool
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Clients that can set headers may authenticate up front; browsers send
+	// an auth message instead.
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	ws, err := websocket.Accept(w, r, &websocket.AcceptOptions{OriginPatterns: s.OriginPatterns})
+	if err != nil {
+		log.Printf("Error accepting WebSocket: %s", err)
+		return
+	}
+	defer ws.CloseNow()
+	ws.SetReadLimit(maxMessageBytes)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	session, id, err := s.handshake(ctx, ws, strings.TrimSpace(token))
+	if errors.Is(err, errAuthTimeout) {
+		return
+	} else if err != nil {
+		writeMessage(ctx, ws, Message{Type: TypeError, ID: id, Error: err.Error()})
+		ws.Close(CloseUnauthorized, "unauthorized")
+		return
+	}
+	filter, err := s.Filter(ctx, session.UserID)
+	if err != nil {
+		log.Printf("Error loading realtime filters: %s", err)
+		ws.Close(websocket.StatusInternalError, "internal error")
+		return
+	}
+	c := &conn{
+		server:   s,
+		ws:       ws,
+		session:  session,
+		filter:   filter,
+		channels: map[string]bool{},
+	}
+	c.serve(ctx, cancel, id)
+}
+
+// handshake authenticates a new connection, with the header token if there
+// was one and otherwise with the first message. It returns the message's ID
+// for the reply.
+func (s *Server) handshake(ctx context.Context, ws *websocket.Conn, token string) (Session, string, error) {
+	if token != "" {
+		session, err := s.Authenticate(ctx, token)
+		return session, "", err
+	}
+	// Cancelling a read closes the connection without a reason, so the
+	// timeout closes it explicitly instead.
+	timeout := time.AfterFunc(durationOr(s.AuthTimeout, defaultAuthTimeout), func() {
+		writeMessage(ctx, ws, Message{Type: TypeError, Error: "authentication timed out"})
+		ws.Close(CloseUnauthorized, "authentication timed out")
+	})
+	m, err := readMessage(ctx, ws)
+	if !timeout.Stop() || err != nil {
+		return Session{}, "", errAuthTimeout
+	}
+	if m.Type != TypeAuth || m.Token == "" {
+		return Session{}, m.ID, errors.New("the first message must be auth")
+	}
+	session, err := s.Authenticate(ctx, m.Token)
+	return session, m.ID, err
+}
+
+func (c *conn) serve(ctx context.Context, cancel context.CancelFunc, id string) {
+	sub, _, _ := c.server.Hub.Subscribe(c.wants, 0, false)
+	defer c.server.Hub.Unsubscribe(sub)
+
+	err := c.write(ctx, c.ready(id))
+	if err != nil {
+		return
+	}
+
+	incoming := make(chan Message)
+	go func() {
+		defer cancel()
+		for {
+			m, err := readMessage(ctx, c.ws)
+			if err != nil {
+				return
+			}
+			select {
+			case incoming <- m:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go c.keepAlive(ctx, cancel)
+
+	warn := time.NewTimer(0)
+	expire := time.NewTimer(0)
+	defer warn.Stop()
+	defer expire.Stop()
+	c.resetExpiry(warn, expire)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sub.Dropped:
+			c.ws.Close(CloseSlowConsumer, "client fell behind")
+			return
+		case e := <-sub.C:
+			err = c.sendEvent(ctx, e)
+		case m := <-incoming:
+			var reauthenticated bool
+			reauthenticated, err = c.handle(ctx, m)
+			if reauthenticated {
+				c.resetExpiry(warn, expire)
+			}
+		case <-warn.C:
+			expiresAt := c.currentSession().ExpiresAt
+			err = c.write(ctx, Message{Type: TypeTokenExpiring, ExpiresAt: &expiresAt})
+		case <-expire.C:
+			c.ws.Close(CloseUnauthorized, "token expired")
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// keepAlive pings the client so that connections that went away without
+// closing are noticed.
+func (c *conn) keepAlive(ctx context.Context, cancel context.CancelFunc) {
+	ticker := time.NewTicker(durationOr(c.server.PingInterval, defaultPingInterval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pingCtx, pingCancel := context.WithTimeout(ctx, writeTimeout)
+			err := c.ws.Ping(pingCtx)
+			pingCancel()
+			if err != nil {
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+func (c *conn) currentSession() Session {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.session
+}
+
+func (c *conn) resetExpiry(warn *time.Timer, expire *time.Timer) {
+	untilExpiry := time.Until(c.currentSession().ExpiresAt)
+	warn.Reset(untilExpiry - durationOr(c.server.ExpiryWarning, defaultExpiryWarning))
+	expire.Reset(untilExpiry)
+}
+
+func (c *conn) ready(id string) Message {
+	session := c.currentSession()
+	userID := session.UserID
+	expiresAt := session.ExpiresAt
+	return Message{Type: TypeReady, ID: id, UserID: &userID, ExpiresAt: &expiresAt}
+}
+
+// handle answers a client message, and reports whether the client
+// authenticated again.
+func (c *conn) handle(ctx context.Context, m Message) (bool, error) {
+	switch m.Type {
+	case TypePing:
+		return false, c.write(ctx, Message{Type: TypePong, ID: m.ID})
+	case TypeAuth:
+		session, err := c.server.Authenticate(ctx, m.Token)
+		if err != nil {
+			return false, c.writeError(ctx, m.ID, err.Error())
+		}
+		c.mu.Lock()
+		sameUser := session.UserID == c.session.UserID
+		if sameUser {
+			c.session = session
+		}
+		c.mu.Unlock()
+		if !sameUser {
+			return false, c.writeError(ctx, m.ID, "token is for a different user")
+		}
+		return true, c.write(ctx, c.ready(m.ID))
+	case TypeSubscribe:
+		err := validChannel(m.Channel)
+		if err != nil {
+			return false, c.writeError(ctx, m.ID, err.Error())
+		}
+		c.mu.Lock()
+		full := !c.channels[m.Channel] && len(c.channels) >= maxChannels
+		if !full {
+			c.channels[m.Channel] = true
+		}
+		c.mu.Unlock()
+		if full {
+			return false, c.writeError(ctx, m.ID, "too many subscriptions")
+		}
+		return false, c.write(ctx, Message{Type: TypeSubscribed, ID: m.ID, Channel: m.Channel})
+	case TypeUnsubscribe:
+		c.mu.Lock()
+		delete(c.channels, m.Channel)
+		c.mu.Unlock()
+		return false, c.write(ctx, Message{Type: TypeUnsubscribed, ID: m.ID, Channel: m.Channel})
+	}
+	if m.Type == "" {
+		return false, c.writeError(ctx, m.ID, "invalid message")
+	}
+	return false, c.writeError(ctx, m.ID, "unknown message type "+m.Type)
+}
+
+// channelsFor returns the subscribed channels an event belongs on. Chirp
+// events go to the feed and their author's channel; anything else is a
+// notification for the user it is about.
+func (c *conn) channelsFor(e stream.Event) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	channels := []string{}
+	if !strings.HasPrefix(e.Type, chirpEventPrefix) {
+		if e.AuthorID == c.session.UserID && c.channels[ChannelNotifications] {
+			channels = append(channels, ChannelNotifications)
+		}
+		return channels
+	}
+	if !c.filter(e) {
+		return channels
+	}
+	if c.channels[ChannelFeed] {
+		channels = append(channels, ChannelFeed)
+	}
+	if user := UserChannel(e.AuthorID); c.channels[user] {
+		channels = append(channels, user)
+	}
+	return channels
+}
+
+// wants is the connection's hub filter. It runs on the publisher's
+// goroutine.
+func (c *conn) wants(e stream.Event) bool {
+	return len(c.channelsFor(e)) > 0
+}
+
+func (c *conn) sendEvent(ctx context.Context, e stream.Event) error {
+	// The subscriptions may have changed since the event was queued.
+	for _, channel := range c.channelsFor(e) {
+		err := c.write(ctx, Message{
+			Type:    TypeEvent,
+			Channel: channel,
+			Event:   e.Type,
+			EventID: e.ID,
+			Data:    json.RawMessage(e.Data),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *conn) write(ctx context.Context, m Message) error {
+	return writeMessage(ctx, c.ws, m)
+}
+
+func (c *conn) writeError(ctx context.Context, id string, msg string) error {
+	return c.write(ctx, Message{Type: TypeError, ID: id, Error: msg})
+}
+
+func writeMessage(ctx context.Context, ws *websocket.Conn, m Message) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+	return ws.Write(ctx, websocket.MessageText, data)
+}
+
+func readMessage(ctx context.Context, ws *websocket.Conn) (Message, error) {
+	m := Message{}
+	_, data, err := ws.Read(ctx)
+	if err != nil {
+		return m, err
+	}
+	err = json.Unmarshal(data, &m)
+	if err != nil {
+		// Undecodable messages get an error reply rather than closing the
+		// connection.
+		return Message{}, nil
+	}
+	return m, nil
+}
@@ -0,0 +1,143 @@
+// Package stream fans live events out to connected clients. A Hub keeps a
+// bounded buffer of recent events so that a client that reconnects can
+// resume from the last event it saw, and drops clients that fall too far
+// behind instead of letting them slow everyone else down.
+package stream
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// An Event is one message on the stream. IDs increase across the whole
+// deployment, so a client can resume on any server.
+type Event struct {
+	ID       int64
+	Type     string
+	AuthorID uuid.UUID
+	// AuthorOnly events are only sent to their author, e.g. chirps by a
+	// shadowbanned user.
+	AuthorOnly bool
+	// Audience, if set, limits an event to these users and its author, e.g.
+	// a chirp for the author's followers.
+	Audience map[uuid.UUID]bool
+	// Body is the text filters such as mutes apply to.
+	Body string
+	// Sensitive events are skipped by viewers who hide sensitive chirps.
+	Sensitive bool
+	Data      []byte
+}
+
+// A Filter decides whether a subscriber wants an event.
+type Filter func(Event) bool
+
+// A Subscription receives the events that match its filter.
+type Subscription struct {
+	// C delivers events in the order they were published.
+	C <-chan Event
+	// Dropped is closed if the subscriber fell behind and was removed. The
+	// client should reconnect and resume from the last event it received.
+	Dropped <-chan struct{}
+
+	c       chan Event
+	dropped chan struct{}
+	filter  Filter
+}
+
+// Hub broadcasts events to subscriptions.
+type Hub struct {
+	mu         sync.Mutex
+	replay     []Event
+	replaySize int
+	bufferSize int
+	subs       map[*Subscription]struct{}
+}
+
+// NewHub returns a hub that keeps the last replaySize events for resuming,
+// and lets each subscriber fall up to bufferSize events behind.
+func NewHub(replaySize int, bufferSize int) *Hub {
+	return &Hub{
+		replaySize: replaySize,
+		bufferSize: bufferSize,
+		subs:       map[*Subscription]struct{}{},
+	}
+}
+
+// Publish sends an event to every subscription whose filter matches it.
+// Subscriptions whose buffer is full are dropped.
+func (h *Hub) Publish(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.replay = append(h.replay, e)
+	if len(h.replay) > h.replaySize {
+		h.replay = h.replay[len(h.replay)-h.replaySize:]
+	}
+	for s := range h.subs {
+		if !s.filter(e) {
+			continue
+		}
+		select {
+		case s.c <- e:
+		default:
+			delete(h.subs, s)
+			close(s.dropped)
+		}
+	}
+}
+
+// Subscribe registers a subscription. If resume is set, it also returns the
+// buffered events after lastEventID that match the filter, and whether the
+// buffer went back far enough to include every event the client missed.
+// Registering and collecting the replay happen together, so no event is
+// missed or sent twice in between.
+func (h *Hub) Subscribe(filter Filter, lastEventID int64, resume bool) (*Subscription, []Event, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := &Subscription{
+		c:       make(chan Event, h.bufferSize),
+		dropped: make(chan struct{}),
+		filter:  filter,
+	}
+	s.C = s.c
+	s.Dropped = s.dropped
+	h.subs[s] = struct{}{}
+	if !resume {
+		return s, nil, true
+	}
+	missed, complete := h.since(lastEventID)
+	replay := []Event{}
+	for _, e := range missed {
+		if filter(e) {
+			replay = append(replay, e)
+		}
+	}
+	return s, replay, complete
+}
+
+// since returns the buffered events after lastEventID. Events from
+// different servers can arrive slightly out of ID order, so if the event is
+// still buffered everything that arrived after it is returned.
+func (h *Hub) since(lastEventID int64) ([]Event, bool) {
+	for i := len(h.replay) - 1; i >= 0; i-- {
+		if h.replay[i].ID == lastEventID {
+			return h.replay[i+1:], true
+		}
+	}
+	events := []Event{}
+	for _, e := range h.replay {
+		if e.ID > lastEventID {
+			events = append(events, e)
+		}
+	}
+	complete := len(h.replay) == 0 || lastEventID >= h.replay[0].ID-1
+	return events, complete
+}
+
+// Unsubscribe removes a subscription. It is safe to call more than once and
+// after the subscription has been dropped.
+func (h *Hub) Unsubscribe(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subs, s)
+}
@@ -0,0 +1,103 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func all(Event) bool { return true }
+
+func TestHubPublish(t *testing.T) {
+	h := NewHub(10, 10)
+	author := uuid.New()
+	s, _, _ := h.Subscribe(func(e Event) bool { return e.AuthorID == author }, 0, false)
+	h.Publish(Event{ID: 1, AuthorID: uuid.New()})
+	h.Publish(Event{ID: 2, AuthorID: author})
+	select {
+	case e := <-s.C:
+		if e.ID != 2 {
+			t.Errorf("TestHubPublish: expected event 2, got %d", e.ID)
+		}
+	default:
+		t.Fatalf("TestHubPublish: expected an event")
+	}
+	select {
+	case e := <-s.C:
+		t.Errorf("TestHubPublish: unexpected event %d", e.ID)
+	default:
+	}
+}
+
+func TestHubResume(t *testing.T) {
+	h := NewHub(10, 10)
+	for i := int64(1); i <= 5; i++ {
+		h.Publish(Event{ID: i})
+	}
+	_, replay, complete := h.Subscribe(all, 3, true)
+	if !complete {
+		t.Errorf("TestHubResume: expected a complete replay")
+	}
+	if len(replay) != 2 || replay[0].ID != 4 || replay[1].ID != 5 {
+		t.Errorf("TestHubResume: expected events 4 and 5, got %v", replay)
+	}
+}
+
+func TestHubResumeOutOfOrder(t *testing.T) {
+	h := NewHub(10, 10)
+	for _, id := range []int64{1, 3, 2, 4} {
+		h.Publish(Event{ID: id})
+	}
+	_, replay, _ := h.Subscribe(all, 3, true)
+	if len(replay) != 2 || replay[0].ID != 2 || replay[1].ID != 4 {
+		t.Errorf("TestHubResumeOutOfOrder: expected events that arrived after 3, got %v", replay)
+	}
+}
+
+func TestHubResumeTooOld(t *testing.T) {
+	h := NewHub(3, 10)
+	for i := int64(1); i <= 6; i++ {
+		h.Publish(Event{ID: i})
+	}
+	_, replay, complete := h.Subscribe(all, 1, true)
+	if complete {
+		t.Errorf("TestHubResumeTooOld: expected an incomplete replay")
+	}
+	if len(replay) != 3 || replay[0].ID != 4 {
+		t.Errorf("TestHubResumeTooOld: expected the buffered events 4 to 6, got %v", replay)
+	}
+}
+
+func TestHubResumeUpToDate(t *testing.T) {
+	h := NewHub(3, 10)
+	h.Publish(Event{ID: 7})
+	_, replay, complete := h.Subscribe(all, 7, true)
+	if !complete || len(replay) != 0 {
+		t.Errorf("TestHubResumeUpToDate: expected nothing to replay, got %v (complete %v)", replay, complete)
+	}
+}
+
+func TestHubDropsSlowSubscriber(t *testing.T) {
+	h := NewHub(10, 2)
+	slow, _, _ := h.Subscribe(all, 0, false)
+	fast, _, _ := h.Subscribe(all, 0, false)
+	for i := int64(1); i <= 3; i++ {
+		h.Publish(Event{ID: i})
+		<-fast.C
+	}
+	select {
+	case <-slow.Dropped:
+	default:
+		t.Errorf("TestHubDropsSlowSubscriber: expected the slow subscriber to be dropped")
+	}
+	select {
+	case <-fast.Dropped:
+		t.Errorf("TestHubDropsSlowSubscriber: the fast subscriber should not be dropped")
+	default:
+	}
+	h.Publish(Event{ID: 4})
+	if e := <-fast.C; e.ID != 4 {
+		t.Errorf("TestHubDropsSlowSubscriber: expected event 4, got %d", e.ID)
+	}
+	h.Unsubscribe(slow)
+}
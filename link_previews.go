@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/lucoand/chirpy/internal/chirptext"
+	"github.com/lucoand/chirpy/internal/database"
+	"github.com/lucoand/chirpy/internal/events"
+)
+
+const (
+	// Cards are refetched when a page is linked again after a day, and
+	// pages that had none are retried after an hour.
+	linkPreviewTTL       = 24 * time.Hour
+	failedLinkPreviewTTL = time.Hour
+	linkPreviewBatchSize = 10
+	// linkPreviewPollInterval is how often the worker checks for work when
+	// nothing wakes it, e.g. for links queued on other servers.
+	linkPreviewPollInterval = 5 * time.Second
+	maxLinkPreviewURLLength = 2048
+)
+
+// urlEntityJSON is a link in a chirp's body. Start and End are byte offsets
+// into the UTF-8 body.
+type urlEntityJSON struct {
+	URL   string `json:"url"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+type entitiesJSON struct {
+	URLs []urlEntityJSON `json:"urls"`
+}
+
+// cardJSON is the preview of the first link in a chirp.
+type cardJSON struct {
+	URL         string  `json:"url"`
+	Title       string  `json:"title"`
+	Description *string `json:"description"`
+	ImageURL    *string `json:"image_url"`
+}
+
+func bodyEntities(body string) entitiesJSON {
+	urls := chirptext.URLs(body)
+	entities := entitiesJSON{URLs: make([]urlEntityJSON, len(urls))}
+	for i, u := range urls {
+		entities.URLs[i] = urlEntityJSON{URL: u.URL, Start: u.Start, End: u.End}
+	}
+	return entities
+}
+
+// cardURL is the link a chirp's card previews, or "" if it has none.
+func cardURL(chirp chirpJSON) string {
+	if len(chirp.Entities.URLs) == 0 || len(chirp.Entities.URLs[0].URL) > maxLinkPreviewURLLength {
+		return ""
+	}
+	return chirp.Entities.URLs[0].URL
+}
+
+// queueLinkPreviews is subscribed to the event bus, so that every new chirp
+// gets a card however it was posted.
+func (cfg *apiConfig) queueLinkPreviews(ctx context.Context, e events.Event) {
+	if e.Type != events.ChirpCreated {
+		return
+	}
+	chirp, ok := e.Data.(chirpJSON)
+	if !ok {
+		return
+	}
+	cfg.queueLinkPreview(ctx, chirp)
+}
+
+// queueLinkPreview asks the worker for the chirp's card, unless there is a
+// recent one already.
+func (cfg *apiConfig) queueLinkPreview(ctx context.Context, chirp chirpJSON) {
+	u := cardURL(chirp)
+	if u == "" {
+		return
+	}
+	err := cfg.dbQueries.QueueLinkPreview(ctx, database.QueueLinkPreviewParams{
+		Url:              u,
+		ReadyTtlSeconds:  linkPreviewTTL.Seconds(),
+		FailedTtlSeconds: failedLinkPreviewTTL.Seconds(),
+	})
+	if err != nil {
+		log.Printf("Error queueing link preview for chirp %v: %s", chirp.ID, err)
+		return
+	}
+	select {
+	case cfg.linkPreviewWake <- struct{}{}:
+	default:
+	}
+}
+
+// withCards fills in the cards of chirps whose links have been previewed.
+// Cards are fetched in the background, so a new chirp has none at first.
+func withCards(ctx context.Context, q *database.Queries, chirps []chirpJSON) error {
+	urls := []string{}
+	for _, c := range chirps {
+		if u := cardURL(c); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	if len(urls) == 0 {
+		return nil
+	}
+	previews, err := q.GetLinkPreviews(ctx, urls)
+	if err != nil {
+		return err
+	}
+	cards := cardsByURL(previews)
+	for i := range chirps {
+		chirps[i].Card = cards[cardURL(chirps[i])]
+	}
+	return nil
+}
+
+// cardsByURL turns fetched previews into cards, keyed by the URL previewed.
+func cardsByURL(previews []database.LinkPreview) map[string]*cardJSON {
+	cards := map[string]*cardJSON{}
+	for _, p := range previews {
+		card := &cardJSON{URL: p.Url, Title: p.Title.String}
+		if p.Description.Valid {
+			card.Description = &p.Description.String
+		}
+		if p.ImageUrl.Valid {
+			card.ImageURL = &p.ImageUrl.String
+		}
+		cards[p.Url] = card
+	}
+	return cards
+}
+
+func (cfg *apiConfig) runLinkPreviewWorker(ctx context.Context) {
+	ticker := time.NewTicker(linkPreviewPollInterval)
+	defer ticker.Stop()
+	for {
+		cfg.fetchLinkPreviews(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-cfg.linkPreviewWake:
+		}
+	}
+}
+
+// fetchLinkPreviews fetches every queued card. Like webhook deliveries,
+// links are claimed with SKIP LOCKED and a lease, and a batch is fetched
+// concurrently so one slow site doesn't hold up the rest.
+func (cfg *apiConfig) fetchLinkPreviews(ctx context.Context) {
+	for {
+		claimed, err := cfg.dbQueries.ClaimLinkPreviews(ctx, linkPreviewBatchSize)
+		if err != nil {
+			log.Printf("Error claiming link previews: %s", err)
+			return
+		}
+		var wg sync.WaitGroup
+		for _, u := range claimed {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				cfg.fetchLinkPreview(ctx, u)
+			}()
+		}
+		wg.Wait()
+		if len(claimed) < linkPreviewBatchSize {
+			return
+		}
+	}
+}
+
+func (cfg *apiConfig) fetchLinkPreview(ctx context.Context, u string) {
+	card, err := cfg.linkPreviews.Fetch(ctx, u)
+	if err != nil {
+		err = cfg.dbQueries.FailLinkPreview(ctx, database.FailLinkPreviewParams{
+			Url:       u,
+			LastError: sql.NullString{String: err.Error(), Valid: true},
+		})
+	} else {
+		err = cfg.dbQueries.SaveLinkPreview(ctx, database.SaveLinkPreviewParams{
+			Url:         u,
+			Title:       sql.NullString{String: card.Title, Valid: true},
+			Description: sql.NullString{String: card.Description, Valid: card.Description != ""},
+			ImageUrl:    sql.NullString{String: card.ImageURL, Valid: card.ImageURL != ""},
+		})
+	}
+	if err != nil {
+		log.Printf("Error recording link preview of %s: %s", u, err)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/lucoand/chirpy/internal/database"
+)
+
+func TestBodyEntities(t *testing.T) {
+	body := "see https://example.com/a, and http://example.org."
+	entities := bodyEntities(body)
+	want := []string{"https://example.com/a", "http://example.org"}
+	if len(entities.URLs) != len(want) {
+		t.Fatalf("TestBodyEntities: expected %d URLs, got %v", len(want), entities.URLs)
+	}
+	for i, u := range entities.URLs {
+		if u.URL != want[i] {
+			t.Errorf("TestBodyEntities: expected URL %q, got %q", want[i], u.URL)
+		}
+		if body[u.Start:u.End] != u.URL {
+			t.Errorf("TestBodyEntities: offsets %d-%d don't match %q", u.Start, u.End, u.URL)
+		}
+	}
+	if entities := bodyEntities("no links here"); entities.URLs == nil || len(entities.URLs) != 0 {
+		t.Errorf("TestBodyEntities: expected an empty list, got %v", entities.URLs)
+	}
+}
+
+func TestCardURL(t *testing.T) {
+	long := "https://example.com/" + strings.Repeat("a", maxLinkPreviewURLLength)
+	tests := []struct {
+		body string
+		want string
+	}{
+		{"https://example.com/a then https://example.com/b", "https://example.com/a"},
+		{"no links here", ""},
+		{long, ""},
+	}
+	for _, tt := range tests {
+		got := cardURL(chirpJSON{Entities: bodyEntities(tt.body)})
+		if got != tt.want {
+			t.Errorf("TestCardURL %.40q: expected %q, got %q", tt.body, tt.want, got)
+		}
+	}
+}
+
+func TestCardsByURL(t *testing.T) {
+	cards := cardsByURL([]database.LinkPreview{
+		{
+			Url:         "https://example.com/a",
+			Title:       sql.NullString{String: "A", Valid: true},
+			Description: sql.NullString{String: "About A", Valid: true},
+			ImageUrl:    sql.NullString{String: "https://example.com/a.png", Valid: true},
+		},
+		{
+			Url:   "https://example.com/b",
+			Title: sql.NullString{String: "B", Valid: true},
+		},
+	})
+	a := cards["https://example.com/a"]
+	if a == nil || a.Title != "A" || a.Description == nil || *a.Description != "About A" || a.ImageURL == nil || *a.ImageURL != "https://example.com/a.png" {
+		t.Errorf("TestCardsByURL: unexpected card for a: %+v", a)
+	}
+	b := cards["https://example.com/b"]
+	if b == nil || b.Title != "B" || b.Description != nil || b.ImageURL != nil {
+		t.Errorf("TestCardsByURL: unexpected card for b: %+v", b)
+	}
+	// Chirps are given the card for the URL they preview, and none if it
+	// hasn't been fetched.
+	chirp := chirpJSON{Entities: bodyEntities("https://example.com/c")}
+	if card := cards[cardURL(chirp)]; card != nil {
+		t.Errorf("TestCardsByURL: expected no card for an unfetched link, got %+v", card)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
@@ -8,8 +9,6 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"sort"
-	"strings"
 	"sync/atomic"
 	"time"
 
@@ -18,27 +17,60 @@ import (
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 	"github.com/lucoand/chirpy/internal/auth"
+	"github.com/lucoand/chirpy/internal/blob"
+	"github.com/lucoand/chirpy/internal/chirptext"
 	"github.com/lucoand/chirpy/internal/database"
+	"github.com/lucoand/chirpy/internal/entitlements"
+	"github.com/lucoand/chirpy/internal/events"
+	"github.com/lucoand/chirpy/internal/linkpreview"
+	"github.com/lucoand/chirpy/internal/profanity"
+	"github.com/lucoand/chirpy/internal/stream"
 )
 
-var profanities = []string{"kerfuffle", "sharbert", "fornax"}
 var port = ":8080"
-var censor string = "****"
 
 type apiConfig struct {
-	jwtSecret      string
-	fileserverHits atomic.Int32
-	dbQueries      *database.Queries
-	platform       string
-	polkaKey       string
+	jwtSecret           string
+	fileserverHits      atomic.Int32
+	db                  *sql.DB
+	dbQueries           *database.Queries
+	platform            string
+	polkaSecrets        []string
+	profanity           profanityCache
+	webhookWake         chan struct{}
+	webhookDeliveryWake chan struct{}
+	events              *events.Bus
+	stream              *stream.Hub
+	media               blob.Store
+	linkPreviews        linkpreview.Fetcher
+	linkPreviewWake     chan struct{}
 }
 
 type chirpJSON struct {
-	Body      string    `json:"body"`
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	UserID    uuid.UUID `json:"user_id"`
+	Body           string       `json:"body"`
+	ID             uuid.UUID    `json:"id"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+	UserID         uuid.UUID    `json:"user_id"`
+	Visibility     string       `json:"visibility"`
+	Mentions       []uuid.UUID  `json:"mentions"`
+	ContentWarning *string      `json:"content_warning"`
+	Sensitive      bool         `json:"sensitive"`
+	Entities       entitiesJSON `json:"entities"`
+	Media          []mediaJSON  `json:"media"`
+	Poll           *pollJSON    `json:"poll"`
+	Card           *cardJSON    `json:"card"`
+}
+
+// chirpDeletedJSON identifies a deleted chirp in events. AuthorStatus and
+// Mentions record who could see the chirp, which the stream needs once it is
+// gone; they are never sent out.
+type chirpDeletedJSON struct {
+	ID           uuid.UUID   `json:"id"`
+	UserID       uuid.UUID   `json:"user_id"`
+	Visibility   string      `json:"visibility"`
+	AuthorStatus string      `json:"-"`
+	Mentions     []uuid.UUID `json:"-"`
 }
 
 type userJSON struct {
@@ -47,6 +79,8 @@ type userJSON struct {
 	UpdatedAt   time.Time `json:"updated_at"`
 	Email       string    `json:"email"`
 	IsChirpyRed bool      `json:"is_chirpy_red"`
+	Badge       *string   `json:"badge"`
+	Role        string    `json:"role"`
 }
 
 func chirpToJSON(c database.Chirp) chirpJSON {
@@ -56,51 +90,75 @@ func chirpToJSON(c database.Chirp) chirpJSON {
 	j.CreatedAt = c.CreatedAt
 	j.UpdatedAt = c.UpdatedAt
 	j.UserID = c.UserID
+	j.Visibility = c.Visibility
+	j.Mentions = []uuid.UUID{}
+	if c.ContentWarning.Valid {
+		j.ContentWarning = &c.ContentWarning.String
+	}
+	j.Sensitive = c.Sensitive
+	j.Entities = bodyEntities(c.Body)
+	j.Media = []mediaJSON{}
 	return j
 }
 
-func userToJSON(u database.User) userJSON {
+// withAttachments fills in the mentions, images, polls and link cards of
+// chirps, as viewerID sees them.
+func (cfg *apiConfig) withAttachments(ctx context.Context, viewerID uuid.NullUUID, chirps []chirpJSON) error {
+	return cfg.withAttachmentsTx(ctx, cfg.dbQueries, viewerID, chirps)
+}
+
+// withAttachmentsTx is withAttachments reading through q, so that chirps
+// created in a transaction can be filled in before it commits.
+func (cfg *apiConfig) withAttachmentsTx(ctx context.Context, q *database.Queries, viewerID uuid.NullUUID, chirps []chirpJSON) error {
+	err := withMentions(ctx, q, chirps)
+	if err != nil {
+		return err
+	}
+	err = cfg.withMedia(ctx, q, chirps)
+	if err != nil {
+		return err
+	}
+	err = withPolls(ctx, q, viewerID, chirps)
+	if err != nil {
+		return err
+	}
+	return withCards(ctx, q, chirps)
+}
+
+// respondWithChirp responds with a chirp and its attachments.
+func (cfg *apiConfig) respondWithChirp(w http.ResponseWriter, r *http.Request, viewerID uuid.NullUUID, chirp database.Chirp) {
+	resp := []chirpJSON{chirpToJSON(chirp)}
+	err := cfg.withAttachments(r.Context(), viewerID, resp)
+	if err != nil {
+		log.Printf("Error retrieving attachments for chirp %v: %s", chirp.ID, err)
+		w.WriteHeader(500)
+		return
+	}
+	respondWithJSON(w, 200, resp[0])
+}
+
+// profileBadge is the badge shown on the profile of a user whose plan
+// includes one, or nil.
+func profileBadge(ent entitlements.Entitlements) *string {
+	if !ent.Has(entitlements.ProfileBadge) {
+		return nil
+	}
+	return &ent.Plan
+}
+
+func userToJSON(u database.User, ent entitlements.Entitlements) userJSON {
 	j := userJSON{
 		ID:          u.ID,
 		CreatedAt:   u.CreatedAt,
 		UpdatedAt:   u.UpdatedAt,
 		Email:       u.Email,
-		IsChirpyRed: u.IsChirpyRed,
+		IsChirpyRed: ent.Plan == entitlements.PlanChirpyRed,
+		Badge:       profileBadge(ent),
+		Role:        u.Role,
 	}
 	return j
 }
 
-func filterProfanities(body string) string {
-	// account for newline chars
-	lines := strings.Split(body, "\n")
-
-	// iterate over each line
-	for i, line := range lines {
-		// split each line into component words
-		words := strings.Split(line, " ")
-
-		// iterate over each word in the line
-		for j, word := range words {
-
-			// account for uppercase profanities
-			lower := strings.ToLower(word)
-			// check each word against the slice of profanities
-			for _, profanity := range profanities {
-				// if it matches, censor it
-				if lower == profanity {
-					words[j] = censor
-					break
-				}
-			}
-		}
-		// join the words back into the line
-		lines[i] = strings.Join(words, " ")
-	}
-	// join the lines back into one string
-	body = strings.Join(lines, "\n")
-	return body
-}
-
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		cfg.fileserverHits.Add(1)
@@ -108,23 +166,34 @@ func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 	})
 }
 
-func newApiConfig(dbQueries *database.Queries, platform string, secret string, polkaKey string) *apiConfig {
+func newApiConfig(db *sql.DB, platform string, secret string, polkaSecrets []string) *apiConfig {
 	var cfg apiConfig
 	cfg.fileserverHits.Store(0)
-	cfg.dbQueries = dbQueries
+	cfg.db = db
+	cfg.dbQueries = database.New(db)
 	cfg.platform = platform
 	cfg.jwtSecret = secret
-	cfg.polkaKey = polkaKey
+	cfg.polkaSecrets = polkaSecrets
+	cfg.webhookWake = make(chan struct{}, 1)
+	cfg.webhookDeliveryWake = make(chan struct{}, 1)
+	cfg.events = events.NewBus()
+	cfg.events.Subscribe(cfg.wakeWebhookDeliveries)
+	cfg.stream = stream.NewHub(streamReplaySize, streamBufferSize)
+	cfg.events.Subscribe(cfg.notifyStream)
+	cfg.events.Subscribe(cfg.createNotifications)
+	cfg.linkPreviews = linkpreview.NewHTTPFetcher(linkpreview.Options{})
+	cfg.linkPreviewWake = make(chan struct{}, 1)
+	cfg.events.Subscribe(cfg.queueLinkPreviews)
 	return &cfg
 }
 
-func (cfg *apiConfig) handleMetrics(w http.ResponseWriter, req *http.Request) {
+func (cfg *apiConfig) handleMetrics(w http.ResponseWriter, req *http.Request, admin database.User) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprintf(w, "<html><body><h1>Welcome, Chirpy Admin</h1><p>Chirpy has been visited %d times!</p></body></html>", cfg.fileserverHits.Load())
 }
 
-func (cfg *apiConfig) handleReset(w http.ResponseWriter, r *http.Request) {
+func (cfg *apiConfig) handleReset(w http.ResponseWriter, r *http.Request, admin database.User) {
 	if cfg.platform != "dev" {
 		w.WriteHeader(403)
 		return
@@ -138,6 +207,10 @@ func (cfg *apiConfig) handleReset(w http.ResponseWriter, r *http.Request) {
 	}
 
 	cfg.fileserverHits.Store(0)
+	cfg.recordAudit(r, auditEntry{
+		Action:  auditAdminReset,
+		ActorID: actor(admin.ID),
+	})
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprintf(w, "Reset fileserverHits.\nDeleted users.\n")
@@ -173,7 +246,7 @@ func (cfg *apiConfig) handleCreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	uJSON := userToJSON(user)
+	uJSON := userToJSON(user, entitlements.ForPlan(entitlements.PlanFree))
 
 	dat, err := json.Marshal(uJSON)
 	if err != nil {
@@ -186,72 +259,211 @@ func (cfg *apiConfig) handleCreateUser(w http.ResponseWriter, r *http.Request) {
 	w.Write(dat)
 }
 
-func (cfg *apiConfig) handleValidateChirp(w http.ResponseWriter, r *http.Request) {
+// validateChirp normalizes a chirp body and checks it against the author's
+// limits and the profanity rules. It returns the filtered body to store, or a
+// message explaining why the chirp was rejected.
+func (cfg *apiConfig) validateChirp(ent entitlements.Entitlements, body string) (profanity.Result, string) {
+	return cfg.validateText("Chirp", body, ent.Limit(entitlements.ChirpLength))
+}
+
+// validateText is validateChirp for any text users post, such as direct
+// messages. noun names the text in the messages.
+func (cfg *apiConfig) validateText(noun string, body string, maxLength int) (profanity.Result, string) {
+	body, err := chirptext.Prepare(body)
+	if errors.Is(err, chirptext.ErrEmpty) {
+		return profanity.Result{}, noun + " is empty"
+	} else if errors.Is(err, chirptext.ErrControlCharacter) {
+		return profanity.Result{}, noun + " contains control characters"
+	} else if err != nil {
+		return profanity.Result{}, noun + " is not valid UTF-8"
+	}
+	if chirptext.Length(body) > maxLength {
+		return profanity.Result{}, noun + " is too long"
+	}
+	filtered := cfg.filterProfanities(body)
+	if filtered.Rejected {
+		return profanity.Result{}, noun + " contains prohibited language"
+	}
+	return filtered, ""
+}
+
+func (cfg *apiConfig) handleValidateChirp(w http.ResponseWriter, r *http.Request, user database.User) {
 	type parameters struct {
 		Body string `json:"body"`
 		// UserID uuid.UUID `json:"user_id"`
+		PublishAt *time.Time      `json:"publish_at"`
+		MediaIDs  []uuid.UUID     `json:"media_ids"`
+		Poll      *pollParameters `json:"poll"`
+		// Visibility defaults to public.
+		Visibility     string      `json:"visibility"`
+		Mentions       []uuid.UUID `json:"mentions"`
+		ContentWarning string      `json:"content_warning"`
+		Sensitive      bool        `json:"sensitive"`
 	}
 
-	type errorJson struct {
-		Error string `json:"error"`
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	ent, err := cfg.entitlements(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error checking entitlements for user %v: %s", user.ID, err)
+		w.WriteHeader(500)
+		return
+	}
+	filtered, problem := cfg.validateChirp(ent, params.Body)
+	if problem != "" {
+		respondWithError(w, 400, problem)
+		return
+	}
+	if params.Visibility == "" {
+		params.Visibility = visibilityPublic
+	}
+	if !validVisibility(params.Visibility) {
+		respondWithError(w, 400, "visibility must be public, unlisted, followers_only or mentioned_only")
+		return
+	}
+	warning, warningFlagged, problem := cfg.validateContentWarning(params.ContentWarning)
+	if problem != "" {
+		respondWithError(w, 400, problem)
+		return
+	}
+	filtered.Flagged = filtered.Flagged || warningFlagged
+	if problem := validateMediaIDs(params.MediaIDs); problem != "" {
+		respondWithError(w, 400, problem)
+		return
+	}
+	if problem := validateMentions(user.ID, params.Mentions); problem != "" {
+		respondWithError(w, 400, problem)
+		return
+	}
+	var pollOptions []string
+	if params.Poll != nil {
+		var pollFlagged bool
+		pollOptions, pollFlagged, problem = cfg.validatePoll(*params.Poll)
+		if problem != "" {
+			respondWithError(w, 400, problem)
+			return
+		}
+		filtered.Flagged = filtered.Flagged || pollFlagged
+	}
+	var query database.CreateChirpParams
+	query.Body = filtered.Body
+	query.UserID = user.ID
+	query.NeedsReview = filtered.Flagged
+	query.Visibility = params.Visibility
+	query.ContentWarning = warning
+	query.Sensitive = params.Sensitive || warning.Valid
+	if params.PublishAt != nil {
+		if len(params.MediaIDs) > 0 || params.Poll != nil || len(params.Mentions) > 0 {
+			respondWithError(w, 400, "Scheduled chirps can't have attachments, polls or mentions")
+			return
+		}
+		cfg.scheduleChirp(w, r, user, ent, query, *params.PublishAt)
+		return
+	}
+	if len(params.Mentions) > 0 {
+		found, err := cfg.dbQueries.CountUsersByIDs(r.Context(), params.Mentions)
+		if err != nil {
+			log.Printf("Error retrieving users from database: %s", err)
+			w.WriteHeader(500)
+			return
+		}
+		if found != int64(len(params.Mentions)) {
+			respondWithError(w, 404, "User not found")
+			return
+		}
+		blocked, err := cfg.dbQueries.IsBlockedBetween(r.Context(), database.IsBlockedBetweenParams{
+			UserID:   user.ID,
+			OtherIds: params.Mentions,
+		})
+		if err != nil {
+			log.Printf("Error checking blocks: %s", err)
+			w.WriteHeader(500)
+			return
+		}
+		if blocked {
+			respondWithError(w, 403, "You can't mention users you have blocked or who have blocked you")
+			return
+		}
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
+	// The chirp, its attachments and its webhook deliveries are saved
+	// together, so an attachment can't end up on two chirps and no delivery
+	// is lost if the server stops in between.
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
 	if err != nil {
-		log.Printf("Error getting Bearer Token: %s", err)
-		w.WriteHeader(401)
+		log.Printf("Error starting transaction: %s", err)
+		w.WriteHeader(500)
 		return
 	}
-	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+	result, err := qtx.CreateChirp(r.Context(), query)
 	if err != nil {
-		log.Printf("Error validating token: %s", err)
-		w.WriteHeader(401)
+		log.Printf("Error creating Chirp: %s", err)
+		w.WriteHeader(500)
 		return
 	}
-
-	decoder := json.NewDecoder(r.Body)
-	params := parameters{}
-	err = decoder.Decode(&params)
+	ok, err := attachMedia(r.Context(), qtx, user.ID, result.ID, params.MediaIDs)
 	if err != nil {
-		log.Printf("Error decoding parameters: %s", err)
+		log.Printf("Error attaching media to chirp %v: %s", result.ID, err)
 		w.WriteHeader(500)
 		return
 	}
-	if len(params.Body) > 140 {
-		resp := errorJson{
-			Error: "Chirp is too long",
+	if !ok {
+		respondWithError(w, 400, "Unknown or already attached media_ids")
+		return
+	}
+	if len(params.Mentions) > 0 {
+		err = qtx.CreateChirpMentions(r.Context(), database.CreateChirpMentionsParams{
+			ChirpID: result.ID,
+			UserIds: params.Mentions,
+		})
+		if err != nil {
+			log.Printf("Error saving mentions for chirp %v: %s", result.ID, err)
+			w.WriteHeader(500)
+			return
 		}
-		dat, err := json.Marshal(resp)
+	}
+	if params.Poll != nil {
+		err = createPoll(r.Context(), qtx, result.ID, params.Poll.ClosesAt, pollOptions)
 		if err != nil {
-			log.Printf("Error marshalling JSON: %s", err)
+			log.Printf("Error creating poll for chirp %v: %s", result.ID, err)
 			w.WriteHeader(500)
 			return
 		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(400)
-		w.Write(dat)
-		return
 	}
 
-	cleanedBody := filterProfanities(params.Body)
-	var query database.CreateChirpParams
-	query.Body = cleanedBody
-	query.UserID = userID
-
-	result, err := cfg.dbQueries.CreateChirp(r.Context(), query)
+	chirps := []chirpJSON{chirpToJSON(result)}
+	err = cfg.withAttachmentsTx(r.Context(), qtx, actor(user.ID), chirps)
 	if err != nil {
-		log.Printf("Error creating Chirp: %s", err)
+		log.Printf("Error retrieving attachments for chirp %v: %s", result.ID, err)
 		w.WriteHeader(500)
 		return
 	}
-
-	resp := chirpJSON{
-		Body:      result.Body,
-		ID:        result.ID,
-		CreatedAt: result.CreatedAt,
-		UpdatedAt: result.UpdatedAt,
-		UserID:    result.UserID,
+	resp := chirps[0]
+	event, err := queueWebhookDeliveries(r.Context(), qtx, events.Event{
+		Type:   events.ChirpCreated,
+		UserID: result.UserID,
+		Data:   resp,
+	})
+	if err != nil {
+		log.Printf("Error queueing webhooks for chirp %v: %s", result.ID, err)
+		w.WriteHeader(500)
+		return
 	}
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("Error committing chirp: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	cfg.events.Publish(r.Context(), event)
 
 	dat, err := json.Marshal(resp)
 	if err != nil {
@@ -265,10 +477,40 @@ func (cfg *apiConfig) handleValidateChirp(w http.ResponseWriter, r *http.Request
 }
 
 func (cfg *apiConfig) handleGetChirps(w http.ResponseWriter, r *http.Request) {
+	viewerID, err := cfg.viewer(r)
+	if err != nil {
+		log.Printf("Error authenticating request: %s", err)
+		w.WriteHeader(401)
+		return
+	}
+	// without limit or offset every chirp is returned
+	var limit sql.NullInt32
+	var offset int32
+	if r.URL.Query().Has("limit") || r.URL.Query().Has("offset") {
+		limit.Int32, offset, err = parsePagination(r)
+		if err != nil {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+		limit.Valid = true
+	}
+	sortDesc := r.URL.Query().Get("sort") == "desc"
+	pinnedFirst := r.URL.Query().Get("pinned") == "true"
+
 	authorID := r.URL.Query().Get("author_id")
+	if pinnedFirst && authorID == "" {
+		respondWithError(w, 400, "pinned=true requires author_id")
+		return
+	}
 	chirps := []database.Chirp{}
 	if authorID == "" {
-		allChirps, err := cfg.dbQueries.GetChirps(r.Context())
+		query := database.GetChirpsParams{
+			ViewerID: viewerID,
+			SortDesc: sortDesc,
+			Limit:    limit,
+			Offset:   offset,
+		}
+		allChirps, err := cfg.dbQueries.GetChirps(r.Context(), query)
 		if err != nil {
 			log.Printf("Error retrieving chirps: %s", err)
 			w.WriteHeader(500)
@@ -282,7 +524,15 @@ func (cfg *apiConfig) handleGetChirps(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(500)
 			return
 		}
-		authorChirps, err := cfg.dbQueries.GetChirpsByUserID(r.Context(), authorUUID)
+		query := database.GetChirpsByUserIDParams{
+			UserID:      authorUUID,
+			ViewerID:    viewerID,
+			PinnedFirst: pinnedFirst,
+			SortDesc:    sortDesc,
+			Limit:       limit,
+			Offset:      offset,
+		}
+		authorChirps, err := cfg.dbQueries.GetChirpsByUserID(r.Context(), query)
 		if errors.Is(err, sql.ErrNoRows) {
 			w.WriteHeader(404)
 			return
@@ -293,20 +543,15 @@ func (cfg *apiConfig) handleGetChirps(w http.ResponseWriter, r *http.Request) {
 		}
 		chirps = append(chirps, authorChirps...)
 	}
-	sortMethod := r.URL.Query().Get("sort")
-	if sortMethod == "desc" {
-		sort.Slice(chirps, func(i, j int) bool {
-			return chirps[i].CreatedAt.After(chirps[j].CreatedAt)
-		})
-	}
-
 	resp := make([]chirpJSON, len(chirps))
 	for i, chirp := range chirps {
-		resp[i].Body = chirp.Body
-		resp[i].ID = chirp.ID
-		resp[i].CreatedAt = chirp.CreatedAt
-		resp[i].UpdatedAt = chirp.CreatedAt
-		resp[i].UserID = chirp.UserID
+		resp[i] = chirpToJSON(chirp)
+	}
+	err = cfg.withAttachments(r.Context(), viewerID, resp)
+	if err != nil {
+		log.Printf("Error retrieving attachments: %s", err)
+		w.WriteHeader(500)
+		return
 	}
 	dat, err := json.Marshal(resp)
 	if err != nil {
@@ -326,13 +571,23 @@ func handleHealthz(w http.ResponseWriter, req *http.Request) {
 }
 
 func (cfg *apiConfig) handleGetChirpByID(w http.ResponseWriter, r *http.Request) {
+	viewerID, err := cfg.viewer(r)
+	if err != nil {
+		log.Printf("Error authenticating request: %s", err)
+		w.WriteHeader(401)
+		return
+	}
 	chirpID, err := uuid.Parse(r.PathValue("chirp_id"))
 	if err != nil {
 		log.Printf("Error parsing chirp_id into UUID: %s", err)
 		w.WriteHeader(500)
 		return
 	}
-	chirp, err := cfg.dbQueries.GetChirp(r.Context(), chirpID)
+	query := database.GetChirpParams{
+		ID:       chirpID,
+		ViewerID: viewerID,
+	}
+	chirp, err := cfg.dbQueries.GetChirp(r.Context(), query)
 	if errors.Is(err, sql.ErrNoRows) {
 		w.WriteHeader(404)
 		return
@@ -341,8 +596,14 @@ func (cfg *apiConfig) handleGetChirpByID(w http.ResponseWriter, r *http.Request)
 		w.WriteHeader(500)
 		return
 	}
-	result := chirpToJSON(chirp)
-	dat, err := json.Marshal(result)
+	result := []chirpJSON{chirpToJSON(chirp)}
+	err = cfg.withAttachments(r.Context(), viewerID, result)
+	if err != nil {
+		log.Printf("Error retrieving attachments: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	dat, err := json.Marshal(result[0])
 	if err != nil {
 		log.Printf("Error marshaling JSON: %s", err)
 		w.WriteHeader(500)
@@ -353,26 +614,19 @@ func (cfg *apiConfig) handleGetChirpByID(w http.ResponseWriter, r *http.Request)
 	w.Write(dat)
 }
 
-func (cfg *apiConfig) handleDeleteChirpByID(w http.ResponseWriter, r *http.Request) {
-	accessToken, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		log.Printf("Error retrieving access token: %s", err)
-		w.WriteHeader(401)
-		return
-	}
-	userID, err := auth.ValidateJWT(accessToken, cfg.jwtSecret)
-	if err != nil {
-		log.Printf("Error validating token: %s", err)
-		w.WriteHeader(401)
-		return
-	}
+func (cfg *apiConfig) handleDeleteChirpByID(w http.ResponseWriter, r *http.Request, user database.User) {
+	userID := user.ID
 	chirpID, err := uuid.Parse(r.PathValue("chirp_id"))
 	if err != nil {
 		log.Printf("Error parsing chirp_id: %s", err)
 		w.WriteHeader(500)
 		return
 	}
-	chirp, err := cfg.dbQueries.GetChirp(r.Context(), chirpID)
+	query := database.GetChirpParams{
+		ID:       chirpID,
+		ViewerID: actor(userID),
+	}
+	chirp, err := cfg.dbQueries.GetChirp(r.Context(), query)
 	if errors.Is(err, sql.ErrNoRows) {
 		w.WriteHeader(404)
 		return
@@ -385,12 +639,66 @@ func (cfg *apiConfig) handleDeleteChirpByID(w http.ResponseWriter, r *http.Reque
 		w.WriteHeader(403)
 		return
 	}
-	err = cfg.dbQueries.DeleteChirpByID(r.Context(), chirp.ID)
+	attached, err := cfg.dbQueries.GetMediaForChirps(r.Context(), []uuid.UUID{chirp.ID})
+	if err != nil {
+		log.Printf("Error retrieving media: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	mentions, err := cfg.dbQueries.GetMentionsForChirps(r.Context(), []uuid.UUID{chirp.ID})
+	if err != nil {
+		log.Printf("Error retrieving mentions: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	deleted := chirpDeletedJSON{
+		ID:           chirp.ID,
+		UserID:       chirp.UserID,
+		Visibility:   chirp.Visibility,
+		AuthorStatus: user.AccountStatus,
+		Mentions:     make([]uuid.UUID, len(mentions)),
+	}
+	for i, m := range mentions {
+		deleted.Mentions[i] = m.UserID
+	}
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+	err = qtx.DeleteChirpByID(r.Context(), chirp.ID)
 	if err != nil {
 		log.Printf("Error deleting chirp from database: %s", err)
 		w.WriteHeader(500)
 		return
 	}
+	event, err := queueWebhookDeliveries(r.Context(), qtx, events.Event{
+		Type:   events.ChirpDeleted,
+		UserID: chirp.UserID,
+		Data:   deleted,
+	})
+	if err != nil {
+		log.Printf("Error queueing webhooks for chirp %v: %s", chirp.ID, err)
+		w.WriteHeader(500)
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("Error committing chirp deletion: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	cfg.deleteMediaBlobs(r.Context(), attached)
+	cfg.recordAudit(r, auditEntry{
+		Action:     auditChirpDelete,
+		ActorID:    actor(userID),
+		TargetType: "chirp",
+		TargetID:   chirp.ID.String(),
+	})
+	cfg.events.Publish(r.Context(), event)
 	w.WriteHeader(204)
 }
 
@@ -417,6 +725,10 @@ func (cfg *apiConfig) handleLogin(w http.ResponseWriter, r *http.Request) {
 
 	user, err := cfg.dbQueries.GetUserByEmail(r.Context(), params.Email)
 	if err != nil {
+		cfg.recordAudit(r, auditEntry{
+			Action:   auditLoginFailed,
+			Metadata: map[string]interface{}{"email": params.Email},
+		})
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		w.WriteHeader(401)
 		fmt.Fprintf(w, "Incorrect email or password\n")
@@ -424,11 +736,33 @@ func (cfg *apiConfig) handleLogin(w http.ResponseWriter, r *http.Request) {
 	}
 	err = auth.CheckPasswordHash(user.HashedPassword, params.Password)
 	if err != nil {
+		cfg.recordAudit(r, auditEntry{
+			Action:     auditLoginFailed,
+			TargetType: "user",
+			TargetID:   user.ID.String(),
+			Metadata:   map[string]interface{}{"email": params.Email},
+		})
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		w.WriteHeader(401)
 		fmt.Fprintf(w, "Incorrect email or password\n")
 		return
 	}
+	if restriction := accountRestriction(user); restriction != "" {
+		cfg.recordAudit(r, auditEntry{
+			Action:     auditLoginFailed,
+			TargetType: "user",
+			TargetID:   user.ID.String(),
+			Metadata:   map[string]interface{}{"email": params.Email, "account_status": user.AccountStatus},
+		})
+		respondWithError(w, 403, restriction)
+		return
+	}
+	ent, err := cfg.entitlements(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error checking subscription for user %v: %s", user.ID, err)
+		w.WriteHeader(500)
+		return
+	}
 	token, err := auth.MakeJWT(user.ID, cfg.jwtSecret, expiresIn)
 	if err != nil {
 		log.Printf("Error generating token: %s", err)
@@ -454,6 +788,12 @@ func (cfg *apiConfig) handleLogin(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(500)
 		return
 	}
+	cfg.recordAudit(r, auditEntry{
+		Action:     auditLogin,
+		ActorID:    actor(user.ID),
+		TargetType: "user",
+		TargetID:   user.ID.String(),
+	})
 
 	type response struct {
 		ID           uuid.UUID `json:"id"`
@@ -463,6 +803,8 @@ func (cfg *apiConfig) handleLogin(w http.ResponseWriter, r *http.Request) {
 		Token        string    `json:"token"`
 		RefreshToken string    `json:"refresh_token"`
 		IsChirpyRed  bool      `json:"is_chirpy_red"`
+		Badge        *string   `json:"badge"`
+		Role         string    `json:"role"`
 	}
 
 	resp := response{
@@ -470,7 +812,9 @@ func (cfg *apiConfig) handleLogin(w http.ResponseWriter, r *http.Request) {
 		CreatedAt:    user.CreatedAt,
 		UpdatedAt:    user.UpdatedAt,
 		Email:        user.Email,
-		IsChirpyRed:  user.IsChirpyRed,
+		IsChirpyRed:  ent.Plan == entitlements.PlanChirpyRed,
+		Badge:        profileBadge(ent),
+		Role:         user.Role,
 		Token:        token,
 		RefreshToken: refreshToken,
 	}
@@ -510,6 +854,16 @@ func (cfg *apiConfig) handleRefresh(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(401)
 		return
 	}
+	user, err := cfg.dbQueries.GetUserByID(r.Context(), refreshTokenDB.UserID)
+	if err != nil {
+		log.Printf("Error retrieving user from database: %s", err)
+		w.WriteHeader(401)
+		return
+	}
+	if restriction := accountRestriction(user); restriction != "" {
+		respondWithError(w, 403, restriction)
+		return
+	}
 	expiresIn := 1 * time.Hour
 	token, err := auth.MakeJWT(refreshTokenDB.UserID, cfg.jwtSecret, expiresIn)
 	if err != nil {
@@ -517,6 +871,12 @@ func (cfg *apiConfig) handleRefresh(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(500)
 		return
 	}
+	cfg.recordAudit(r, auditEntry{
+		Action:     auditTokenRefresh,
+		ActorID:    actor(refreshTokenDB.UserID),
+		TargetType: "user",
+		TargetID:   refreshTokenDB.UserID.String(),
+	})
 	type response struct {
 		Token string `json:"token"`
 	}
@@ -547,30 +907,27 @@ func (cfg *apiConfig) handleRevoke(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(500)
 		return
 	}
+	entry := auditEntry{Action: auditTokenRevoke}
+	refreshTokenDB, err := cfg.dbQueries.GetRefreshTokenFromToken(r.Context(), refreshToken)
+	if err == nil {
+		entry.ActorID = actor(refreshTokenDB.UserID)
+		entry.TargetType = "user"
+		entry.TargetID = refreshTokenDB.UserID.String()
+	}
+	cfg.recordAudit(r, entry)
 	w.WriteHeader(204)
 	return
 }
 
-func (cfg *apiConfig) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
-	accessToken, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		log.Printf("Error retriving access token from header: %s", err)
-		w.WriteHeader(401)
-		return
-	}
-	userID, err := auth.ValidateJWT(accessToken, cfg.jwtSecret)
-	if err != nil {
-		log.Printf("Error validating access token: %s", err)
-		w.WriteHeader(401)
-		return
-	}
+func (cfg *apiConfig) handleUpdateUser(w http.ResponseWriter, r *http.Request, oldUser database.User) {
+	userID := oldUser.ID
 	type parameters struct {
 		Password string `json:"password"`
 		Email    string `json:"email"`
 	}
 	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
-	err = decoder.Decode(&params)
+	err := decoder.Decode(&params)
 	if err != nil {
 		log.Printf("Error decoding JSON: %s", err)
 		w.WriteHeader(500)
@@ -593,12 +950,37 @@ func (cfg *apiConfig) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(500)
 		return
 	}
+	if result.Email != oldUser.Email {
+		cfg.recordAudit(r, auditEntry{
+			Action:     auditEmailChange,
+			ActorID:    actor(userID),
+			TargetType: "user",
+			TargetID:   userID.String(),
+			Metadata:   map[string]interface{}{"old_email": oldUser.Email, "new_email": result.Email},
+		})
+	}
+	if auth.CheckPasswordHash(oldUser.HashedPassword, params.Password) != nil {
+		cfg.recordAudit(r, auditEntry{
+			Action:     auditPasswordChange,
+			ActorID:    actor(userID),
+			TargetType: "user",
+			TargetID:   userID.String(),
+		})
+	}
+	ent, err := cfg.entitlements(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error checking subscription for user %v: %s", userID, err)
+		w.WriteHeader(500)
+		return
+	}
 	resp := userJSON{
 		ID:          result.ID,
 		CreatedAt:   result.CreatedAt,
 		UpdatedAt:   result.UpdatedAt,
 		Email:       result.Email,
-		IsChirpyRed: result.IsChirpyRed,
+		IsChirpyRed: ent.Plan == entitlements.PlanChirpyRed,
+		Badge:       profileBadge(ent),
+		Role:        result.Role,
 	}
 	dat, err := json.Marshal(resp)
 	if err != nil {
@@ -611,79 +993,118 @@ func (cfg *apiConfig) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
 	w.Write(dat)
 }
 
-func (cfg *apiConfig) handlePolkaWebhooks(w http.ResponseWriter, r *http.Request) {
-	apiKey, err := auth.GetAPIKey(r.Header)
-	if err != nil || apiKey != cfg.polkaKey {
-		w.WriteHeader(401)
-		return
-	}
-	type parameters struct {
-		Event string `json:"event"`
-		Data  struct {
-			UserID uuid.UUID `json:"user_id"`
-		} `json:"data"`
-	}
-	decoder := json.NewDecoder(r.Body)
-	params := parameters{}
-	err = decoder.Decode(&params)
-	if err != nil {
-		log.Printf("Error decoding json parameters: %s", err)
-		w.WriteHeader(500)
-		return
-	}
-	if params.Event != "user.upgraded" {
-		w.WriteHeader(204)
-		return
-	}
-	user, err := cfg.dbQueries.GetUserByID(r.Context(), params.Data.UserID)
-	if errors.Is(err, sql.ErrNoRows) {
-		w.WriteHeader(404)
-		return
-	} else if err != nil {
-		log.Printf("Error retrieving user from database: %s", err)
-		w.WriteHeader(500)
-		return
-	}
-	err = cfg.dbQueries.UpgradeUserToChirpyRed(r.Context(), user.ID)
-	if err != nil {
-		log.Printf("Error upgrading user %v to Chirpy Red: %s", user.ID, err)
-		w.WriteHeader(500)
-		return
-	}
-	w.WriteHeader(204)
-	return
-}
-
 func main() {
 	godotenv.Load()
 	dbURL := os.Getenv("DB_URL")
 	secret := os.Getenv("SECRET")
 	platform := os.Getenv("PLATFORM")
-	polkaKey := os.Getenv("POLKA_KEY")
+	polkaSecrets := parsePolkaSecrets(os.Getenv("POLKA_WEBHOOK_SECRETS"), os.Getenv("POLKA_KEY"))
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
 		log.Fatal("ERROR: Unable to connect to database.")
 	}
-	dbQueries := database.New(db)
-	apiCfg := newApiConfig(dbQueries, platform, secret, polkaKey)
+	apiCfg := newApiConfig(db, platform, secret, polkaSecrets)
+	mediaStore, mediaFiles, err := newMediaStore()
+	if err != nil {
+		log.Fatalf("ERROR: Unable to set up media storage: %s", err)
+	}
+	apiCfg.media = mediaStore
+	err = apiCfg.reloadProfanityRules(context.Background())
+	if err != nil {
+		log.Fatalf("ERROR: Unable to load profanity rules: %s", err)
+	}
+	go apiCfg.watchProfanityRules(context.Background())
+	go apiCfg.runSubscriptionExpiry(context.Background())
+	go apiCfg.runWebhookWorker(context.Background())
+	go apiCfg.runWebhookDeliveryWorker(context.Background())
+	go apiCfg.runStreamListener(context.Background(), dbURL)
+	go apiCfg.runChirpScheduler(context.Background())
+	go apiCfg.runMediaCleanup(context.Background())
+	go apiCfg.runLinkPreviewWorker(context.Background())
 	mux := http.NewServeMux()
 	fs := http.StripPrefix("/app", http.FileServer(http.Dir(".")))
-	mux.HandleFunc("POST /api/chirps", apiCfg.handleValidateChirp)
+	mux.HandleFunc("POST /api/chirps", apiCfg.middlewareAuth(apiCfg.handleValidateChirp))
 	mux.HandleFunc("GET /api/chirps", apiCfg.handleGetChirps)
 	mux.HandleFunc("GET /api/chirps/{chirp_id}", apiCfg.handleGetChirpByID)
-	mux.HandleFunc("DELETE /api/chirps/{chirp_id}", apiCfg.handleDeleteChirpByID)
+	mux.HandleFunc("DELETE /api/chirps/{chirp_id}", apiCfg.middlewareAuth(apiCfg.handleDeleteChirpByID))
+	mux.HandleFunc("PUT /api/chirps/{chirp_id}", apiCfg.middlewareAuth(apiCfg.handleEditChirp))
+	mux.HandleFunc("GET /api/chirps/{chirp_id}/edits", apiCfg.handleGetChirpEdits)
+	mux.HandleFunc("POST /api/chirps/{chirp_id}/vote", apiCfg.middlewareAuth(apiCfg.handleVotePoll))
+	mux.HandleFunc("POST /api/chirps/{chirp_id}/bookmark", apiCfg.middlewareAuth(apiCfg.handleCreateBookmark))
+	mux.HandleFunc("DELETE /api/chirps/{chirp_id}/bookmark", apiCfg.middlewareAuth(apiCfg.handleDeleteBookmark))
+	mux.HandleFunc("GET /api/bookmarks", apiCfg.middlewareAuth(apiCfg.handleListBookmarks))
+	mux.HandleFunc("POST /api/chirps/{chirp_id}/pin", apiCfg.middlewareAuth(apiCfg.handlePinChirp))
+	mux.HandleFunc("DELETE /api/chirps/{chirp_id}/pin", apiCfg.middlewareAuth(apiCfg.handleUnpinChirp))
+	mux.HandleFunc("GET /api/chirps/scheduled", apiCfg.middlewareAuth(apiCfg.handleListScheduledChirps))
+	mux.HandleFunc("PUT /api/chirps/scheduled/{scheduled_id}", apiCfg.middlewareAuth(apiCfg.handleEditScheduledChirp))
+	mux.HandleFunc("DELETE /api/chirps/scheduled/{scheduled_id}", apiCfg.middlewareAuth(apiCfg.handleCancelScheduledChirp))
+	mux.HandleFunc("POST /api/media", apiCfg.middlewareAuth(apiCfg.handleUploadMedia))
+	if mediaFiles != nil {
+		mux.Handle("GET /media/", mediaFiles)
+	}
+	mux.HandleFunc("GET /api/drafts", apiCfg.middlewareAuth(apiCfg.handleListDrafts))
+	mux.HandleFunc("POST /api/drafts", apiCfg.middlewareAuth(apiCfg.handleCreateDraft))
+	mux.HandleFunc("GET /api/drafts/{draft_id}", apiCfg.middlewareAuth(apiCfg.handleGetDraft))
+	mux.HandleFunc("PUT /api/drafts/{draft_id}", apiCfg.middlewareAuth(apiCfg.handleUpdateDraft))
+	mux.HandleFunc("DELETE /api/drafts/{draft_id}", apiCfg.middlewareAuth(apiCfg.handleDeleteDraft))
+	mux.HandleFunc("POST /api/drafts/{draft_id}/publish", apiCfg.middlewareAuth(apiCfg.handlePublishDraft))
+	mux.HandleFunc("GET /api/stream", apiCfg.handleStream)
+	mux.Handle("GET /api/ws", apiCfg.realtimeServer())
+	mux.HandleFunc("GET /api/conversations", apiCfg.middlewareAuth(apiCfg.handleListConversations))
+	mux.HandleFunc("POST /api/conversations", apiCfg.middlewareAuth(apiCfg.handleCreateConversation))
+	mux.HandleFunc("GET /api/conversations/{conversation_id}/messages", apiCfg.middlewareAuth(apiCfg.handleGetMessages))
+	mux.HandleFunc("POST /api/conversations/{conversation_id}/messages", apiCfg.middlewareAuth(apiCfg.handleSendMessage))
+	mux.HandleFunc("POST /api/conversations/{conversation_id}/read", apiCfg.middlewareAuth(apiCfg.handleMarkConversationRead))
+	mux.HandleFunc("GET /api/notifications", apiCfg.middlewareAuth(apiCfg.handleListNotifications))
+	mux.HandleFunc("POST /api/notifications/read", apiCfg.middlewareAuth(apiCfg.handleMarkAllNotificationsRead))
+	mux.HandleFunc("POST /api/notifications/{notification_id}/read", apiCfg.middlewareAuth(apiCfg.handleMarkNotificationRead))
+	mux.HandleFunc("GET /api/notifications/preferences", apiCfg.middlewareAuth(apiCfg.handleGetNotificationPreferences))
+	mux.HandleFunc("PUT /api/notifications/preferences", apiCfg.middlewareAuth(apiCfg.handleUpdateNotificationPreferences))
+	mux.HandleFunc("POST /api/chirps/{chirp_id}/reports", apiCfg.middlewareAuth(apiCfg.handleCreateReport))
+	mux.HandleFunc("GET /api/mutes", apiCfg.middlewareAuth(apiCfg.handleListMutes))
+	mux.HandleFunc("POST /api/mutes", apiCfg.middlewareAuth(apiCfg.handleCreateMute))
+	mux.HandleFunc("DELETE /api/mutes/{mute_id}", apiCfg.middlewareAuth(apiCfg.handleDeleteMute))
+	mux.HandleFunc("GET /api/blocks", apiCfg.middlewareAuth(apiCfg.handleListBlocks))
+	mux.HandleFunc("POST /api/blocks", apiCfg.middlewareAuth(apiCfg.handleCreateBlock))
+	mux.HandleFunc("DELETE /api/blocks/{user_id}", apiCfg.middlewareAuth(apiCfg.handleDeleteBlock))
+	mux.HandleFunc("GET /api/follows", apiCfg.middlewareAuth(apiCfg.handleListFollowing))
+	mux.HandleFunc("GET /api/followers", apiCfg.middlewareAuth(apiCfg.handleListFollowers))
+	mux.HandleFunc("POST /api/follows", apiCfg.middlewareAuth(apiCfg.handleCreateFollow))
+	mux.HandleFunc("DELETE /api/follows/{user_id}", apiCfg.middlewareAuth(apiCfg.handleDeleteFollow))
+	mux.HandleFunc("GET /api/reports", apiCfg.middlewareAuth(apiCfg.handleListMyReports))
+	mux.HandleFunc("GET /api/moderation/queue", apiCfg.middlewareRequireRole(roleModerator, apiCfg.handleModerationQueue))
+	mux.HandleFunc("POST /api/moderation/chirps/{chirp_id}/actions", apiCfg.middlewareRequireRole(roleModerator, apiCfg.handleModerationAction))
 	mux.HandleFunc("GET /api/healthz", handleHealthz)
 	mux.HandleFunc("POST /api/login", apiCfg.handleLogin)
 	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlePolkaWebhooks)
 	mux.HandleFunc("POST /api/refresh", apiCfg.handleRefresh)
 	mux.HandleFunc("POST /api/revoke", apiCfg.handleRevoke)
 	mux.HandleFunc("POST /api/users", apiCfg.handleCreateUser)
-	mux.HandleFunc("PUT /api/users", apiCfg.handleUpdateUser)
-	mux.HandleFunc("GET /admin/metrics", apiCfg.handleMetrics)
-	mux.HandleFunc("POST /admin/reset", apiCfg.handleReset)
+	mux.HandleFunc("PUT /api/users", apiCfg.middlewareAuth(apiCfg.handleUpdateUser))
+	mux.HandleFunc("GET /api/users/preferences", apiCfg.middlewareAuth(apiCfg.handleGetUserPreferences))
+	mux.HandleFunc("PUT /api/users/preferences", apiCfg.middlewareAuth(apiCfg.handleUpdateUserPreferences))
+	mux.HandleFunc("GET /api/entitlements", apiCfg.middlewareAuth(apiCfg.handleGetEntitlements))
+	mux.HandleFunc("GET /api/webhooks", apiCfg.middlewareAuth(apiCfg.handleListWebhookEndpoints))
+	mux.HandleFunc("POST /api/webhooks", apiCfg.middlewareAuth(apiCfg.handleCreateWebhookEndpoint))
+	mux.HandleFunc("DELETE /api/webhooks/{endpoint_id}", apiCfg.middlewareAuth(apiCfg.handleDeleteWebhookEndpoint))
+	mux.HandleFunc("POST /api/webhooks/{endpoint_id}/ping", apiCfg.middlewareAuth(apiCfg.handlePingWebhookEndpoint))
+	mux.HandleFunc("GET /api/webhooks/{endpoint_id}/deliveries", apiCfg.middlewareAuth(apiCfg.handleListWebhookDeliveries))
+	mux.HandleFunc("GET /admin/metrics", apiCfg.middlewareRequireRole(roleAdmin, apiCfg.handleMetrics))
+	mux.HandleFunc("POST /admin/reset", apiCfg.middlewareRequireRole(roleAdmin, apiCfg.handleReset))
+	mux.HandleFunc("PUT /admin/users/{user_id}/role", apiCfg.middlewareRequireRole(roleAdmin, apiCfg.handleGrantRole))
+	mux.HandleFunc("DELETE /admin/users/{user_id}/role", apiCfg.middlewareRequireRole(roleAdmin, apiCfg.handleRevokeRole))
+	mux.HandleFunc("PUT /admin/users/{user_id}/status", apiCfg.middlewareRequireRole(roleAdmin, apiCfg.handleSetAccountStatus))
+	mux.HandleFunc("GET /admin/audit", apiCfg.middlewareRequireRole(roleAdmin, apiCfg.handleListAuditEvents))
+	mux.HandleFunc("GET /admin/webhooks", apiCfg.middlewareRequireRole(roleAdmin, apiCfg.handleListWebhookEvents))
+	mux.HandleFunc("GET /admin/webhooks/{event_id}", apiCfg.middlewareRequireRole(roleAdmin, apiCfg.handleGetWebhookEvent))
+	mux.HandleFunc("POST /admin/webhooks/{event_id}/replay", apiCfg.middlewareRequireRole(roleAdmin, apiCfg.handleReplayWebhookEvent))
+	mux.HandleFunc("GET /admin/profanities", apiCfg.middlewareRequireRole(roleAdmin, apiCfg.handleListProfanityRules))
+	mux.HandleFunc("POST /admin/profanities", apiCfg.middlewareRequireRole(roleAdmin, apiCfg.handleCreateProfanityRule))
+	mux.HandleFunc("PUT /admin/profanities/{rule_id}", apiCfg.middlewareRequireRole(roleAdmin, apiCfg.handleUpdateProfanityRule))
+	mux.HandleFunc("DELETE /admin/profanities/{rule_id}", apiCfg.middlewareRequireRole(roleAdmin, apiCfg.handleDeleteProfanityRule))
 	mux.Handle("/app/", apiCfg.middlewareMetricsInc(fs))
 	server := http.Server{}
 	server.Addr = port
-	server.Handler = mux
+	server.Handler = middlewareRequestID(mux)
 	log.Fatal(server.ListenAndServe())
 }
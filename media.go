@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lucoand/chirpy/internal/blob"
+	"github.com/lucoand/chirpy/internal/database"
+	"github.com/lucoand/chirpy/internal/media"
+)
+
+const (
+	maxUploadBytes = 10 << 20
+	// maxImagePixels allows for the largest photos phones take.
+	maxImagePixels = 50_000_000
+	thumbnailSize  = 320
+	maxChirpMedia  = 4
+	// Uploads that haven't been attached to a chirp after a day are
+	// deleted.
+	unattachedMediaTTL   = 24 * time.Hour
+	mediaCleanupInterval = time.Hour
+)
+
+type thumbnailJSON struct {
+	URL    string `json:"url"`
+	Width  int32  `json:"width"`
+	Height int32  `json:"height"`
+}
+
+type mediaJSON struct {
+	ID          uuid.UUID     `json:"id"`
+	URL         string        `json:"url"`
+	ContentType string        `json:"content_type"`
+	Width       int32         `json:"width"`
+	Height      int32         `json:"height"`
+	Thumbnail   thumbnailJSON `json:"thumbnail"`
+}
+
+func (cfg *apiConfig) mediaToJSON(m database.Medium) mediaJSON {
+	return mediaJSON{
+		ID:          m.ID,
+		URL:         cfg.media.URL(m.Key),
+		ContentType: m.ContentType,
+		Width:       m.Width,
+		Height:      m.Height,
+		Thumbnail: thumbnailJSON{
+			URL:    cfg.media.URL(m.ThumbnailKey),
+			Width:  m.ThumbnailWidth,
+			Height: m.ThumbnailHeight,
+		},
+	}
+}
+
+// newMediaStore sets up where uploads are kept, from the environment. For
+// the local store it also returns the handler that serves the files.
+func newMediaStore() (blob.Store, http.Handler, error) {
+	switch os.Getenv("MEDIA_STORE") {
+	case "", "local":
+		dir := os.Getenv("MEDIA_DIR")
+		if dir == "" {
+			dir = "media"
+		}
+		baseURL := os.Getenv("MEDIA_BASE_URL")
+		if baseURL == "" {
+			baseURL = "/media"
+		}
+		store, err := blob.NewLocalStore(dir, baseURL)
+		if err != nil {
+			return nil, nil, err
+		}
+		return store, http.StripPrefix("/media/", mediaFileServer(dir)), nil
+	case "s3":
+		store, err := blob.NewS3Store(blob.S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			PublicURL:       os.Getenv("S3_PUBLIC_URL"),
+		})
+		return store, nil, err
+	}
+	return nil, nil, fmt.Errorf("unknown MEDIA_STORE %q", os.Getenv("MEDIA_STORE"))
+}
+
+// mediaFileServer serves uploads from dir, without directory listings or
+// the temporary files uploads are written to.
+func mediaFileServer(dir string) http.Handler {
+	fs := http.FileServer(http.Dir(dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "" || strings.HasSuffix(r.URL.Path, "/") || strings.Contains(r.URL.Path, "/.") || strings.HasPrefix(r.URL.Path, ".") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		fs.ServeHTTP(w, r)
+	})
+}
+
+// handleUploadMedia accepts an image in the multipart field "file". It's
+// stored, without its metadata and with a thumbnail, until it is attached
+// to a chirp with media_ids.
+func (cfg *apiConfig) handleUploadMedia(w http.ResponseWriter, r *http.Request, user database.User) {
+	// Leave room for the multipart headers around the file.
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes+64<<10)
+	file, _, err := r.FormFile("file")
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		respondWithError(w, 413, "Image is too large")
+		return
+	} else if err != nil {
+		respondWithError(w, 400, "Expected an image in the \"file\" field")
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxUploadBytes+1))
+	if err != nil {
+		log.Printf("Error reading upload: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	if len(data) > maxUploadBytes {
+		respondWithError(w, 413, "Image is too large")
+		return
+	}
+
+	processed, err := media.Process(data, media.Limits{MaxPixels: maxImagePixels, ThumbnailSize: thumbnailSize})
+	if errors.Is(err, media.ErrUnsupported) {
+		respondWithError(w, 415, "Images must be JPEG, PNG or GIF")
+		return
+	} else if errors.Is(err, media.ErrTooLarge) {
+		respondWithError(w, 413, "Image has too many pixels")
+		return
+	} else if errors.Is(err, media.ErrInvalid) {
+		respondWithError(w, 400, "Invalid image")
+		return
+	} else if err != nil {
+		log.Printf("Error processing upload: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	original, thumbnail := processed.Original, processed.Thumbnail
+	// Re-encoding can make a file bigger than the upload was.
+	if len(original.Data) > maxUploadBytes {
+		respondWithError(w, 413, "Image is too large")
+		return
+	}
+
+	id := uuid.New()
+	key := id.String() + media.Extension(original.ContentType)
+	thumbnailKey := id.String() + "_thumb" + media.Extension(thumbnail.ContentType)
+	err = cfg.media.Put(r.Context(), key, original.Data, original.ContentType)
+	if err == nil {
+		err = cfg.media.Put(r.Context(), thumbnailKey, thumbnail.Data, thumbnail.ContentType)
+	}
+	if err != nil {
+		log.Printf("Error storing upload: %s", err)
+		cfg.deleteBlobs(context.Background(), key, thumbnailKey)
+		w.WriteHeader(500)
+		return
+	}
+	uploaded, err := cfg.dbQueries.CreateMedia(r.Context(), database.CreateMediaParams{
+		ID:              id,
+		UserID:          user.ID,
+		Key:             key,
+		ThumbnailKey:    thumbnailKey,
+		ContentType:     original.ContentType,
+		Width:           int32(original.Width),
+		Height:          int32(original.Height),
+		ThumbnailWidth:  int32(thumbnail.Width),
+		ThumbnailHeight: int32(thumbnail.Height),
+		SizeBytes:       int32(len(original.Data)),
+	})
+	if err != nil {
+		log.Printf("Error recording upload: %s", err)
+		cfg.deleteBlobs(context.Background(), key, thumbnailKey)
+		w.WriteHeader(500)
+		return
+	}
+	respondWithJSON(w, 201, cfg.mediaToJSON(uploaded))
+}
+
+// validateMediaIDs explains what is wrong with a chirp's media_ids, or
+// returns an empty string.
+func validateMediaIDs(ids []uuid.UUID) string {
+	if len(ids) > maxChirpMedia {
+		return fmt.Sprintf("A chirp can have at most %d attachments", maxChirpMedia)
+	}
+	seen := map[uuid.UUID]bool{}
+	for _, id := range ids {
+		if seen[id] {
+			return "media_ids contains duplicates"
+		}
+		seen[id] = true
+	}
+	return ""
+}
+
+// attachMedia attaches uploads to a new chirp in the order given. It
+// reports false if any of them isn't the user's or is already attached to
+// a chirp, in which case the caller should roll back.
+func attachMedia(ctx context.Context, q *database.Queries, userID uuid.UUID, chirpID uuid.UUID, ids []uuid.UUID) (bool, error) {
+	for i, id := range ids {
+		n, err := q.AttachMedia(ctx, database.AttachMediaParams{
+			ID:       id,
+			UserID:   userID,
+			ChirpID:  uuid.NullUUID{UUID: chirpID, Valid: true},
+			Position: int32(i),
+		})
+		if err != nil {
+			return false, err
+		}
+		if n == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// withMedia fills in the attachments of chirps.
+func (cfg *apiConfig) withMedia(ctx context.Context, q *database.Queries, chirps []chirpJSON) error {
+	ids := make([]uuid.UUID, len(chirps))
+	for i, c := range chirps {
+		ids[i] = c.ID
+	}
+	attached, err := q.GetMediaForChirps(ctx, ids)
+	if err != nil {
+		return err
+	}
+	byChirp := map[uuid.UUID][]mediaJSON{}
+	for _, m := range attached {
+		byChirp[m.ChirpID.UUID] = append(byChirp[m.ChirpID.UUID], cfg.mediaToJSON(m))
+	}
+	for i := range chirps {
+		if m, ok := byChirp[chirps[i].ID]; ok {
+			chirps[i].Media = m
+		}
+	}
+	return nil
+}
+
+// deleteBlobs removes stored files whose database rows are gone. Failures
+// are only logged: the files are unreachable either way.
+func (cfg *apiConfig) deleteBlobs(ctx context.Context, keys ...string) {
+	for _, key := range keys {
+		err := cfg.media.Delete(ctx, key)
+		if err != nil {
+			log.Printf("Error deleting stored file %s: %s", key, err)
+		}
+	}
+}
+
+func (cfg *apiConfig) deleteMediaBlobs(ctx context.Context, deleted []database.Medium) {
+	for _, m := range deleted {
+		cfg.deleteBlobs(ctx, m.Key, m.ThumbnailKey)
+	}
+}
+
+// runMediaCleanup deletes uploads that were never attached to a chirp.
+func (cfg *apiConfig) runMediaCleanup(ctx context.Context) {
+	ticker := time.NewTicker(mediaCleanupInterval)
+	defer ticker.Stop()
+	for {
+		deleted, err := cfg.dbQueries.DeleteUnattachedMedia(ctx, time.Now().Add(-unattachedMediaTTL).UTC())
+		if err != nil {
+			log.Printf("Error deleting unattached media: %s", err)
+		}
+		cfg.deleteMediaBlobs(ctx, deleted)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
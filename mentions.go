@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/lucoand/chirpy/internal/database"
+)
+
+const maxChirpMentions = 10
+
+// validateMentions explains what is wrong with a chirp's mentions, or
+// returns an empty string. Whether the users exist is checked separately.
+func validateMentions(authorID uuid.UUID, ids []uuid.UUID) string {
+	if len(ids) > maxChirpMentions {
+		return fmt.Sprintf("A chirp can mention at most %d users", maxChirpMentions)
+	}
+	seen := map[uuid.UUID]bool{}
+	for _, id := range ids {
+		if id == authorID {
+			return "You cannot mention yourself"
+		}
+		if seen[id] {
+			return "mentions contains duplicates"
+		}
+		seen[id] = true
+	}
+	return ""
+}
+
+// withMentions fills in the users chirps mention.
+func withMentions(ctx context.Context, q *database.Queries, chirps []chirpJSON) error {
+	ids := make([]uuid.UUID, len(chirps))
+	for i, c := range chirps {
+		ids[i] = c.ID
+	}
+	mentions, err := q.GetMentionsForChirps(ctx, ids)
+	if err != nil {
+		return err
+	}
+	byChirp := map[uuid.UUID][]uuid.UUID{}
+	for _, m := range mentions {
+		byChirp[m.ChirpID] = append(byChirp[m.ChirpID], m.UserID)
+	}
+	for i := range chirps {
+		if m, ok := byChirp[chirps[i].ID]; ok {
+			chirps[i].Mentions = m
+		}
+	}
+	return nil
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/lucoand/chirpy/internal/database"
+	"github.com/lucoand/chirpy/internal/events"
+)
+
+const (
+	maxReportReasonLength  = 500
+	defaultSuspensionHours = 7 * 24
+)
+
+const (
+	reportStatusOpen      = "open"
+	reportStatusDismissed = "dismissed"
+	reportStatusActioned  = "actioned"
+)
+
+const (
+	moderationDismiss       = "dismiss"
+	moderationHide          = "hide"
+	moderationSuspend       = "suspend"
+	moderationMarkSensitive = "mark_sensitive"
+)
+
+const (
+	auditReportCreate     = "chirp.reported"
+	auditModerationPrefix = "moderation."
+)
+
+type reportJSON struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ChirpID    uuid.UUID  `json:"chirp_id"`
+	ReporterID uuid.UUID  `json:"reporter_id"`
+	Reason     string     `json:"reason"`
+	Status     string     `json:"status"`
+	Resolution *string    `json:"resolution"`
+	ResolvedAt *time.Time `json:"resolved_at"`
+}
+
+func reportToJSON(r database.ChirpReport) reportJSON {
+	j := reportJSON{
+		ID:         r.ID,
+		CreatedAt:  r.CreatedAt,
+		ChirpID:    r.ChirpID,
+		ReporterID: r.ReporterID,
+		Reason:     r.Reason,
+		Status:     r.Status,
+	}
+	if r.Resolution.Valid {
+		j.Resolution = &r.Resolution.String
+	}
+	if r.ResolvedAt.Valid {
+		j.ResolvedAt = &r.ResolvedAt.Time
+	}
+	return j
+}
+
+func (cfg *apiConfig) handleCreateReport(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		Reason string `json:"reason"`
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirp_id"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid chirp_id")
+		return
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, "Invalid JSON")
+		return
+	}
+	params.Reason = strings.TrimSpace(params.Reason)
+	if params.Reason == "" || utf8.RuneCountInString(params.Reason) > maxReportReasonLength {
+		respondWithError(w, 400, "A reason of up to 500 characters is required")
+		return
+	}
+	chirp, err := cfg.dbQueries.GetChirp(r.Context(), database.GetChirpParams{
+		ID:       chirpID,
+		ViewerID: actor(user.ID),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		return
+	} else if err != nil {
+		log.Printf("Error retrieving chirp: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	if chirp.UserID == user.ID {
+		respondWithError(w, 400, "You cannot report your own chirp")
+		return
+	}
+	query := database.CreateChirpReportParams{
+		ChirpID:    chirp.ID,
+		ReporterID: user.ID,
+		Reason:     params.Reason,
+	}
+	report, err := cfg.dbQueries.CreateChirpReport(r.Context(), query)
+	if isUniqueViolation(err) {
+		respondWithError(w, 409, "You have already reported this chirp")
+		return
+	} else if err != nil {
+		log.Printf("Error creating report: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	cfg.recordAudit(r, auditEntry{
+		Action:     auditReportCreate,
+		ActorID:    actor(user.ID),
+		TargetType: "chirp",
+		TargetID:   chirp.ID.String(),
+		Metadata:   map[string]interface{}{"report_id": report.ID},
+	})
+	respondWithJSON(w, 201, reportToJSON(report))
+}
+
+// handleListMyReports lets reporters follow up on what happened to their
+// reports.
+func (cfg *apiConfig) handleListMyReports(w http.ResponseWriter, r *http.Request, user database.User) {
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	query := database.GetChirpReportsByReporterParams{
+		ReporterID: user.ID,
+		Limit:      limit,
+		Offset:     offset,
+	}
+	reports, err := cfg.dbQueries.GetChirpReportsByReporter(r.Context(), query)
+	if err != nil {
+		log.Printf("Error retrieving reports: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	resp := make([]reportJSON, len(reports))
+	for i, report := range reports {
+		resp[i] = reportToJSON(report)
+	}
+	respondWithJSON(w, 200, resp)
+}
+
+func (cfg *apiConfig) handleModerationQueue(w http.ResponseWriter, r *http.Request, moderator database.User) {
+	type queueItem struct {
+		ChirpID         uuid.UUID    `json:"chirp_id"`
+		Body            string       `json:"body"`
+		UserID          uuid.UUID    `json:"user_id"`
+		CreatedAt       time.Time    `json:"created_at"`
+		NeedsReview     bool         `json:"needs_review"`
+		ReportCount     int64        `json:"report_count"`
+		FirstReportedAt time.Time    `json:"first_reported_at"`
+		Reports         []reportJSON `json:"reports"`
+	}
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	query := database.ListModerationQueueParams{
+		Limit:  limit,
+		Offset: offset,
+	}
+	rows, err := cfg.dbQueries.ListModerationQueue(r.Context(), query)
+	if err != nil {
+		log.Printf("Error retrieving moderation queue: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	chirpIDs := make([]uuid.UUID, len(rows))
+	for i, row := range rows {
+		chirpIDs[i] = row.ID
+	}
+	reports, err := cfg.dbQueries.GetOpenChirpReportsForChirps(r.Context(), chirpIDs)
+	if err != nil {
+		log.Printf("Error retrieving reports: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	reportsByChirp := map[uuid.UUID][]reportJSON{}
+	for _, report := range reports {
+		reportsByChirp[report.ChirpID] = append(reportsByChirp[report.ChirpID], reportToJSON(report))
+	}
+	resp := make([]queueItem, len(rows))
+	for i, row := range rows {
+		resp[i] = queueItem{
+			ChirpID:         row.ID,
+			Body:            row.Body,
+			UserID:          row.UserID,
+			CreatedAt:       row.CreatedAt,
+			NeedsReview:     row.NeedsReview,
+			ReportCount:     row.ReportCount,
+			FirstReportedAt: row.FirstReportedAt,
+			Reports:         reportsByChirp[row.ID],
+		}
+		if resp[i].Reports == nil {
+			resp[i].Reports = []reportJSON{}
+		}
+	}
+	respondWithJSON(w, 200, resp)
+}
+
+// suspensionConflict explains why a moderator can't suspend author until
+// the given time, or returns an empty string. A suspension never replaces a
+// stricter status: a ban, a shadowban (which it would lift once it ran out),
+// or a suspension that already lasts longer, such as one set by an admin.
+func suspensionConflict(author database.User, until time.Time) string {
+	switch author.AccountStatus {
+	case accountBanned:
+		return "The author is already banned"
+	case accountShadowbanned:
+		return "The author is shadowbanned"
+	case accountSuspended:
+		if author.SuspendedUntil.Valid && !author.SuspendedUntil.Time.Before(until) {
+			return "The author is already suspended for longer"
+		}
+	}
+	return ""
+}
+
+// handleModerationAction resolves every open report against a chirp with one
+// of dismiss, hide (the chirp), suspend (its author) or mark_sensitive (the
+// chirp, with an optional content warning). Chirps can be marked sensitive
+// whether or not anyone reported them.
+func (cfg *apiConfig) handleModerationAction(w http.ResponseWriter, r *http.Request, moderator database.User) {
+	type parameters struct {
+		Action         string `json:"action"`
+		Note           string `json:"note"`
+		SuspendHours   int    `json:"suspend_hours"`
+		ContentWarning string `json:"content_warning"`
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirp_id"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid chirp_id")
+		return
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, "Invalid JSON")
+		return
+	}
+	status := reportStatusActioned
+	var warning sql.NullString
+	switch params.Action {
+	case moderationDismiss:
+		status = reportStatusDismissed
+	case moderationHide:
+	case moderationMarkSensitive:
+		var problem string
+		warning, _, problem = cfg.validateContentWarning(params.ContentWarning)
+		if problem != "" {
+			respondWithError(w, 400, problem)
+			return
+		}
+	case moderationSuspend:
+		if params.SuspendHours == 0 {
+			params.SuspendHours = defaultSuspensionHours
+		}
+		if params.SuspendHours < 0 {
+			respondWithError(w, 400, "suspend_hours must be positive")
+			return
+		}
+	default:
+		respondWithError(w, 400, "action must be one of dismiss, hide, suspend or mark_sensitive")
+		return
+	}
+
+	chirp, err := cfg.dbQueries.GetChirpIncludingHidden(r.Context(), chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		return
+	} else if err != nil {
+		log.Printf("Error retrieving chirp: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	metadata := map[string]interface{}{"note": params.Note}
+	var until time.Time
+	if params.Action == moderationSuspend {
+		author, err := cfg.dbQueries.GetUserByID(r.Context(), chirp.UserID)
+		if err != nil {
+			log.Printf("Error retrieving user from database: %s", err)
+			w.WriteHeader(500)
+			return
+		}
+		if hasRole(author, roleModerator) {
+			respondWithError(w, 403, "Moderators cannot suspend other moderators or admins")
+			return
+		}
+		until = time.Now().Add(time.Duration(params.SuspendHours) * time.Hour)
+		if problem := suspensionConflict(author, until); problem != "" {
+			respondWithError(w, 409, problem)
+			return
+		}
+		metadata["user_id"] = author.ID
+		metadata["suspend_hours"] = params.SuspendHours
+	}
+	if params.Action == moderationMarkSensitive && warning.Valid {
+		metadata["content_warning"] = warning.String
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+	switch params.Action {
+	case moderationHide:
+		err = qtx.HideChirp(r.Context(), chirp.ID)
+	case moderationMarkSensitive:
+		err = markChirpSensitive(r.Context(), qtx, chirp, moderator.ID, warning)
+		if err == nil {
+			err = qtx.ClearChirpReview(r.Context(), chirp.ID)
+		}
+	case moderationSuspend:
+		reason := params.Note
+		if reason == "" {
+			reason = "Suspended by a moderator"
+		}
+		_, err = qtx.SetUserAccountStatus(r.Context(), database.SetUserAccountStatusParams{
+			ID:             chirp.UserID,
+			AccountStatus:  accountSuspended,
+			StatusReason:   sql.NullString{String: reason, Valid: true},
+			SuspendedUntil: sql.NullTime{Time: until.UTC(), Valid: true},
+		})
+		if err == nil {
+			err = qtx.ClearChirpReview(r.Context(), chirp.ID)
+		}
+	default:
+		err = qtx.ClearChirpReview(r.Context(), chirp.ID)
+	}
+	if err != nil {
+		log.Printf("Error applying moderation action %s: %s", params.Action, err)
+		w.WriteHeader(500)
+		return
+	}
+	reports, err := qtx.ResolveChirpReports(r.Context(), database.ResolveChirpReportsParams{
+		ChirpID:    chirp.ID,
+		Status:     status,
+		Resolution: sql.NullString{String: params.Action, Valid: true},
+		ResolvedBy: actor(moderator.ID),
+	})
+	if err != nil {
+		log.Printf("Error resolving reports: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("Error committing moderation action: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	metadata["resolved_reports"] = len(reports)
+	cfg.recordAudit(r, auditEntry{
+		Action:     auditModerationPrefix + params.Action,
+		ActorID:    actor(moderator.ID),
+		TargetType: "chirp",
+		TargetID:   chirp.ID.String(),
+		Metadata:   metadata,
+	})
+	for _, report := range reports {
+		cfg.events.Publish(r.Context(), events.Event{
+			Type:   events.ReportResolved,
+			UserID: report.ReporterID,
+			Data:   reportToJSON(report),
+		})
+	}
+
+	type response struct {
+		ChirpID         uuid.UUID    `json:"chirp_id"`
+		Action          string       `json:"action"`
+		ResolvedReports []reportJSON `json:"resolved_reports"`
+	}
+	resp := response{
+		ChirpID:         chirp.ID,
+		Action:          params.Action,
+		ResolvedReports: make([]reportJSON, len(reports)),
+	}
+	for i, report := range reports {
+		resp.ResolvedReports[i] = reportToJSON(report)
+	}
+	respondWithJSON(w, 200, resp)
+}
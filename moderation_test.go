@@ -0,0 +1,33 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/lucoand/chirpy/internal/database"
+)
+
+func TestSuspensionConflict(t *testing.T) {
+	now := time.Now()
+	until := now.Add(24 * time.Hour)
+	tests := []struct {
+		name      string
+		author    database.User
+		wantAllow bool
+	}{
+		{"active", database.User{AccountStatus: accountActive}, true},
+		{"suspension has ended", database.User{AccountStatus: accountSuspended, SuspendedUntil: sql.NullTime{Time: now.Add(-time.Hour), Valid: true}}, true},
+		{"shorter suspension", database.User{AccountStatus: accountSuspended, SuspendedUntil: sql.NullTime{Time: now.Add(time.Hour), Valid: true}}, true},
+		{"longer suspension", database.User{AccountStatus: accountSuspended, SuspendedUntil: sql.NullTime{Time: now.Add(30 * 24 * time.Hour), Valid: true}}, false},
+		{"same suspension", database.User{AccountStatus: accountSuspended, SuspendedUntil: sql.NullTime{Time: until, Valid: true}}, false},
+		{"banned", database.User{AccountStatus: accountBanned}, false},
+		{"shadowbanned", database.User{AccountStatus: accountShadowbanned}, false},
+	}
+	for _, tt := range tests {
+		problem := suspensionConflict(tt.author, until)
+		if (problem == "") != tt.wantAllow {
+			t.Errorf("TestSuspensionConflict %s: expected allowed to be %t, got %q", tt.name, tt.wantAllow, problem)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lucoand/chirpy/internal/database"
+)
+
+const maxMutePhraseLength = 100
+
+type muteJSON struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Phrase    string    `json:"phrase"`
+}
+
+func muteToJSON(m database.UserMute) muteJSON {
+	return muteJSON{
+		ID:        m.ID,
+		CreatedAt: m.CreatedAt,
+		Phrase:    m.Phrase,
+	}
+}
+
+// mutePattern turns a phrase into the regular expression Postgres matches
+// chirps against with ~*. It only matches whole words, and any run of
+// whitespace in the phrase matches any run of whitespace in a chirp.
+func mutePattern(phrase string) string {
+	words := strings.Fields(phrase)
+	for i, word := range words {
+		words[i] = regexp.QuoteMeta(word)
+	}
+	return `(^|[^[:alnum:]_])` + strings.Join(words, `\s+`) + `([^[:alnum:]_]|$)`
+}
+
+func (cfg *apiConfig) handleListMutes(w http.ResponseWriter, r *http.Request, user database.User) {
+	mutes, err := cfg.dbQueries.GetUserMutes(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error retrieving mutes: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	resp := make([]muteJSON, len(mutes))
+	for i, mute := range mutes {
+		resp[i] = muteToJSON(mute)
+	}
+	respondWithJSON(w, 200, resp)
+}
+
+func (cfg *apiConfig) handleCreateMute(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		Phrase string `json:"phrase"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, "Invalid JSON")
+		return
+	}
+	phrase := strings.Join(strings.Fields(strings.ToLower(params.Phrase)), " ")
+	if phrase == "" || len(phrase) > maxMutePhraseLength {
+		respondWithError(w, 400, "A phrase of up to 100 characters is required")
+		return
+	}
+	query := database.CreateUserMuteParams{
+		UserID:  user.ID,
+		Phrase:  phrase,
+		Pattern: mutePattern(phrase),
+	}
+	mute, err := cfg.dbQueries.CreateUserMute(r.Context(), query)
+	if isUniqueViolation(err) {
+		respondWithError(w, 409, "You have already muted that phrase")
+		return
+	} else if err != nil {
+		log.Printf("Error creating mute: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	respondWithJSON(w, 201, muteToJSON(mute))
+}
+
+func (cfg *apiConfig) handleDeleteMute(w http.ResponseWriter, r *http.Request, user database.User) {
+	muteID, err := uuid.Parse(r.PathValue("mute_id"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid mute_id")
+		return
+	}
+	query := database.DeleteUserMuteParams{
+		ID:     muteID,
+		UserID: user.ID,
+	}
+	deleted, err := cfg.dbQueries.DeleteUserMute(r.Context(), query)
+	if err != nil {
+		log.Printf("Error deleting mute: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	if deleted == 0 {
+		w.WriteHeader(404)
+		return
+	}
+	w.WriteHeader(204)
+}
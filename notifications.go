@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lucoand/chirpy/internal/database"
+	"github.com/lucoand/chirpy/internal/events"
+)
+
+// Notification types, which users can turn off one by one. A type is only
+// added here along with the rule that creates it.
+const (
+	notificationSubscription = "subscription"
+	notificationReport       = "report"
+	notificationFollow       = "follow"
+	notificationMention      = "mention"
+)
+
+var notificationTypes = []string{
+	notificationSubscription,
+	notificationReport,
+	notificationFollow,
+	notificationMention,
+}
+
+// notificationRules decide which notifications a domain event creates. An
+// event type without a rule creates none.
+var notificationRules = map[string]func(events.Event) []pendingNotification{
+	events.UserUpgraded:   notifySubject(notificationSubscription),
+	events.UserDowngraded: notifySubject(notificationSubscription),
+	events.ReportResolved: notifyReporter,
+	events.FollowCreated:  notifyFollowee,
+	events.ChirpCreated:   notifyMentioned,
+}
+
+// pendingNotification is a notification a rule wants to send.
+type pendingNotification struct {
+	UserID  uuid.UUID
+	Type    string
+	ActorID uuid.NullUUID
+	ChirpID uuid.NullUUID
+}
+
+// notifySubject notifies the user an event is about.
+func notifySubject(notificationType string) func(events.Event) []pendingNotification {
+	return func(e events.Event) []pendingNotification {
+		return []pendingNotification{{UserID: e.UserID, Type: notificationType}}
+	}
+}
+
+// notifyReporter tells the user who filed a report that it was resolved.
+// The moderator who resolved it isn't named.
+func notifyReporter(e events.Event) []pendingNotification {
+	p := pendingNotification{UserID: e.UserID, Type: notificationReport}
+	if report, ok := e.Data.(reportJSON); ok {
+		p.ChirpID = actor(report.ChirpID)
+	}
+	return []pendingNotification{p}
+}
+
+// notifyFollowee tells a user who started following them.
+func notifyFollowee(e events.Event) []pendingNotification {
+	p := pendingNotification{UserID: e.UserID, Type: notificationFollow}
+	if follow, ok := e.Data.(followCreatedJSON); ok {
+		p.ActorID = actor(follow.FollowerID)
+	}
+	return []pendingNotification{p}
+}
+
+// notifyMentioned tells the users a new chirp mentions about it.
+func notifyMentioned(e events.Event) []pendingNotification {
+	chirp, ok := e.Data.(chirpJSON)
+	if !ok {
+		return nil
+	}
+	pending := make([]pendingNotification, len(chirp.Mentions))
+	for i, id := range chirp.Mentions {
+		pending[i] = pendingNotification{
+			UserID:  id,
+			Type:    notificationMention,
+			ActorID: actor(chirp.UserID),
+			ChirpID: actor(chirp.ID),
+		}
+	}
+	return pending
+}
+
+type notificationJSON struct {
+	ID        uuid.UUID       `json:"id"`
+	CreatedAt time.Time       `json:"created_at"`
+	Type      string          `json:"type"`
+	Event     string          `json:"event"`
+	ActorID   *uuid.UUID      `json:"actor_id"`
+	ChirpID   *uuid.UUID      `json:"chirp_id"`
+	Data      json.RawMessage `json:"data"`
+	ReadAt    *time.Time      `json:"read_at"`
+}
+
+func notificationToJSON(n database.Notification) notificationJSON {
+	resp := notificationJSON{
+		ID:        n.ID,
+		CreatedAt: n.CreatedAt,
+		Type:      n.Type,
+		Event:     n.EventType,
+		Data:      n.Data,
+	}
+	if n.ActorID.Valid {
+		resp.ActorID = &n.ActorID.UUID
+	}
+	if n.ChirpID.Valid {
+		resp.ChirpID = &n.ChirpID.UUID
+	}
+	if n.ReadAt.Valid {
+		resp.ReadAt = &n.ReadAt.Time
+	}
+	return resp
+}
+
+// createNotifications is the bus subscriber that fills users' inboxes. Each
+// user gets at most one notification per event.
+func (cfg *apiConfig) createNotifications(ctx context.Context, e events.Event) {
+	rule, ok := notificationRules[e.Type]
+	if !ok {
+		return
+	}
+	pending := rule(e)
+	// A shadowbanned user's actions reach nobody else. All of an event's
+	// notifications share its actor.
+	if len(pending) > 0 && pending[0].ActorID.Valid {
+		actorUser, err := cfg.dbQueries.GetUserByID(ctx, pending[0].ActorID.UUID)
+		if err != nil {
+			log.Printf("Error retrieving actor of %s event %v: %s", e.Type, e.ID, err)
+			return
+		}
+		if actorUser.AccountStatus == accountShadowbanned {
+			return
+		}
+	}
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		log.Printf("Error marshalling %s event %v for notifications: %s", e.Type, e.ID, err)
+		return
+	}
+	for _, p := range pending {
+		// Nobody is told about their own actions.
+		if p.ActorID.Valid && p.ActorID.UUID == p.UserID {
+			continue
+		}
+		n, err := cfg.dbQueries.CreateNotification(ctx, database.CreateNotificationParams{
+			UserID:    p.UserID,
+			Type:      p.Type,
+			EventID:   e.ID,
+			EventType: e.Type,
+			ActorID:   p.ActorID,
+			ChirpID:   p.ChirpID,
+			Data:      data,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			// Turned off by the user, or already notified.
+			continue
+		} else if err != nil {
+			log.Printf("Error creating %s notification for %v: %s", p.Type, p.UserID, err)
+			continue
+		}
+		cfg.events.Publish(ctx, events.Event{
+			Type:   events.NotificationCreated,
+			UserID: n.UserID,
+			Data:   notificationToJSON(n),
+		})
+	}
+}
+
+func (cfg *apiConfig) handleListNotifications(w http.ResponseWriter, r *http.Request, user database.User) {
+	type response struct {
+		Notifications []notificationJSON `json:"notifications"`
+		UnreadCount   int64              `json:"unread_count"`
+	}
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	query := database.GetNotificationsParams{
+		UserID:     user.ID,
+		UnreadOnly: r.URL.Query().Get("unread") == "true",
+		Limit:      limit,
+		Offset:     offset,
+	}
+	notifications, err := cfg.dbQueries.GetNotifications(r.Context(), query)
+	if err != nil {
+		log.Printf("Error retrieving notifications: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	unread, err := cfg.dbQueries.CountUnreadNotifications(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error counting unread notifications: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	resp := response{
+		Notifications: make([]notificationJSON, len(notifications)),
+		UnreadCount:   unread,
+	}
+	for i, n := range notifications {
+		resp.Notifications[i] = notificationToJSON(n)
+	}
+	respondWithJSON(w, 200, resp)
+}
+
+func (cfg *apiConfig) handleMarkNotificationRead(w http.ResponseWriter, r *http.Request, user database.User) {
+	notificationID, err := uuid.Parse(r.PathValue("notification_id"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid notification ID")
+		return
+	}
+	query := database.MarkNotificationReadParams{
+		ID:     notificationID,
+		UserID: user.ID,
+	}
+	n, err := cfg.dbQueries.MarkNotificationRead(r.Context(), query)
+	if err != nil {
+		log.Printf("Error marking notification read: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	if n == 0 {
+		respondWithError(w, 404, "Notification not found")
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handleMarkAllNotificationsRead(w http.ResponseWriter, r *http.Request, user database.User) {
+	_, err := cfg.dbQueries.MarkAllNotificationsRead(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error marking notifications read: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// notificationPreferences returns whether each notification type is on for
+// a user. Types are on unless the user turned them off.
+func (cfg *apiConfig) notificationPreferences(ctx context.Context, userID uuid.UUID) (map[string]bool, error) {
+	prefs, err := cfg.dbQueries.GetNotificationPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	resp := map[string]bool{}
+	for _, t := range notificationTypes {
+		resp[t] = true
+	}
+	for _, p := range prefs {
+		if _, ok := resp[p.Type]; ok {
+			resp[p.Type] = p.Enabled
+		}
+	}
+	return resp, nil
+}
+
+func (cfg *apiConfig) handleGetNotificationPreferences(w http.ResponseWriter, r *http.Request, user database.User) {
+	prefs, err := cfg.notificationPreferences(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error retrieving notification preferences: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	respondWithJSON(w, 200, prefs)
+}
+
+// handleUpdateNotificationPreferences turns notification types on or off.
+// Types left out of the request keep their setting.
+func (cfg *apiConfig) handleUpdateNotificationPreferences(w http.ResponseWriter, r *http.Request, user database.User) {
+	params := map[string]bool{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, "Invalid JSON")
+		return
+	}
+	known := map[string]bool{}
+	for _, t := range notificationTypes {
+		known[t] = true
+	}
+	for t := range params {
+		if !known[t] {
+			respondWithError(w, 400, "Unknown notification type: "+t)
+			return
+		}
+	}
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+	for t, enabled := range params {
+		err = qtx.SetNotificationPreference(r.Context(), database.SetNotificationPreferenceParams{
+			UserID:  user.ID,
+			Type:    t,
+			Enabled: enabled,
+		})
+		if err != nil {
+			log.Printf("Error saving notification preference: %s", err)
+			w.WriteHeader(500)
+			return
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("Error committing notification preferences: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	prefs, err := cfg.notificationPreferences(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error retrieving notification preferences: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	respondWithJSON(w, 200, prefs)
+}
@@ -0,0 +1,524 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lucoand/chirpy/internal/auth"
+	"github.com/lucoand/chirpy/internal/database"
+	"github.com/lucoand/chirpy/internal/events"
+	"github.com/lucoand/chirpy/internal/netguard"
+)
+
+const (
+	maxWebhookEndpoints    = 10
+	maxWebhookURLLength    = 2048
+	webhookDeliveryTimeout = 10 * time.Second
+	webhookPingEvent       = "ping"
+	webhookDelivered       = "delivered"
+)
+
+const (
+	auditWebhookEndpointCreate = "webhook_endpoint.created"
+	auditWebhookEndpointDelete = "webhook_endpoint.deleted"
+)
+
+// webhookEventTypes are the domain events an endpoint can subscribe to.
+var webhookEventTypes = map[string]bool{
+	events.ChirpCreated:   true,
+	events.ChirpDeleted:   true,
+	events.UserUpgraded:   true,
+	events.UserDowngraded: true,
+}
+
+// webhookClient sends outbound webhooks. Redirects are not followed, so a
+// delivery only ever goes to the registered URL, and the dialer refuses
+// private addresses whatever the URL's hostname resolves to at the time.
+var webhookClient = newWebhookClient(netguard.PublicAddr)
+
+func newWebhookClient(allow func(netip.Addr) bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: webhookDeliveryTimeout,
+		Control: netguard.Control(allow),
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			// Going through a proxy would hide the real destination from
+			// the dialer.
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   webhookDeliveryTimeout,
+			ResponseHeaderTimeout: webhookDeliveryTimeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		Timeout: webhookDeliveryTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// webhookPayload is the body of every outbound webhook.
+type webhookPayload struct {
+	ID        uuid.UUID   `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// queueWebhookDeliveries queues an event for every endpoint subscribed to
+// it. Endpoints hear about events concerning their owner, and admins'
+// all_users endpoints hear about everyone's.
+//
+// It is called with the transaction that makes the change the event
+// describes, so the deliveries are committed with it and can't be lost to a
+// crash in between. It fills in the event's ID and time, and the returned
+// event is what should be published once the transaction commits.
+func queueWebhookDeliveries(ctx context.Context, q *database.Queries, e events.Event) (events.Event, error) {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	if e.OccurredAt.IsZero() {
+		e.OccurredAt = time.Now().UTC()
+	}
+	payload, err := json.Marshal(webhookPayload{
+		ID:        e.ID,
+		Type:      e.Type,
+		CreatedAt: e.OccurredAt,
+		Data:      e.Data,
+	})
+	if err != nil {
+		return e, err
+	}
+	_, err = q.EnqueueWebhookDeliveries(ctx, database.EnqueueWebhookDeliveriesParams{
+		EventID:   e.ID,
+		EventType: e.Type,
+		Payload:   payload,
+		UserID:    e.UserID,
+	})
+	return e, err
+}
+
+// wakeWebhookDeliveries is subscribed to the event bus. Deliveries were
+// queued when the event was recorded, so once it is published they can be
+// sent.
+func (cfg *apiConfig) wakeWebhookDeliveries(ctx context.Context, e events.Event) {
+	if webhookEventTypes[e.Type] {
+		cfg.wakeWebhookDeliveryWorker()
+	}
+}
+
+func (cfg *apiConfig) wakeWebhookDeliveryWorker() {
+	select {
+	case cfg.webhookDeliveryWake <- struct{}{}:
+	default:
+	}
+}
+
+func (cfg *apiConfig) runWebhookDeliveryWorker(ctx context.Context) {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+	for {
+		cfg.sendWebhookDeliveries(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-cfg.webhookDeliveryWake:
+		}
+	}
+}
+
+// sendWebhookDeliveries sends every delivery that is due. Like the inbox,
+// deliveries are claimed with SKIP LOCKED and a lease. A batch is sent
+// concurrently so one slow endpoint doesn't hold up the rest.
+func (cfg *apiConfig) sendWebhookDeliveries(ctx context.Context) {
+	for {
+		claimed, err := cfg.dbQueries.ClaimWebhookDeliveries(ctx, webhookBatchSize)
+		if err != nil {
+			log.Printf("Error claiming webhook deliveries: %s", err)
+			return
+		}
+		var wg sync.WaitGroup
+		for _, d := range claimed {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				cfg.sendWebhookDelivery(ctx, d)
+			}()
+		}
+		wg.Wait()
+		if len(claimed) < webhookBatchSize {
+			return
+		}
+	}
+}
+
+func (cfg *apiConfig) sendWebhookDelivery(ctx context.Context, d database.ClaimWebhookDeliveriesRow) {
+	status, err := postWebhook(ctx, d)
+	responseStatus := sql.NullInt32{Int32: int32(status), Valid: status != 0}
+	if err == nil {
+		err = cfg.dbQueries.MarkWebhookDeliveryDelivered(ctx, database.MarkWebhookDeliveryDeliveredParams{
+			ID:             d.ID,
+			ResponseStatus: responseStatus,
+		})
+		if err != nil {
+			log.Printf("Error recording webhook delivery %v: %s", d.ID, err)
+		}
+		return
+	}
+	lastError := sql.NullString{String: err.Error(), Valid: true}
+	if d.Attempts >= webhookMaxAttempts {
+		err = cfg.dbQueries.DeadLetterWebhookDelivery(ctx, database.DeadLetterWebhookDeliveryParams{
+			ID:             d.ID,
+			ResponseStatus: responseStatus,
+			LastError:      lastError,
+		})
+	} else {
+		err = cfg.dbQueries.RetryWebhookDelivery(ctx, database.RetryWebhookDeliveryParams{
+			ResponseStatus: responseStatus,
+			LastError:      lastError,
+			BackoffSeconds: webhookBackoff(d.Attempts).Seconds(),
+			ID:             d.ID,
+		})
+	}
+	if err != nil {
+		log.Printf("Error recording failure of webhook delivery %v: %s", d.ID, err)
+	}
+}
+
+// postWebhook sends a delivery and returns the response's status code, or 0
+// if there was no response. Any 2xx status is success.
+func postWebhook(ctx context.Context, d database.ClaimWebhookDeliveriesRow) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", d.Url, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set("X-Chirpy-Event", d.EventType)
+	req.Header.Set("X-Chirpy-Delivery", d.ID.String())
+	req.Header.Set("X-Chirpy-Timestamp", timestamp)
+	req.Header.Set("X-Chirpy-Signature", auth.SignWebhook(d.Secret, timestamp, d.Payload))
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+func validateWebhookURL(raw string) error {
+	if len(raw) > maxWebhookURLLength {
+		return errors.New("url is too long")
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	// Hostnames are checked when deliveries connect, since what they resolve
+	// to can change; these can be turned away now.
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errors.New("url must not point at a private address")
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !netguard.PublicAddr(addr) {
+		return errors.New("url must not point at a private address")
+	}
+	return nil
+}
+
+type webhookEndpointJSON struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	AllUsers  bool      `json:"all_users"`
+	Secret    string    `json:"secret,omitempty"`
+}
+
+func webhookEndpointToJSON(e database.WebhookEndpoint) webhookEndpointJSON {
+	return webhookEndpointJSON{
+		ID:        e.ID,
+		CreatedAt: e.CreatedAt,
+		URL:       e.Url,
+		Events:    e.Events,
+		AllUsers:  e.AllUsers,
+	}
+}
+
+type webhookDeliveryJSON struct {
+	ID             uuid.UUID       `json:"id"`
+	EndpointID     uuid.UUID       `json:"endpoint_id"`
+	EventID        uuid.UUID       `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	ResponseStatus *int32          `json:"response_status"`
+	LastError      *string         `json:"last_error"`
+	CreatedAt      time.Time       `json:"created_at"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+}
+
+func webhookDeliveryToJSON(d database.WebhookDelivery) webhookDeliveryJSON {
+	j := webhookDeliveryJSON{
+		ID:         d.ID,
+		EndpointID: d.EndpointID,
+		EventID:    d.EventID,
+		EventType:  d.EventType,
+		Payload:    d.Payload,
+		Status:     d.Status,
+		Attempts:   d.Attempts,
+		CreatedAt:  d.CreatedAt,
+	}
+	if d.ResponseStatus.Valid {
+		j.ResponseStatus = &d.ResponseStatus.Int32
+	}
+	if d.LastError.Valid {
+		j.LastError = &d.LastError.String
+	}
+	if d.Status != webhookDelivered && d.Status != webhookDead {
+		j.NextAttemptAt = &d.NextAttemptAt
+	}
+	if d.DeliveredAt.Valid {
+		j.DeliveredAt = &d.DeliveredAt.Time
+	}
+	return j
+}
+
+func (cfg *apiConfig) handleCreateWebhookEndpoint(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		URL      string   `json:"url"`
+		Events   []string `json:"events"`
+		AllUsers bool     `json:"all_users"`
+	}
+	params := parameters{}
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		w.WriteHeader(400)
+		return
+	}
+	err = validateWebhookURL(params.URL)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	if len(params.Events) == 0 {
+		respondWithError(w, 400, "events must list at least one event type")
+		return
+	}
+	subscribed := []string{}
+	seen := map[string]bool{}
+	for _, e := range params.Events {
+		if !webhookEventTypes[e] {
+			respondWithError(w, 400, fmt.Sprintf("Unknown event type %q", e))
+			return
+		}
+		if !seen[e] {
+			seen[e] = true
+			subscribed = append(subscribed, e)
+		}
+	}
+	if params.AllUsers && !hasRole(user, roleAdmin) {
+		respondWithError(w, 403, "Only admins can receive events for all users")
+		return
+	}
+	count, err := cfg.dbQueries.CountWebhookEndpoints(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error counting webhook endpoints: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	if count >= maxWebhookEndpoints {
+		respondWithError(w, 409, fmt.Sprintf("You can register at most %d webhook endpoints", maxWebhookEndpoints))
+		return
+	}
+	secret, err := newWebhookSecret()
+	if err != nil {
+		log.Printf("Error generating webhook secret: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	endpoint, err := cfg.dbQueries.CreateWebhookEndpoint(r.Context(), database.CreateWebhookEndpointParams{
+		OwnerID:  user.ID,
+		Url:      params.URL,
+		Secret:   secret,
+		Events:   subscribed,
+		AllUsers: params.AllUsers,
+	})
+	if err != nil {
+		log.Printf("Error creating webhook endpoint: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	cfg.recordAudit(r, auditEntry{
+		Action:     auditWebhookEndpointCreate,
+		ActorID:    actor(user.ID),
+		TargetType: "webhook_endpoint",
+		TargetID:   endpoint.ID.String(),
+		Metadata:   map[string]interface{}{"url": endpoint.Url, "events": endpoint.Events, "all_users": endpoint.AllUsers},
+	})
+	// The secret is only ever shown here.
+	resp := webhookEndpointToJSON(endpoint)
+	resp.Secret = endpoint.Secret
+	respondWithJSON(w, 201, resp)
+}
+
+func (cfg *apiConfig) handleListWebhookEndpoints(w http.ResponseWriter, r *http.Request, user database.User) {
+	endpoints, err := cfg.dbQueries.GetWebhookEndpoints(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error retrieving webhook endpoints: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	resp := make([]webhookEndpointJSON, len(endpoints))
+	for i, e := range endpoints {
+		resp[i] = webhookEndpointToJSON(e)
+	}
+	respondWithJSON(w, 200, resp)
+}
+
+func (cfg *apiConfig) handleDeleteWebhookEndpoint(w http.ResponseWriter, r *http.Request, user database.User) {
+	endpointID, err := uuid.Parse(r.PathValue("endpoint_id"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid endpoint ID")
+		return
+	}
+	deleted, err := cfg.dbQueries.DeleteWebhookEndpoint(r.Context(), database.DeleteWebhookEndpointParams{
+		ID:      endpointID,
+		OwnerID: user.ID,
+	})
+	if err != nil {
+		log.Printf("Error deleting webhook endpoint %v: %s", endpointID, err)
+		w.WriteHeader(500)
+		return
+	}
+	if deleted == 0 {
+		w.WriteHeader(404)
+		return
+	}
+	cfg.recordAudit(r, auditEntry{
+		Action:     auditWebhookEndpointDelete,
+		ActorID:    actor(user.ID),
+		TargetType: "webhook_endpoint",
+		TargetID:   endpointID.String(),
+	})
+	w.WriteHeader(204)
+}
+
+// ownedWebhookEndpoint looks up one of the user's endpoints from the request
+// path, writing the error response if it can't.
+func (cfg *apiConfig) ownedWebhookEndpoint(w http.ResponseWriter, r *http.Request, user database.User) (database.WebhookEndpoint, bool) {
+	endpointID, err := uuid.Parse(r.PathValue("endpoint_id"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid endpoint ID")
+		return database.WebhookEndpoint{}, false
+	}
+	endpoint, err := cfg.dbQueries.GetWebhookEndpoint(r.Context(), database.GetWebhookEndpointParams{
+		ID:      endpointID,
+		OwnerID: user.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		return database.WebhookEndpoint{}, false
+	} else if err != nil {
+		log.Printf("Error retrieving webhook endpoint %v: %s", endpointID, err)
+		w.WriteHeader(500)
+		return database.WebhookEndpoint{}, false
+	}
+	return endpoint, true
+}
+
+func (cfg *apiConfig) handlePingWebhookEndpoint(w http.ResponseWriter, r *http.Request, user database.User) {
+	endpoint, ok := cfg.ownedWebhookEndpoint(w, r, user)
+	if !ok {
+		return
+	}
+	eventID := uuid.New()
+	payload, err := json.Marshal(webhookPayload{
+		ID:        eventID,
+		Type:      webhookPingEvent,
+		CreatedAt: time.Now().UTC(),
+		Data:      map[string]interface{}{"endpoint_id": endpoint.ID},
+	})
+	if err != nil {
+		log.Printf("Error marshalling ping: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	delivery, err := cfg.dbQueries.CreateWebhookDelivery(r.Context(), database.CreateWebhookDeliveryParams{
+		EndpointID: endpoint.ID,
+		EventID:    eventID,
+		EventType:  webhookPingEvent,
+		Payload:    payload,
+	})
+	if err != nil {
+		log.Printf("Error queueing ping for webhook endpoint %v: %s", endpoint.ID, err)
+		w.WriteHeader(500)
+		return
+	}
+	cfg.wakeWebhookDeliveryWorker()
+	respondWithJSON(w, 202, webhookDeliveryToJSON(delivery))
+}
+
+func (cfg *apiConfig) handleListWebhookDeliveries(w http.ResponseWriter, r *http.Request, user database.User) {
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	endpoint, ok := cfg.ownedWebhookEndpoint(w, r, user)
+	if !ok {
+		return
+	}
+	deliveries, err := cfg.dbQueries.GetWebhookDeliveries(r.Context(), database.GetWebhookDeliveriesParams{
+		EndpointID: endpoint.ID,
+		Limit:      limit,
+		Offset:     offset,
+	})
+	if err != nil {
+		log.Printf("Error retrieving deliveries for webhook endpoint %v: %s", endpoint.ID, err)
+		w.WriteHeader(500)
+		return
+	}
+	resp := make([]webhookDeliveryJSON, len(deliveries))
+	for i, d := range deliveries {
+		resp[i] = webhookDeliveryToJSON(d)
+	}
+	respondWithJSON(w, 200, resp)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/lucoand/chirpy/internal/database"
+	"github.com/lucoand/chirpy/internal/netguard"
+)
+
+func TestValidateWebhookURL(t *testing.T) {
+	tests := []struct {
+		url   string
+		valid bool
+	}{
+		{"https://example.com/chirpy-hook", true},
+		{"http://93.184.216.34:8080/hook", true},
+		{"ftp://example.com/", false},
+		{"/relative", false},
+		{"http://localhost/hook", false},
+		{"http://LOCALHOST./hook", false},
+		{"http://api.localhost/hook", false},
+		{"http://127.0.0.1:8080/hook", false},
+		{"http://10.0.0.1/hook", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"http://[::1]/hook", false},
+		{"http://[::ffff:192.168.0.1]/hook", false},
+		{"http://[fe80::1%25eth0]/hook", false},
+	}
+	for _, tt := range tests {
+		err := validateWebhookURL(tt.url)
+		if (err == nil) != tt.valid {
+			t.Errorf("TestValidateWebhookURL %s: expected valid to be %t, got error %v", tt.url, tt.valid, err)
+		}
+	}
+}
+
+func TestPostWebhookBlocksPrivateAddresses(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer srv.Close()
+
+	status, err := postWebhook(context.Background(), database.ClaimWebhookDeliveriesRow{
+		ID:        uuid.New(),
+		EventType: webhookPingEvent,
+		Payload:   []byte("{}"),
+		Url:       srv.URL,
+		Secret:    "whsec_test",
+	})
+	if !errors.Is(err, netguard.ErrBlocked) {
+		t.Errorf("TestPostWebhookBlocksPrivateAddresses: expected ErrBlocked, got %v", err)
+	}
+	if status != 0 || hits.Load() != 0 {
+		t.Errorf("TestPostWebhookBlocksPrivateAddresses: server was reached")
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/lucoand/chirpy/internal/database"
+	"github.com/lucoand/chirpy/internal/entitlements"
+)
+
+// handlePinChirp pins one of the user's chirps to their profile. How many
+// they can pin depends on their plan. Pinning a pinned chirp does nothing.
+func (cfg *apiConfig) handlePinChirp(w http.ResponseWriter, r *http.Request, user database.User) {
+	chirp, ok := cfg.visibleChirp(w, r, user)
+	if !ok {
+		return
+	}
+	if chirp.UserID != user.ID {
+		respondWithError(w, 403, "You can only pin your own chirps")
+		return
+	}
+	ent, err := cfg.entitlements(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error checking entitlements for user %v: %s", user.ID, err)
+		w.WriteHeader(500)
+		return
+	}
+
+	// The user's row is locked while their pins are counted, so that
+	// concurrent requests can't pin past the limit between them.
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+	err = qtx.LockUser(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error locking user %v: %s", user.ID, err)
+		w.WriteHeader(500)
+		return
+	}
+	pinned, err := qtx.IsChirpPinned(r.Context(), chirp.ID)
+	if err != nil {
+		log.Printf("Error checking pinned chirps: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	if pinned {
+		w.WriteHeader(204)
+		return
+	}
+	count, err := qtx.CountPinnedChirps(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error counting pinned chirps: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	if limit := ent.Limit(entitlements.PinnedChirps); count >= int64(limit) {
+		respondWithError(w, 403, fmt.Sprintf("You can pin at most %d chirps", limit))
+		return
+	}
+	err = qtx.PinChirp(r.Context(), database.PinChirpParams{
+		ChirpID: chirp.ID,
+		UserID:  user.ID,
+	})
+	if err != nil {
+		log.Printf("Error pinning chirp: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("Error committing pin: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handleUnpinChirp(w http.ResponseWriter, r *http.Request, user database.User) {
+	chirpID, err := uuid.Parse(r.PathValue("chirp_id"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid chirp ID")
+		return
+	}
+	err = cfg.dbQueries.UnpinChirp(r.Context(), database.UnpinChirpParams{
+		ChirpID: chirpID,
+		UserID:  user.ID,
+	})
+	if err != nil {
+		log.Printf("Error unpinning chirp: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.WriteHeader(204)
+}
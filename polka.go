@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lucoand/chirpy/internal/auth"
+	"github.com/lucoand/chirpy/internal/database"
+	"github.com/lucoand/chirpy/internal/entitlements"
+	"github.com/lucoand/chirpy/internal/events"
+)
+
+const (
+	// polkaTimestampTolerance is how far a webhook's timestamp may be from
+	// our clock before it is treated as a replay.
+	polkaTimestampTolerance = 5 * time.Minute
+	maxWebhookBodyBytes     = 1 << 20
+)
+
+// parsePolkaSecrets returns the comma-separated secrets used to verify Polka
+// webhooks. During a rotation both the old and new secret are listed. The
+// single legacy POLKA_KEY is used if no list is configured.
+func parsePolkaSecrets(secrets string, legacyKey string) []string {
+	parsed := []string{}
+	for _, secret := range strings.Split(secrets, ",") {
+		secret = strings.TrimSpace(secret)
+		if secret != "" {
+			parsed = append(parsed, secret)
+		}
+	}
+	if len(parsed) == 0 && legacyKey != "" {
+		parsed = append(parsed, legacyKey)
+	}
+	return parsed
+}
+
+func (cfg *apiConfig) handlePolkaWebhooks(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBodyBytes))
+	if err != nil {
+		log.Printf("Error reading webhook body: %s", err)
+		w.WriteHeader(400)
+		return
+	}
+	signature, timestamp := auth.GetWebhookSignature(r.Header, "Polka")
+	err = auth.VerifyWebhook(cfg.polkaSecrets, signature, timestamp, body, polkaTimestampTolerance, time.Now())
+	if err != nil {
+		log.Printf("Error verifying Polka webhook: %s", err)
+		w.WriteHeader(401)
+		return
+	}
+	eventID := r.Header.Get("X-Polka-Event-ID")
+	if eventID == "" {
+		respondWithError(w, 400, "Missing X-Polka-Event-ID header")
+		return
+	}
+	params := polkaEvent{}
+	err = json.Unmarshal(body, &params)
+	if err != nil {
+		log.Printf("Error decoding json parameters: %s", err)
+		w.WriteHeader(400)
+		return
+	}
+
+	// The event is stored before it is acknowledged and processed later by
+	// the webhook worker, so a failure while processing it is retried
+	// instead of being lost.
+	created, err := cfg.dbQueries.CreateWebhookEvent(r.Context(), database.CreateWebhookEventParams{
+		Source:    webhookSourcePolka,
+		EventID:   eventID,
+		EventType: params.Event,
+		Payload:   body,
+	})
+	if err != nil {
+		log.Printf("Error storing Polka event %s: %s", eventID, err)
+		w.WriteHeader(500)
+		return
+	}
+	if created == 0 {
+		log.Printf("Ignoring duplicate Polka event %s", eventID)
+		w.WriteHeader(204)
+		return
+	}
+	cfg.wakeWebhookWorker()
+	w.WriteHeader(204)
+}
+
+type polkaEvent struct {
+	Event string `json:"event"`
+	Data  struct {
+		UserID           uuid.UUID  `json:"user_id"`
+		Plan             string     `json:"plan"`
+		CurrentPeriodEnd *time.Time `json:"current_period_end"`
+	} `json:"data"`
+}
+
+var errPolkaUserNotFound = fmt.Errorf("%w: user not found", errWebhookPermanent)
+
+// applyPolkaEvent moves the user's subscription through its lifecycle. It
+// reports whether the subscription changed; events that don't apply to the
+// subscription's current state, and unknown events, are acknowledged and
+// ignored.
+func applyPolkaEvent(ctx context.Context, q *database.Queries, event polkaEvent) (database.Subscription, bool, error) {
+	switch event.Event {
+	case "user.upgraded", "subscription.renewed",
+		"user.downgraded", "payment.failed", "payment.refunded":
+	default:
+		return database.Subscription{}, false, nil
+	}
+	_, err := q.GetUserByID(ctx, event.Data.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Subscription{}, false, errPolkaUserNotFound
+	} else if err != nil {
+		return database.Subscription{}, false, err
+	}
+
+	var sub database.Subscription
+	switch event.Event {
+	case "user.upgraded", "subscription.renewed":
+		plan := event.Data.Plan
+		if plan == "" {
+			plan = entitlements.PlanChirpyRed
+		}
+		var periodEnd time.Time
+		if event.Data.CurrentPeriodEnd != nil {
+			periodEnd = event.Data.CurrentPeriodEnd.UTC()
+		} else {
+			periodEnd, err = nextPeriodEnd(ctx, q, event.Data.UserID)
+			if err != nil {
+				return database.Subscription{}, false, err
+			}
+		}
+		sub, err = q.ActivateSubscription(ctx, database.ActivateSubscriptionParams{
+			UserID:           event.Data.UserID,
+			Plan:             plan,
+			CurrentPeriodEnd: periodEnd,
+		})
+	case "user.downgraded":
+		sub, err = q.CancelSubscription(ctx, event.Data.UserID)
+	case "payment.failed":
+		sub, err = q.MarkSubscriptionPastDue(ctx, event.Data.UserID)
+	case "payment.refunded":
+		sub, err = q.RefundSubscription(ctx, event.Data.UserID)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Subscription{}, false, nil
+	} else if err != nil {
+		return database.Subscription{}, false, err
+	}
+	return sub, true, nil
+}
+
+// processPolkaWebhook applies a stored Polka event. If the subscription
+// changed, it returns the audit entry for the change, and the domain event
+// for upgrades and downgrades, whose webhooks are queued in q.
+func processPolkaWebhook(ctx context.Context, q *database.Queries, e database.WebhookEvent) (webhookEffects, error) {
+	event := polkaEvent{}
+	err := json.Unmarshal(e.Payload, &event)
+	if err != nil {
+		return webhookEffects{}, fmt.Errorf("%w: %s", errWebhookPermanent, err)
+	}
+	sub, changed, err := applyPolkaEvent(ctx, q, event)
+	if err != nil || !changed {
+		return webhookEffects{}, err
+	}
+	effects := webhookEffects{}
+	var domainEvent events.Event
+	switch event.Event {
+	case "user.upgraded":
+		domainEvent = subscriptionEvent(events.UserUpgraded, sub)
+	case "user.downgraded":
+		domainEvent = subscriptionEvent(events.UserDowngraded, sub)
+	}
+	if domainEvent.Type != "" {
+		domainEvent, err = queueWebhookDeliveries(ctx, q, domainEvent)
+		if err != nil {
+			return webhookEffects{}, err
+		}
+		effects.events = append(effects.events, domainEvent)
+	}
+	effects.audit = &auditEntry{
+		Action:     auditSubscriptionPrefix + event.Event,
+		TargetType: "user",
+		TargetID:   sub.UserID.String(),
+		Metadata: map[string]interface{}{
+			"source":             webhookSourcePolka,
+			"event_id":           e.EventID,
+			"plan":               sub.Plan,
+			"status":             sub.Status,
+			"current_period_end": sub.CurrentPeriodEnd,
+		},
+	}
+	return effects, nil
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lucoand/chirpy/internal/database"
+)
+
+const (
+	minPollOptions      = 2
+	maxPollOptions      = 4
+	maxPollOptionLength = 25
+	minPollDuration     = 5 * time.Minute
+	maxPollDuration     = 7 * 24 * time.Hour
+)
+
+// pollParameters is a poll as sent with a new chirp.
+type pollParameters struct {
+	Options  []string  `json:"options"`
+	ClosesAt time.Time `json:"closes_at"`
+}
+
+type pollOptionJSON struct {
+	Text  string `json:"text"`
+	Votes *int64 `json:"votes"`
+}
+
+// pollJSON is a chirp's poll as a particular viewer sees it. Vote counts are
+// nil until the viewer has voted or the poll has closed, so that early
+// results don't sway anyone.
+type pollJSON struct {
+	ClosesAt   time.Time        `json:"closes_at"`
+	Closed     bool             `json:"closed"`
+	Options    []pollOptionJSON `json:"options"`
+	TotalVotes *int64           `json:"total_votes"`
+	// VotedFor is the index of the viewer's choice in Options.
+	VotedFor *int32 `json:"voted_for"`
+}
+
+// validatePoll checks a new poll the way validateChirp checks a chirp. It
+// returns the options to store and whether any of them needs review, or a
+// message explaining why the poll was rejected.
+func (cfg *apiConfig) validatePoll(poll pollParameters) ([]string, bool, string) {
+	if len(poll.Options) < minPollOptions || len(poll.Options) > maxPollOptions {
+		return nil, false, fmt.Sprintf("A poll must have %d to %d options", minPollOptions, maxPollOptions)
+	}
+	options := make([]string, len(poll.Options))
+	flagged := false
+	for i, option := range poll.Options {
+		filtered, problem := cfg.validateText("Poll option", option, maxPollOptionLength)
+		if problem != "" {
+			return nil, false, problem
+		}
+		for _, previous := range options[:i] {
+			if strings.EqualFold(previous, filtered.Body) {
+				return nil, false, "Poll options must be different"
+			}
+		}
+		options[i] = filtered.Body
+		flagged = flagged || filtered.Flagged
+	}
+	if poll.ClosesAt.Before(time.Now().Add(minPollDuration)) {
+		return nil, false, "A poll must stay open for at least 5 minutes"
+	}
+	if poll.ClosesAt.After(time.Now().Add(maxPollDuration)) {
+		return nil, false, "A poll can stay open for at most 7 days"
+	}
+	return options, flagged, ""
+}
+
+// createPoll adds a poll to a new chirp.
+func createPoll(ctx context.Context, q *database.Queries, chirpID uuid.UUID, closesAt time.Time, options []string) error {
+	err := q.CreatePoll(ctx, database.CreatePollParams{
+		ChirpID:  chirpID,
+		ClosesAt: closesAt.UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	for i, option := range options {
+		err = q.CreatePollOption(ctx, database.CreatePollOptionParams{
+			ChirpID:  chirpID,
+			Position: int32(i),
+			Text:     option,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withPolls fills in the polls of chirps as viewerID sees them.
+func withPolls(ctx context.Context, q *database.Queries, viewerID uuid.NullUUID, chirps []chirpJSON) error {
+	ids := make([]uuid.UUID, len(chirps))
+	for i, c := range chirps {
+		ids[i] = c.ID
+	}
+	rows, err := q.GetPollsForChirps(ctx, database.GetPollsForChirpsParams{
+		ViewerID: viewerID,
+		ChirpIds: ids,
+	})
+	if err != nil {
+		return err
+	}
+	polls := map[uuid.UUID]*pollJSON{}
+	for _, row := range rows {
+		poll, ok := polls[row.ChirpID]
+		if !ok {
+			poll = &pollJSON{
+				ClosesAt: row.ClosesAt,
+				Closed:   !row.ClosesAt.After(time.Now().UTC()),
+				Options:  []pollOptionJSON{},
+			}
+			polls[row.ChirpID] = poll
+		}
+		votes := row.Votes
+		poll.Options = append(poll.Options, pollOptionJSON{Text: row.Text, Votes: &votes})
+		if row.Chosen {
+			position := row.Position
+			poll.VotedFor = &position
+		}
+	}
+	for _, poll := range polls {
+		if !poll.Closed && poll.VotedFor == nil {
+			for i := range poll.Options {
+				poll.Options[i].Votes = nil
+			}
+			continue
+		}
+		total := int64(0)
+		for _, option := range poll.Options {
+			total += *option.Votes
+		}
+		poll.TotalVotes = &total
+	}
+	for i := range chirps {
+		chirps[i].Poll = polls[chirps[i].ID]
+	}
+	return nil
+}
+
+// handleVotePoll records the user's vote on a chirp's poll. Voting again
+// before the poll closes changes the vote.
+func (cfg *apiConfig) handleVotePoll(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		Option *int32 `json:"option"`
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirp_id"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid chirp ID")
+		return
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, "Invalid JSON")
+		return
+	}
+	chirp, err := cfg.dbQueries.GetChirp(r.Context(), database.GetChirpParams{
+		ID:       chirpID,
+		ViewerID: actor(user.ID),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Chirp not found")
+		return
+	} else if err != nil {
+		log.Printf("Error retrieving chirp: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	poll, err := cfg.dbQueries.GetPoll(r.Context(), chirp.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Chirp has no poll")
+		return
+	} else if err != nil {
+		log.Printf("Error retrieving poll: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	if !poll.ClosesAt.After(time.Now().UTC()) {
+		respondWithError(w, 409, "Poll is closed")
+		return
+	}
+	count, err := cfg.dbQueries.CountPollOptions(r.Context(), chirp.ID)
+	if err != nil {
+		log.Printf("Error counting poll options: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	if params.Option == nil || *params.Option < 0 || int64(*params.Option) >= count {
+		respondWithError(w, 400, "Invalid option")
+		return
+	}
+	err = cfg.dbQueries.UpsertPollVote(r.Context(), database.UpsertPollVoteParams{
+		ChirpID:  chirp.ID,
+		UserID:   user.ID,
+		Position: *params.Option,
+	})
+	if err != nil {
+		log.Printf("Error recording vote: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	resp := []chirpJSON{{ID: chirp.ID}}
+	err = withPolls(r.Context(), cfg.dbQueries, actor(user.ID), resp)
+	if err != nil {
+		log.Printf("Error retrieving poll: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	respondWithJSON(w, 200, resp[0].Poll)
+}
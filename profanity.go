@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/lucoand/chirpy/internal/database"
+	"github.com/lucoand/chirpy/internal/profanity"
+)
+
+// profanityRefreshInterval bounds how stale another instance's changes to the
+// word list can be.
+const profanityRefreshInterval = 1 * time.Minute
+
+const (
+	auditProfanityCreate = "admin.profanity_rule_created"
+	auditProfanityUpdate = "admin.profanity_rule_updated"
+	auditProfanityDelete = "admin.profanity_rule_deleted"
+)
+
+// profanityCache holds the filter built from the profanity_rules table.
+type profanityCache struct {
+	mu     sync.RWMutex
+	filter *profanity.Filter
+}
+
+func (c *profanityCache) get() *profanity.Filter {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.filter
+}
+
+func (c *profanityCache) set(f *profanity.Filter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.filter = f
+}
+
+func (cfg *apiConfig) reloadProfanityRules(ctx context.Context) error {
+	rules, err := cfg.dbQueries.ListProfanityRules(ctx)
+	if err != nil {
+		return err
+	}
+	filterRules := make([]profanity.Rule, len(rules))
+	for i, rule := range rules {
+		filterRules[i] = profanity.Rule{
+			Word: rule.Word,
+			Mode: profanity.Mode(rule.Mode),
+		}
+	}
+	cfg.profanity.set(profanity.NewFilter(filterRules))
+	return nil
+}
+
+// watchProfanityRules periodically reloads the rules so that changes made
+// through another server instance are picked up.
+func (cfg *apiConfig) watchProfanityRules(ctx context.Context) {
+	ticker := time.NewTicker(profanityRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := cfg.reloadProfanityRules(ctx)
+			if err != nil {
+				log.Printf("Error reloading profanity rules: %s", err)
+			}
+		}
+	}
+}
+
+func (cfg *apiConfig) filterProfanities(body string) profanity.Result {
+	f := cfg.profanity.get()
+	if f == nil {
+		return profanity.Result{Body: body}
+	}
+	return f.Apply(body)
+}
+
+type profanityRuleJSON struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Word      string    `json:"word"`
+	Mode      string    `json:"mode"`
+}
+
+func profanityRuleToJSON(rule database.ProfanityRule) profanityRuleJSON {
+	return profanityRuleJSON{
+		ID:        rule.ID,
+		CreatedAt: rule.CreatedAt,
+		UpdatedAt: rule.UpdatedAt,
+		Word:      rule.Word,
+		Mode:      rule.Mode,
+	}
+}
+
+type profanityRuleParameters struct {
+	Word string `json:"word"`
+	Mode string `json:"mode"`
+}
+
+// decodeProfanityRule reads and validates a rule from the request body. An
+// empty mode defaults to censor.
+func decodeProfanityRule(r *http.Request) (profanityRuleParameters, error) {
+	decoder := json.NewDecoder(r.Body)
+	params := profanityRuleParameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		return params, errors.New("Invalid JSON")
+	}
+	params.Word = strings.Join(strings.Fields(strings.ToLower(params.Word)), " ")
+	if profanity.Normalize(params.Word) == "" {
+		return params, errors.New("word must contain at least one letter or digit")
+	}
+	if params.Mode == "" {
+		params.Mode = string(profanity.ModeCensor)
+	}
+	if !profanity.Mode(params.Mode).Valid() {
+		return params, errors.New("mode must be one of censor, reject or flag")
+	}
+	return params, nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+func (cfg *apiConfig) handleListProfanityRules(w http.ResponseWriter, r *http.Request, admin database.User) {
+	rules, err := cfg.dbQueries.ListProfanityRules(r.Context())
+	if err != nil {
+		log.Printf("Error retrieving profanity rules: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	resp := make([]profanityRuleJSON, len(rules))
+	for i, rule := range rules {
+		resp[i] = profanityRuleToJSON(rule)
+	}
+	respondWithJSON(w, 200, resp)
+}
+
+func (cfg *apiConfig) handleCreateProfanityRule(w http.ResponseWriter, r *http.Request, admin database.User) {
+	params, err := decodeProfanityRule(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	query := database.CreateProfanityRuleParams{
+		Word: params.Word,
+		Mode: params.Mode,
+	}
+	rule, err := cfg.dbQueries.CreateProfanityRule(r.Context(), query)
+	if isUniqueViolation(err) {
+		respondWithError(w, 409, "A rule for that word already exists")
+		return
+	} else if err != nil {
+		log.Printf("Error creating profanity rule: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	cfg.afterProfanityRuleChange(r, admin, auditProfanityCreate, rule.ID, map[string]interface{}{"word": rule.Word, "mode": rule.Mode})
+	respondWithJSON(w, 201, profanityRuleToJSON(rule))
+}
+
+func (cfg *apiConfig) handleUpdateProfanityRule(w http.ResponseWriter, r *http.Request, admin database.User) {
+	ruleID, err := uuid.Parse(r.PathValue("rule_id"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid rule_id")
+		return
+	}
+	params, err := decodeProfanityRule(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	query := database.UpdateProfanityRuleParams{
+		ID:   ruleID,
+		Word: params.Word,
+		Mode: params.Mode,
+	}
+	rule, err := cfg.dbQueries.UpdateProfanityRule(r.Context(), query)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		return
+	} else if isUniqueViolation(err) {
+		respondWithError(w, 409, "A rule for that word already exists")
+		return
+	} else if err != nil {
+		log.Printf("Error updating profanity rule: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	cfg.afterProfanityRuleChange(r, admin, auditProfanityUpdate, rule.ID, map[string]interface{}{"word": rule.Word, "mode": rule.Mode})
+	respondWithJSON(w, 200, profanityRuleToJSON(rule))
+}
+
+func (cfg *apiConfig) handleDeleteProfanityRule(w http.ResponseWriter, r *http.Request, admin database.User) {
+	ruleID, err := uuid.Parse(r.PathValue("rule_id"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid rule_id")
+		return
+	}
+	deleted, err := cfg.dbQueries.DeleteProfanityRule(r.Context(), ruleID)
+	if err != nil {
+		log.Printf("Error deleting profanity rule: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	if deleted == 0 {
+		w.WriteHeader(404)
+		return
+	}
+	cfg.afterProfanityRuleChange(r, admin, auditProfanityDelete, ruleID, nil)
+	w.WriteHeader(204)
+}
+
+// afterProfanityRuleChange refreshes this instance's cache straight away and
+// records the change.
+func (cfg *apiConfig) afterProfanityRuleChange(r *http.Request, admin database.User, action string, ruleID uuid.UUID, metadata map[string]interface{}) {
+	err := cfg.reloadProfanityRules(r.Context())
+	if err != nil {
+		log.Printf("Error reloading profanity rules: %s", err)
+	}
+	cfg.recordAudit(r, auditEntry{
+		Action:     action,
+		ActorID:    actor(admin.ID),
+		TargetType: "profanity_rule",
+		TargetID:   ruleID.String(),
+		Metadata:   metadata,
+	})
+}
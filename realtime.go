@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/lucoand/chirpy/internal/auth"
+	"github.com/lucoand/chirpy/internal/realtime"
+	"github.com/lucoand/chirpy/internal/stream"
+)
+
+var errRealtimeToken = errors.New("invalid token")
+
+// realtimeServer serves the WebSocket API from the same hub as the
+// Server-Sent Events stream.
+func (cfg *apiConfig) realtimeServer() *realtime.Server {
+	return &realtime.Server{
+		Hub:          cfg.stream,
+		Authenticate: cfg.authenticateRealtime,
+		Filter: func(ctx context.Context, userID uuid.UUID) (stream.Filter, error) {
+			return cfg.streamFilter(ctx, actor(userID), uuid.NullUUID{})
+		},
+	}
+}
+
+// authenticateRealtime checks a WebSocket client's access token. It runs
+// again whenever the client sends a new token, so bans and suspensions take
+// effect at the latest when the old token expires.
+func (cfg *apiConfig) authenticateRealtime(ctx context.Context, token string) (realtime.Session, error) {
+	userID, expiresAt, err := auth.ValidateJWTWithExpiry(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("Error authenticating WebSocket: %s", err)
+		return realtime.Session{}, errRealtimeToken
+	}
+	user, err := cfg.dbQueries.GetUserByID(ctx, userID)
+	if err != nil {
+		log.Printf("Error retrieving user %v for WebSocket: %s", userID, err)
+		return realtime.Session{}, errRealtimeToken
+	}
+	if restriction := accountRestriction(user); restriction != "" {
+		return realtime.Session{}, errors.New(restriction)
+	}
+	return realtime.Session{UserID: user.ID, ExpiresAt: expiresAt}, nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/lucoand/chirpy/internal/auth"
+	"github.com/lucoand/chirpy/internal/database"
+)
+
+const (
+	roleUser      = "user"
+	roleModerator = "moderator"
+	roleAdmin     = "admin"
+)
+
+// roleRanks orders the roles so that a higher role is allowed everything a
+// lower one is.
+var roleRanks = map[string]int{
+	roleUser:      0,
+	roleModerator: 1,
+	roleAdmin:     2,
+}
+
+func isValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+func hasRole(user database.User, role string) bool {
+	have, ok := roleRanks[user.Role]
+	if !ok {
+		return false
+	}
+	return have >= roleRanks[role]
+}
+
+// authedHandler is a handler that has already been given the user making the
+// request.
+type authedHandler func(http.ResponseWriter, *http.Request, database.User)
+
+// authenticate loads the user identified by the request's access token.
+// The user is always read from the database so that role changes take effect
+// immediately instead of when the token expires.
+func (cfg *apiConfig) authenticate(r *http.Request) (database.User, error) {
+	accessToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return database.User{}, err
+	}
+	userID, err := auth.ValidateJWT(accessToken, cfg.jwtSecret)
+	if err != nil {
+		return database.User{}, err
+	}
+	return cfg.dbQueries.GetUserByID(r.Context(), userID)
+}
+
+func (cfg *apiConfig) middlewareAuth(next authedHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := cfg.authenticate(r)
+		if err != nil {
+			log.Printf("Error authenticating request: %s", err)
+			w.WriteHeader(401)
+			return
+		}
+		if restriction := accountRestriction(user); restriction != "" {
+			respondWithError(w, 403, restriction)
+			return
+		}
+		next(w, r, user)
+	}
+}
+
+func (cfg *apiConfig) middlewareRequireRole(role string, next authedHandler) http.HandlerFunc {
+	return cfg.middlewareAuth(func(w http.ResponseWriter, r *http.Request, user database.User) {
+		if !hasRole(user, role) {
+			w.WriteHeader(403)
+			return
+		}
+		next(w, r, user)
+	})
+}
+
+func (cfg *apiConfig) setUserRole(w http.ResponseWriter, r *http.Request, admin database.User, role string, action string) {
+	userID, err := uuid.Parse(r.PathValue("user_id"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid user_id")
+		return
+	}
+	if userID == admin.ID {
+		respondWithError(w, 400, "Admins cannot change their own role")
+		return
+	}
+	query := database.UpdateUserRoleParams{
+		ID:   userID,
+		Role: role,
+	}
+	user, err := cfg.dbQueries.UpdateUserRole(r.Context(), query)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		return
+	} else if err != nil {
+		log.Printf("Error updating role for user %v: %s", userID, err)
+		w.WriteHeader(500)
+		return
+	}
+	cfg.recordAudit(r, auditEntry{
+		Action:     action,
+		ActorID:    actor(admin.ID),
+		TargetType: "user",
+		TargetID:   user.ID.String(),
+		Metadata:   map[string]interface{}{"role": role},
+	})
+	ent, err := cfg.entitlements(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error checking subscription for user %v: %s", user.ID, err)
+		w.WriteHeader(500)
+		return
+	}
+	respondWithJSON(w, 200, userToJSON(user, ent))
+}
+
+func (cfg *apiConfig) handleGrantRole(w http.ResponseWriter, r *http.Request, admin database.User) {
+	type parameters struct {
+		Role string `json:"role"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, "Invalid JSON")
+		return
+	}
+	if !isValidRole(params.Role) {
+		respondWithError(w, 400, "Role must be one of user, moderator or admin")
+		return
+	}
+	cfg.setUserRole(w, r, admin, params.Role, auditRoleGrant)
+}
+
+func (cfg *apiConfig) handleRevokeRole(w http.ResponseWriter, r *http.Request, admin database.User) {
+	cfg.setUserRole(w, r, admin, roleUser, auditRoleRevoke)
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lucoand/chirpy/internal/database"
+	"github.com/lucoand/chirpy/internal/entitlements"
+	"github.com/lucoand/chirpy/internal/events"
+)
+
+const (
+	chirpSchedulerInterval = time.Second
+	chirpSchedulerBatch    = 50
+	maxScheduleAhead       = 365 * 24 * time.Hour
+)
+
+type scheduledChirpJSON struct {
+	ID             uuid.UUID `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	Body           string    `json:"body"`
+	UserID         uuid.UUID `json:"user_id"`
+	Visibility     string    `json:"visibility"`
+	ContentWarning *string   `json:"content_warning"`
+	Sensitive      bool      `json:"sensitive"`
+	PublishAt      time.Time `json:"publish_at"`
+	Status         string    `json:"status"`
+	Error          *string   `json:"error"`
+}
+
+func scheduledChirpToJSON(c database.ScheduledChirp) scheduledChirpJSON {
+	resp := scheduledChirpJSON{
+		ID:         c.ID,
+		CreatedAt:  c.CreatedAt,
+		UpdatedAt:  c.UpdatedAt,
+		Body:       c.Body,
+		UserID:     c.UserID,
+		Visibility: c.Visibility,
+		Sensitive:  c.Sensitive,
+		PublishAt:  c.PublishAt,
+		Status:     c.Status,
+	}
+	if c.ContentWarning.Valid {
+		resp.ContentWarning = &c.ContentWarning.String
+	}
+	if c.LastError.Valid {
+		resp.Error = &c.LastError.String
+	}
+	return resp
+}
+
+// validatePublishAt explains what is wrong with a publish time, or returns
+// an empty string.
+func validatePublishAt(publishAt time.Time) string {
+	if !publishAt.After(time.Now()) {
+		return "publish_at must be in the future"
+	}
+	if publishAt.After(time.Now().Add(maxScheduleAhead)) {
+		return "publish_at must be within a year"
+	}
+	return ""
+}
+
+// scheduleChirp is the part of POST /api/chirps that handles publish_at. The
+// chirp has already been validated, and query holds what it will be
+// published with.
+func (cfg *apiConfig) scheduleChirp(w http.ResponseWriter, r *http.Request, user database.User, ent entitlements.Entitlements, query database.CreateChirpParams, publishAt time.Time) {
+	if problem := validatePublishAt(publishAt); problem != "" {
+		respondWithError(w, 400, problem)
+		return
+	}
+	count, err := cfg.dbQueries.CountScheduledChirps(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error counting scheduled chirps: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	if limit := ent.Limit(entitlements.ScheduledChirps); count >= int64(limit) {
+		respondWithError(w, 403, fmt.Sprintf("You can have at most %d scheduled chirps", limit))
+		return
+	}
+	scheduled, err := cfg.dbQueries.CreateScheduledChirp(r.Context(), database.CreateScheduledChirpParams{
+		UserID:         user.ID,
+		Body:           query.Body,
+		NeedsReview:    query.NeedsReview,
+		PublishAt:      publishAt.UTC(),
+		Visibility:     query.Visibility,
+		ContentWarning: query.ContentWarning,
+		Sensitive:      query.Sensitive,
+	})
+	if err != nil {
+		log.Printf("Error scheduling chirp: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	respondWithJSON(w, 201, scheduledChirpToJSON(scheduled))
+}
+
+func (cfg *apiConfig) handleListScheduledChirps(w http.ResponseWriter, r *http.Request, user database.User) {
+	scheduled, err := cfg.dbQueries.GetScheduledChirps(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error retrieving scheduled chirps: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	resp := make([]scheduledChirpJSON, len(scheduled))
+	for i, c := range scheduled {
+		resp[i] = scheduledChirpToJSON(c)
+	}
+	respondWithJSON(w, 200, resp)
+}
+
+// handleEditScheduledChirp changes a scheduled chirp's body or publish time.
+// Editing a chirp that failed to publish schedules it again.
+func (cfg *apiConfig) handleEditScheduledChirp(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		Body      *string    `json:"body"`
+		PublishAt *time.Time `json:"publish_at"`
+	}
+	scheduledID, err := uuid.Parse(r.PathValue("scheduled_id"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid scheduled chirp ID")
+		return
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, "Invalid JSON")
+		return
+	}
+	scheduled, err := cfg.dbQueries.GetScheduledChirp(r.Context(), database.GetScheduledChirpParams{
+		ID:     scheduledID,
+		UserID: user.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Scheduled chirp not found")
+		return
+	} else if err != nil {
+		log.Printf("Error retrieving scheduled chirp: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	query := database.UpdateScheduledChirpParams{
+		ID:          scheduled.ID,
+		UserID:      user.ID,
+		Body:        scheduled.Body,
+		NeedsReview: scheduled.NeedsReview,
+		PublishAt:   scheduled.PublishAt,
+	}
+	if params.Body != nil {
+		ent, err := cfg.entitlements(r.Context(), user.ID)
+		if err != nil {
+			log.Printf("Error checking entitlements for user %v: %s", user.ID, err)
+			w.WriteHeader(500)
+			return
+		}
+		filtered, problem := cfg.validateChirp(ent, *params.Body)
+		if problem != "" {
+			respondWithError(w, 400, problem)
+			return
+		}
+		query.Body = filtered.Body
+		query.NeedsReview = filtered.Flagged
+	}
+	if params.PublishAt != nil {
+		query.PublishAt = params.PublishAt.UTC()
+	}
+	if problem := validatePublishAt(query.PublishAt); problem != "" {
+		respondWithError(w, 400, problem)
+		return
+	}
+	// If the scheduler is publishing the chirp right now, this waits for it
+	// and then finds nothing to update.
+	updated, err := cfg.dbQueries.UpdateScheduledChirp(r.Context(), query)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Scheduled chirp not found")
+		return
+	} else if err != nil {
+		log.Printf("Error updating scheduled chirp: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	respondWithJSON(w, 200, scheduledChirpToJSON(updated))
+}
+
+func (cfg *apiConfig) handleCancelScheduledChirp(w http.ResponseWriter, r *http.Request, user database.User) {
+	scheduledID, err := uuid.Parse(r.PathValue("scheduled_id"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid scheduled chirp ID")
+		return
+	}
+	n, err := cfg.dbQueries.DeleteScheduledChirp(r.Context(), database.DeleteScheduledChirpParams{
+		ID:     scheduledID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		log.Printf("Error cancelling scheduled chirp: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	if n == 0 {
+		respondWithError(w, 404, "Scheduled chirp not found")
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// runChirpScheduler publishes scheduled chirps when they are due. Chirps
+// that came due while no server was running are published on startup.
+func (cfg *apiConfig) runChirpScheduler(ctx context.Context) {
+	ticker := time.NewTicker(chirpSchedulerInterval)
+	defer ticker.Stop()
+	for {
+		cfg.publishDueChirps(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// publishDueChirps publishes every chirp that is due. Each batch is claimed
+// with SKIP LOCKED and published in the same transaction, so several servers
+// can share the work and each chirp is published exactly once; if a server
+// dies partway through, its transaction rolls back and the chirps are
+// claimed again.
+func (cfg *apiConfig) publishDueChirps(ctx context.Context) {
+	for {
+		published, n, err := cfg.publishChirpBatch(ctx)
+		if err != nil {
+			log.Printf("Error publishing scheduled chirps: %s", err)
+			return
+		}
+		for _, e := range published {
+			cfg.events.Publish(ctx, e)
+		}
+		if n < chirpSchedulerBatch {
+			return
+		}
+	}
+}
+
+// publishChirpBatch publishes up to a batch of due chirps. It returns an
+// event for each chirp it published, to go out once the batch is committed,
+// and how many it claimed.
+func (cfg *apiConfig) publishChirpBatch(ctx context.Context) ([]events.Event, int, error) {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+	due, err := qtx.ClaimDueScheduledChirps(ctx, chirpSchedulerBatch)
+	if err != nil {
+		return nil, 0, err
+	}
+	published := []events.Event{}
+	for _, scheduled := range due {
+		chirp, problem, err := cfg.publishScheduledChirp(ctx, qtx, scheduled)
+		if err != nil {
+			return nil, 0, err
+		}
+		if problem != "" {
+			err = qtx.FailScheduledChirp(ctx, database.FailScheduledChirpParams{
+				ID:        scheduled.ID,
+				LastError: sql.NullString{String: problem, Valid: true},
+			})
+			if err != nil {
+				return nil, 0, err
+			}
+			continue
+		}
+		e, err := queueWebhookDeliveries(ctx, qtx, events.Event{
+			Type:   events.ChirpCreated,
+			UserID: chirp.UserID,
+			Data:   chirpToJSON(chirp),
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		published = append(published, e)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, 0, err
+	}
+	return published, len(due), nil
+}
+
+// publishScheduledChirp turns a scheduled chirp into a chirp with the same
+// ID. The author's account and the chirp are checked again, since the
+// author's plan or the profanity rules may have changed since it was
+// scheduled; a chirp that no longer passes isn't published and the problem
+// is returned instead.
+func (cfg *apiConfig) publishScheduledChirp(ctx context.Context, q *database.Queries, scheduled database.ScheduledChirp) (database.Chirp, string, error) {
+	user, err := cfg.dbQueries.GetUserByID(ctx, scheduled.UserID)
+	if err != nil {
+		return database.Chirp{}, "", err
+	}
+	if restriction := accountRestriction(user); restriction != "" {
+		return database.Chirp{}, restriction, nil
+	}
+	ent, err := cfg.entitlements(ctx, scheduled.UserID)
+	if err != nil {
+		return database.Chirp{}, "", err
+	}
+	filtered, problem := cfg.validateChirp(ent, scheduled.Body)
+	if problem != "" {
+		return database.Chirp{}, problem, nil
+	}
+	warning, warningFlagged, problem := cfg.validateContentWarning(scheduled.ContentWarning.String)
+	if problem != "" {
+		return database.Chirp{}, problem, nil
+	}
+	chirp, err := q.PublishScheduledChirp(ctx, database.PublishScheduledChirpParams{
+		ID:             scheduled.ID,
+		Body:           filtered.Body,
+		NeedsReview:    scheduled.NeedsReview || filtered.Flagged || warningFlagged,
+		ContentWarning: warning,
+	})
+	return chirp, "", err
+}
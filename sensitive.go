@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/lucoand/chirpy/internal/database"
+)
+
+// A chirp with a content warning is shown collapsed behind it by clients.
+// Every chirp with one is also sensitive, so that users who hide sensitive
+// chirps don't see it either.
+const maxContentWarningLength = 100
+
+// validateContentWarning checks an optional content warning the way
+// validateChirp checks a body. An empty warning is no warning.
+func (cfg *apiConfig) validateContentWarning(warning string) (sql.NullString, bool, string) {
+	if warning == "" {
+		return sql.NullString{}, false, ""
+	}
+	filtered, problem := cfg.validateText("Content warning", warning, maxContentWarningLength)
+	if problem != "" {
+		return sql.NullString{}, false, problem
+	}
+	return sql.NullString{String: filtered.Body, Valid: true}, filtered.Flagged, ""
+}
+
+type userPreferencesJSON struct {
+	// HideSensitive leaves sensitive chirps by other users out of listings
+	// and streams. They can still be opened directly.
+	HideSensitive bool `json:"hide_sensitive"`
+}
+
+// userPreferences returns the user's preferences, or the defaults if they
+// never changed any.
+func (cfg *apiConfig) userPreferences(ctx context.Context, userID uuid.UUID) (userPreferencesJSON, error) {
+	prefs, err := cfg.dbQueries.GetUserPreferences(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return userPreferencesJSON{}, nil
+	} else if err != nil {
+		return userPreferencesJSON{}, err
+	}
+	return userPreferencesJSON{HideSensitive: prefs.HideSensitive}, nil
+}
+
+func (cfg *apiConfig) handleGetUserPreferences(w http.ResponseWriter, r *http.Request, user database.User) {
+	prefs, err := cfg.userPreferences(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error retrieving preferences: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	respondWithJSON(w, 200, prefs)
+}
+
+// handleUpdateUserPreferences changes the user's preferences. Preferences
+// left out of the request keep their setting.
+func (cfg *apiConfig) handleUpdateUserPreferences(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		HideSensitive *bool `json:"hide_sensitive"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, "Invalid JSON")
+		return
+	}
+	prefs, err := cfg.userPreferences(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error retrieving preferences: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	if params.HideSensitive != nil {
+		prefs.HideSensitive = *params.HideSensitive
+	}
+	saved, err := cfg.dbQueries.UpsertUserPreferences(r.Context(), database.UpsertUserPreferencesParams{
+		UserID:        user.ID,
+		HideSensitive: prefs.HideSensitive,
+	})
+	if err != nil {
+		log.Printf("Error saving preferences: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	respondWithJSON(w, 200, userPreferencesJSON{HideSensitive: saved.HideSensitive})
+}
+
+// markChirpSensitive flags a chirp as sensitive on a moderator's behalf,
+// optionally replacing its content warning. The chirp as it was is kept in
+// its edit history, as edited by the moderator.
+func markChirpSensitive(ctx context.Context, q *database.Queries, chirp database.Chirp, moderatorID uuid.UUID, warning sql.NullString) error {
+	_, err := q.CreateChirpEdit(ctx, database.CreateChirpEditParams{
+		ChirpID:        chirp.ID,
+		Body:           chirp.Body,
+		EditedBy:       actor(moderatorID),
+		ContentWarning: chirp.ContentWarning,
+		Sensitive:      chirp.Sensitive,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = q.MarkChirpSensitive(ctx, database.MarkChirpSensitiveParams{
+		ID:             chirp.ID,
+		ContentWarning: warning,
+	})
+	return err
+}
@@ -0,0 +1,34 @@
+-- name: CreateBookmark :exec
+INSERT INTO bookmarks (user_id, chirp_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (user_id, chirp_id) DO NOTHING;
+
+-- name: DeleteBookmark :exec
+DELETE FROM bookmarks
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: GetBookmarkedChirps :many
+SELECT chirps.* FROM bookmarks
+JOIN chirps ON chirps.id = bookmarks.chirp_id
+WHERE bookmarks.user_id = sqlc.arg('user_id') AND chirps.hidden_at IS NULL
+AND (
+	chirps.user_id = sqlc.arg('user_id')
+	OR NOT EXISTS (
+		SELECT 1 FROM users
+		WHERE users.id = chirps.user_id AND users.account_status = 'shadowbanned'
+	)
+)
+AND (
+	chirps.visibility IN ('public', 'unlisted')
+	OR chirps.user_id = sqlc.arg('user_id')
+	OR (chirps.visibility = 'followers_only' AND EXISTS (
+		SELECT 1 FROM follows
+		WHERE follows.follower_id = sqlc.arg('user_id') AND follows.followee_id = chirps.user_id
+	))
+	OR (chirps.visibility IN ('followers_only', 'mentioned_only') AND EXISTS (
+		SELECT 1 FROM chirp_mentions
+		WHERE chirp_mentions.chirp_id = chirps.id AND chirp_mentions.user_id = sqlc.arg('user_id')
+	))
+)
+ORDER BY bookmarks.created_at DESC, chirps.id
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
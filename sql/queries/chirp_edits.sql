@@ -0,0 +1,17 @@
+-- name: CreateChirpEdit :one
+INSERT INTO chirp_edits (id, chirp_id, body, edited_at, edited_by, content_warning, sensitive)
+VALUES (
+	gen_random_uuid(),
+	$1,
+	$2,
+	NOW(),
+	$3,
+	$4,
+	$5
+	)
+	RETURNING *;
+
+-- name: GetChirpEdits :many
+SELECT * FROM chirp_edits
+WHERE chirp_id = $1
+ORDER BY edited_at DESC, id;
@@ -0,0 +1,42 @@
+-- name: CreateChirpReport :one
+INSERT INTO chirp_reports (id, created_at, updated_at, chirp_id, reporter_id, reason)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	NOW(),
+	$1,
+	$2,
+	$3
+	)
+	RETURNING *;
+
+-- name: GetChirpReportsByReporter :many
+SELECT * FROM chirp_reports
+WHERE reporter_id = $1
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3;
+
+-- name: ListModerationQueue :many
+SELECT chirps.id, chirps.created_at, chirps.body, chirps.user_id, chirps.needs_review,
+	COUNT(chirp_reports.id) AS report_count,
+	COALESCE(MIN(chirp_reports.created_at), chirps.created_at)::timestamp AS first_reported_at
+FROM chirps
+LEFT JOIN chirp_reports
+ON chirp_reports.chirp_id = chirps.id AND chirp_reports.status = 'open'
+WHERE chirps.hidden_at IS NULL
+AND (chirps.needs_review OR chirp_reports.id IS NOT NULL)
+GROUP BY chirps.id
+ORDER BY report_count DESC, first_reported_at ASC
+LIMIT $1 OFFSET $2;
+
+-- name: GetOpenChirpReportsForChirps :many
+SELECT * FROM chirp_reports
+WHERE chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+AND status = 'open'
+ORDER BY created_at ASC;
+
+-- name: ResolveChirpReports :many
+UPDATE chirp_reports
+SET status = $2, resolution = $3, resolved_by = $4, resolved_at = NOW(), updated_at = NOW()
+WHERE chirp_id = $1 AND status = 'open'
+RETURNING *;
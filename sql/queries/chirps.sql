@@ -1,20 +1,88 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, needs_review, visibility, content_warning, sensitive)
 VALUES (
 	gen_random_uuid(),
 	NOW(),
 	NOW(),
 	$1,
-	$2
+	$2,
+	$3,
+	$4,
+	$5,
+	$6
 	)
 	RETURNING *;
 
 -- name: GetChirps :many
 SELECT * FROM chirps
-ORDER BY created_at ASC;
+WHERE hidden_at IS NULL
+AND (
+	user_id = sqlc.narg('viewer_id')
+	OR NOT EXISTS (
+		SELECT 1 FROM users
+		WHERE users.id = chirps.user_id AND users.account_status = 'shadowbanned'
+	)
+)
+AND (
+	visibility = 'public'
+	OR user_id = sqlc.narg('viewer_id')
+	OR (visibility = 'followers_only' AND EXISTS (
+		SELECT 1 FROM follows
+		WHERE follows.follower_id = sqlc.narg('viewer_id') AND follows.followee_id = chirps.user_id
+	))
+	OR (visibility IN ('followers_only', 'mentioned_only') AND EXISTS (
+		SELECT 1 FROM chirp_mentions
+		WHERE chirp_mentions.chirp_id = chirps.id AND chirp_mentions.user_id = sqlc.narg('viewer_id')
+	))
+)
+AND NOT EXISTS (
+	SELECT 1 FROM user_blocks
+	WHERE user_blocks.blocker_id = sqlc.narg('viewer_id') AND user_blocks.blocked_id = chirps.user_id
+)
+AND NOT EXISTS (
+	SELECT 1 FROM user_mutes
+	WHERE user_mutes.user_id = sqlc.narg('viewer_id') AND user_mutes.user_id <> chirps.user_id
+	AND chirps.body ~* user_mutes.pattern
+)
+AND NOT (
+	chirps.sensitive AND chirps.user_id <> sqlc.narg('viewer_id')
+	AND EXISTS (
+		SELECT 1 FROM user_preferences
+		WHERE user_preferences.user_id = sqlc.narg('viewer_id') AND user_preferences.hide_sensitive
+	)
+)
+ORDER BY
+	CASE WHEN sqlc.arg('sort_desc')::boolean THEN created_at END DESC,
+	CASE WHEN sqlc.arg('sort_desc')::boolean THEN id END DESC,
+	created_at ASC,
+	id ASC
+LIMIT sqlc.narg('limit') OFFSET sqlc.arg('offset');
 
 -- name: GetChirp :one
 SELECT * FROM chirps
+WHERE id = sqlc.arg('id') AND hidden_at IS NULL
+AND (
+	user_id = sqlc.narg('viewer_id')
+	OR NOT EXISTS (
+		SELECT 1 FROM users
+		WHERE users.id = chirps.user_id AND users.account_status = 'shadowbanned'
+	)
+)
+AND (
+	visibility IN ('public', 'unlisted')
+	OR user_id = sqlc.narg('viewer_id')
+	OR (visibility = 'followers_only' AND EXISTS (
+		SELECT 1 FROM follows
+		WHERE follows.follower_id = sqlc.narg('viewer_id') AND follows.followee_id = chirps.user_id
+	))
+	OR (visibility IN ('followers_only', 'mentioned_only') AND EXISTS (
+		SELECT 1 FROM chirp_mentions
+		WHERE chirp_mentions.chirp_id = chirps.id AND chirp_mentions.user_id = sqlc.narg('viewer_id')
+	))
+);
+
+-- name: GetChirpIncludingHidden :one
+SELECT * FROM chirps
 WHERE id = $1;
 
 -- name: DeleteChirpByID :exec
@@ -23,5 +91,73 @@ WHERE id = $1;
 
 -- name: GetChirpsByUserID :many
 SELECT * FROM chirps
-WHERE user_id = $1
-ORDER BY created_at ASC;
+WHERE user_id = sqlc.arg('user_id') AND hidden_at IS NULL
+AND (
+	user_id = sqlc.narg('viewer_id')
+	OR NOT EXISTS (
+		SELECT 1 FROM users
+		WHERE users.id = chirps.user_id AND users.account_status = 'shadowbanned'
+	)
+)
+AND (
+	visibility IN ('public', 'unlisted')
+	OR user_id = sqlc.narg('viewer_id')
+	OR (visibility = 'followers_only' AND EXISTS (
+		SELECT 1 FROM follows
+		WHERE follows.follower_id = sqlc.narg('viewer_id') AND follows.followee_id = chirps.user_id
+	))
+	OR (visibility IN ('followers_only', 'mentioned_only') AND EXISTS (
+		SELECT 1 FROM chirp_mentions
+		WHERE chirp_mentions.chirp_id = chirps.id AND chirp_mentions.user_id = sqlc.narg('viewer_id')
+	))
+)
+AND NOT EXISTS (
+	SELECT 1 FROM user_blocks
+	WHERE user_blocks.blocker_id = sqlc.narg('viewer_id') AND user_blocks.blocked_id = chirps.user_id
+)
+AND NOT EXISTS (
+	SELECT 1 FROM user_mutes
+	WHERE user_mutes.user_id = sqlc.narg('viewer_id') AND user_mutes.user_id <> chirps.user_id
+	AND chirps.body ~* user_mutes.pattern
+)
+AND NOT (
+	chirps.sensitive AND chirps.user_id <> sqlc.narg('viewer_id')
+	AND EXISTS (
+		SELECT 1 FROM user_preferences
+		WHERE user_preferences.user_id = sqlc.narg('viewer_id') AND user_preferences.hide_sensitive
+	)
+)
+ORDER BY
+	CASE WHEN sqlc.arg('pinned_first')::boolean THEN (
+		SELECT pinned_at FROM pinned_chirps
+		WHERE pinned_chirps.chirp_id = chirps.id
+	) END DESC NULLS LAST,
+	CASE WHEN sqlc.arg('sort_desc')::boolean THEN created_at END DESC,
+	CASE WHEN sqlc.arg('sort_desc')::boolean THEN id END DESC,
+	created_at ASC,
+	id ASC
+LIMIT sqlc.narg('limit') OFFSET sqlc.arg('offset');
+
+-- name: HideChirp :exec
+UPDATE chirps
+SET hidden_at = NOW(), needs_review = FALSE, updated_at = NOW()
+WHERE id = $1;
+
+-- name: ClearChirpReview :exec
+UPDATE chirps
+SET needs_review = FALSE
+WHERE id = $1;
+
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2, needs_review = needs_review OR $3, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: MarkChirpSensitive :one
+UPDATE chirps
+SET sensitive = TRUE,
+	content_warning = COALESCE(sqlc.narg('content_warning'), content_warning),
+	updated_at = NOW()
+WHERE id = sqlc.arg('id')
+RETURNING *;
@@ -0,0 +1,67 @@
+-- name: CreateConversation :one
+INSERT INTO conversations (id, created_at, updated_at, created_by, direct_key)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	NOW(),
+	$1,
+	$2
+	)
+ON CONFLICT (direct_key) DO NOTHING
+RETURNING *;
+
+-- name: GetDirectConversation :one
+SELECT * FROM conversations
+WHERE direct_key = $1;
+
+-- name: AddConversationParticipants :exec
+INSERT INTO conversation_participants (conversation_id, user_id, joined_at)
+SELECT sqlc.arg('conversation_id')::uuid, unnest(sqlc.arg('user_ids')::uuid[]), NOW()
+ON CONFLICT DO NOTHING;
+
+-- name: GetConversation :one
+SELECT conversations.* FROM conversations
+JOIN conversation_participants ON conversation_participants.conversation_id = conversations.id
+WHERE conversations.id = $1 AND conversation_participants.user_id = $2;
+
+-- name: GetConversationParticipants :many
+SELECT * FROM conversation_participants
+WHERE conversation_id = ANY(sqlc.arg('conversation_ids')::uuid[])
+ORDER BY joined_at, user_id;
+
+-- name: ListConversations :many
+SELECT
+	conversations.id,
+	conversations.created_at,
+	conversations.updated_at,
+	last_message.id AS last_message_id,
+	last_message.sender_id AS last_message_sender_id,
+	last_message.created_at AS last_message_created_at,
+	last_message.body AS last_message_body,
+	(
+		SELECT COUNT(*) FROM messages
+		WHERE messages.conversation_id = conversations.id
+		AND messages.sender_id IS DISTINCT FROM me.user_id
+		AND (me.last_read_at IS NULL OR messages.created_at > me.last_read_at)
+	) AS unread_count
+FROM conversation_participants me
+JOIN conversations ON conversations.id = me.conversation_id
+LEFT JOIN LATERAL (
+	SELECT messages.id, messages.sender_id, messages.created_at, messages.body FROM messages
+	WHERE messages.conversation_id = conversations.id
+	ORDER BY messages.created_at DESC, messages.id DESC
+	LIMIT 1
+	) last_message ON TRUE
+WHERE me.user_id = $1
+ORDER BY conversations.updated_at DESC, conversations.id DESC
+LIMIT $2 OFFSET $3;
+
+-- name: TouchConversation :exec
+UPDATE conversations
+SET updated_at = NOW()
+WHERE id = $1;
+
+-- name: MarkConversationRead :exec
+UPDATE conversation_participants
+SET last_read_at = NOW()
+WHERE conversation_id = $1 AND user_id = $2;
@@ -0,0 +1,37 @@
+-- name: QueueLinkPreview :exec
+INSERT INTO link_previews (url, created_at, updated_at, status, next_attempt_at)
+VALUES (sqlc.arg('url'), NOW(), NOW(), 'pending', NOW())
+ON CONFLICT (url) DO UPDATE
+SET status = 'pending', next_attempt_at = NOW(), updated_at = NOW()
+WHERE (link_previews.status = 'ready'
+	AND link_previews.fetched_at < NOW() - make_interval(secs => sqlc.arg('ready_ttl_seconds')::float8))
+OR (link_previews.status = 'failed'
+	AND link_previews.fetched_at < NOW() - make_interval(secs => sqlc.arg('failed_ttl_seconds')::float8));
+
+-- name: ClaimLinkPreviews :many
+UPDATE link_previews
+SET status = 'processing', next_attempt_at = NOW() + INTERVAL '5 minutes', updated_at = NOW()
+WHERE url IN (
+	SELECT url FROM link_previews
+	WHERE status IN ('pending', 'processing') AND next_attempt_at <= NOW()
+	ORDER BY next_attempt_at
+	LIMIT $1
+	FOR UPDATE SKIP LOCKED
+	)
+RETURNING url;
+
+-- name: SaveLinkPreview :exec
+UPDATE link_previews
+SET status = 'ready', title = $2, description = $3, image_url = $4, last_error = NULL,
+	fetched_at = NOW(), updated_at = NOW()
+WHERE url = $1;
+
+-- name: FailLinkPreview :exec
+UPDATE link_previews
+SET status = 'failed', title = NULL, description = NULL, image_url = NULL, last_error = $2,
+	fetched_at = NOW(), updated_at = NOW()
+WHERE url = $1;
+
+-- name: GetLinkPreviews :many
+SELECT * FROM link_previews
+WHERE url = ANY(sqlc.arg('urls')::text[]) AND status = 'ready';
@@ -0,0 +1,31 @@
+-- name: CreateMedia :one
+INSERT INTO media (id, created_at, user_id, key, thumbnail_key, content_type, width, height, thumbnail_width, thumbnail_height, size_bytes)
+VALUES (
+	$1,
+	NOW(),
+	$2,
+	$3,
+	$4,
+	$5,
+	$6,
+	$7,
+	$8,
+	$9,
+	$10
+	)
+	RETURNING *;
+
+-- name: AttachMedia :execrows
+UPDATE media
+SET chirp_id = $3, position = $4
+WHERE id = $1 AND user_id = $2 AND chirp_id IS NULL;
+
+-- name: GetMediaForChirps :many
+SELECT * FROM media
+WHERE chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+ORDER BY chirp_id, position;
+
+-- name: DeleteUnattachedMedia :many
+DELETE FROM media
+WHERE chirp_id IS NULL AND created_at < $1
+RETURNING *;
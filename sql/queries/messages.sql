@@ -0,0 +1,16 @@
+-- name: CreateMessage :one
+INSERT INTO messages (id, conversation_id, sender_id, created_at, body)
+VALUES (
+	gen_random_uuid(),
+	$1,
+	$2,
+	NOW(),
+	$3
+	)
+	RETURNING *;
+
+-- name: GetMessages :many
+SELECT * FROM messages
+WHERE conversation_id = $1
+ORDER BY created_at DESC, id DESC
+LIMIT $2 OFFSET $3;
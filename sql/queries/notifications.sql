@@ -0,0 +1,52 @@
+-- name: CreateNotification :one
+INSERT INTO notifications (id, created_at, user_id, type, event_id, event_type, actor_id, chirp_id, data)
+SELECT
+	gen_random_uuid(),
+	NOW(),
+	sqlc.arg('user_id')::uuid,
+	sqlc.arg('type')::text,
+	sqlc.arg('event_id')::uuid,
+	sqlc.arg('event_type')::text,
+	sqlc.narg('actor_id')::uuid,
+	sqlc.narg('chirp_id')::uuid,
+	sqlc.arg('data')::jsonb
+WHERE NOT EXISTS (
+	SELECT 1 FROM notification_preferences
+	WHERE notification_preferences.user_id = sqlc.arg('user_id')::uuid
+	AND notification_preferences.type = sqlc.arg('type')::text
+	AND NOT notification_preferences.enabled
+	)
+ON CONFLICT (user_id, event_id) DO NOTHING
+RETURNING *;
+
+-- name: GetNotifications :many
+SELECT * FROM notifications
+WHERE user_id = sqlc.arg('user_id')
+AND (NOT sqlc.arg('unread_only')::boolean OR read_at IS NULL)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications
+WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: MarkNotificationRead :execrows
+UPDATE notifications
+SET read_at = COALESCE(read_at, NOW())
+WHERE id = $1 AND user_id = $2;
+
+-- name: MarkAllNotificationsRead :execrows
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: GetNotificationPreferences :many
+SELECT * FROM notification_preferences
+WHERE user_id = $1
+ORDER BY type;
+
+-- name: SetNotificationPreference :exec
+INSERT INTO notification_preferences (user_id, type, enabled, updated_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT (user_id, type) DO UPDATE
+SET enabled = EXCLUDED.enabled, updated_at = NOW();
@@ -0,0 +1,18 @@
+-- name: PinChirp :exec
+INSERT INTO pinned_chirps (chirp_id, user_id, pinned_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (chirp_id) DO NOTHING;
+
+-- name: UnpinChirp :exec
+DELETE FROM pinned_chirps
+WHERE chirp_id = $1 AND user_id = $2;
+
+-- name: IsChirpPinned :one
+SELECT EXISTS (
+	SELECT 1 FROM pinned_chirps
+	WHERE chirp_id = $1
+);
+
+-- name: CountPinnedChirps :one
+SELECT COUNT(*) FROM pinned_chirps
+WHERE user_id = $1;
@@ -0,0 +1,37 @@
+-- name: CreatePoll :exec
+INSERT INTO polls (chirp_id, closes_at)
+VALUES ($1, $2);
+
+-- name: CreatePollOption :exec
+INSERT INTO poll_options (chirp_id, position, text)
+VALUES ($1, $2, $3);
+
+-- name: GetPoll :one
+SELECT * FROM polls
+WHERE chirp_id = $1;
+
+-- name: CountPollOptions :one
+SELECT COUNT(*) FROM poll_options
+WHERE chirp_id = $1;
+
+-- name: GetPollsForChirps :many
+SELECT polls.chirp_id, polls.closes_at, poll_options.position, poll_options.text,
+	(
+		SELECT COUNT(*) FROM poll_votes
+		WHERE poll_votes.chirp_id = poll_options.chirp_id AND poll_votes.position = poll_options.position
+	) AS votes,
+	EXISTS (
+		SELECT 1 FROM poll_votes
+		WHERE poll_votes.chirp_id = poll_options.chirp_id AND poll_votes.position = poll_options.position
+		AND poll_votes.user_id = sqlc.narg('viewer_id')
+	) AS chosen
+FROM polls
+JOIN poll_options ON poll_options.chirp_id = polls.chirp_id
+WHERE polls.chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+ORDER BY polls.chirp_id, poll_options.position;
+
+-- name: UpsertPollVote :exec
+INSERT INTO poll_votes (chirp_id, user_id, position, created_at, updated_at)
+VALUES ($1, $2, $3, NOW(), NOW())
+ON CONFLICT (chirp_id, user_id) DO UPDATE
+SET position = EXCLUDED.position, updated_at = NOW();
@@ -0,0 +1,24 @@
+-- name: ListProfanityRules :many
+SELECT * FROM profanity_rules
+ORDER BY word ASC;
+
+-- name: CreateProfanityRule :one
+INSERT INTO profanity_rules (id, created_at, updated_at, word, mode)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	NOW(),
+	$1,
+	$2
+	)
+	RETURNING *;
+
+-- name: UpdateProfanityRule :one
+UPDATE profanity_rules
+SET word = $2, mode = $3, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteProfanityRule :execrows
+DELETE FROM profanity_rules
+WHERE id = $1;
@@ -0,0 +1,63 @@
+-- name: CreateScheduledChirp :one
+INSERT INTO scheduled_chirps (id, created_at, updated_at, user_id, body, needs_review, publish_at,
+	visibility, content_warning, sensitive)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	NOW(),
+	$1,
+	$2,
+	$3,
+	$4,
+	$5,
+	$6,
+	$7
+	)
+	RETURNING *;
+
+-- name: CountScheduledChirps :one
+SELECT COUNT(*) FROM scheduled_chirps
+WHERE user_id = $1;
+
+-- name: GetScheduledChirps :many
+SELECT * FROM scheduled_chirps
+WHERE user_id = $1
+ORDER BY publish_at, id;
+
+-- name: UpdateScheduledChirp :one
+UPDATE scheduled_chirps
+SET body = $3, needs_review = $4, publish_at = $5, status = 'scheduled', last_error = NULL, updated_at = NOW()
+WHERE id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: GetScheduledChirp :one
+SELECT * FROM scheduled_chirps
+WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteScheduledChirp :execrows
+DELETE FROM scheduled_chirps
+WHERE id = $1 AND user_id = $2;
+
+-- name: ClaimDueScheduledChirps :many
+SELECT * FROM scheduled_chirps
+WHERE status = 'scheduled' AND publish_at <= NOW()
+ORDER BY publish_at, id
+LIMIT $1
+FOR UPDATE SKIP LOCKED;
+
+-- name: PublishScheduledChirp :one
+WITH published AS (
+	DELETE FROM scheduled_chirps
+	WHERE scheduled_chirps.id = $1
+	RETURNING scheduled_chirps.id, scheduled_chirps.user_id, scheduled_chirps.visibility, scheduled_chirps.sensitive
+	)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, needs_review, visibility, content_warning, sensitive)
+SELECT published.id, NOW(), NOW(), $2::text, published.user_id, $3::boolean,
+	published.visibility, $4::text, published.sensitive
+FROM published
+RETURNING chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.needs_review, chirps.hidden_at, chirps.visibility, chirps.content_warning, chirps.sensitive;
+
+-- name: FailScheduledChirp :exec
+UPDATE scheduled_chirps
+SET status = 'failed', last_error = $2, updated_at = NOW()
+WHERE id = $1;
@@ -0,0 +1,14 @@
+-- name: NotifyStream :exec
+SELECT pg_notify('chirpy_stream', json_build_object(
+	'id', nextval('stream_event_id_seq'),
+	'type', sqlc.arg('type')::text,
+	'chirp_id', sqlc.narg('chirp_id')::uuid,
+	'user_id', sqlc.arg('user_id')::uuid,
+	'author_status', sqlc.narg('author_status')::text,
+	'data', sqlc.narg('data')::json
+	)::text);
+
+-- name: GetChirpForStream :one
+SELECT chirps.*, users.account_status FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.id = $1;
@@ -0,0 +1,50 @@
+-- name: GetSubscriptionByUserID :one
+SELECT * FROM subscriptions
+WHERE user_id = $1;
+
+-- name: GetCurrentSubscription :one
+SELECT * FROM subscriptions
+WHERE user_id = $1
+AND status IN ('active', 'past_due', 'canceled')
+AND current_period_end > NOW();
+
+-- name: ActivateSubscription :one
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan, status, current_period_end)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	NOW(),
+	$1,
+	$2,
+	'active',
+	$3
+	)
+ON CONFLICT (user_id) DO UPDATE
+SET plan = EXCLUDED.plan, status = 'active', current_period_end = EXCLUDED.current_period_end,
+	canceled_at = NULL, updated_at = NOW()
+RETURNING *;
+
+-- name: CancelSubscription :one
+UPDATE subscriptions
+SET status = 'canceled', canceled_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND status IN ('active', 'past_due')
+RETURNING *;
+
+-- name: MarkSubscriptionPastDue :one
+UPDATE subscriptions
+SET status = 'past_due', updated_at = NOW()
+WHERE user_id = $1 AND status = 'active'
+RETURNING *;
+
+-- name: RefundSubscription :one
+UPDATE subscriptions
+SET status = 'refunded', current_period_end = LEAST(current_period_end, NOW()),
+	canceled_at = COALESCE(canceled_at, NOW()), updated_at = NOW()
+WHERE user_id = $1 AND status <> 'refunded'
+RETURNING *;
+
+-- name: ExpireLapsedSubscriptions :many
+UPDATE subscriptions
+SET status = 'expired', updated_at = NOW()
+WHERE status IN ('active', 'past_due', 'canceled') AND current_period_end <= NOW()
+RETURNING *;
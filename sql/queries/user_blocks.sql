@@ -0,0 +1,24 @@
+-- name: CreateUserBlock :exec
+INSERT INTO user_blocks (blocker_id, blocked_id, created_at)
+VALUES (
+	$1,
+	$2,
+	NOW()
+	)
+ON CONFLICT DO NOTHING;
+
+-- name: GetUserBlocks :many
+SELECT * FROM user_blocks
+WHERE blocker_id = $1
+ORDER BY created_at ASC;
+
+-- name: DeleteUserBlock :execrows
+DELETE FROM user_blocks
+WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: IsBlockedBetween :one
+SELECT EXISTS (
+	SELECT 1 FROM user_blocks
+	WHERE (blocker_id = sqlc.arg('user_id') AND blocked_id = ANY(sqlc.arg('other_ids')::uuid[]))
+	OR (blocked_id = sqlc.arg('user_id') AND blocker_id = ANY(sqlc.arg('other_ids')::uuid[]))
+	);
@@ -0,0 +1,19 @@
+-- name: CreateUserMute :one
+INSERT INTO user_mutes (id, created_at, user_id, phrase, pattern)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	$1,
+	$2,
+	$3
+	)
+	RETURNING *;
+
+-- name: GetUserMutes :many
+SELECT * FROM user_mutes
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: DeleteUserMute :execrows
+DELETE FROM user_mutes
+WHERE id = $1 AND user_id = $2;
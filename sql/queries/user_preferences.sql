@@ -0,0 +1,14 @@
+-- name: GetUserPreferences :one
+SELECT * FROM user_preferences
+WHERE user_id = $1;
+
+-- name: UpsertUserPreferences :one
+INSERT INTO user_preferences (user_id, updated_at, hide_sensitive)
+VALUES (
+	$1,
+	NOW(),
+	$2
+	)
+ON CONFLICT (user_id) DO UPDATE
+SET hide_sensitive = EXCLUDED.hide_sensitive, updated_at = NOW()
+RETURNING *;
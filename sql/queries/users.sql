@@ -20,13 +20,29 @@ DELETE FROM users;
 UPDATE users
 SET email = $2, hashed_password = $3, updated_at = NOW()
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, is_chirpy_red;
+RETURNING id, created_at, updated_at, email, role;
 
--- name: UpgradeUserToChirpyRed :exec
-UPDATE users
-SET is_chirpy_red = TRUE, updated_at = NOW()
+-- name: GetUserByID :one
+SELECT * FROM users
 WHERE id = $1;
 
--- name: GetUserByID :one
-SELECT id, created_at, updated_at, email, is_chirpy_red
-FROM users WHERE id = $1;
+-- name: UpdateUserRole :one
+UPDATE users
+SET role = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: SetUserAccountStatus :one
+UPDATE users
+SET account_status = $2, status_reason = $3, suspended_until = $4, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: CountUsersByIDs :one
+SELECT COUNT(*) FROM users
+WHERE id = ANY(sqlc.arg('ids')::uuid[]);
+
+-- name: LockUser :exec
+SELECT id FROM users
+WHERE id = $1
+FOR UPDATE;
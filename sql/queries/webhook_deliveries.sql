@@ -0,0 +1,65 @@
+-- name: EnqueueWebhookDeliveries :execrows
+INSERT INTO webhook_deliveries (id, endpoint_id, event_id, event_type, payload, status, attempts,
+	created_at, updated_at, next_attempt_at)
+SELECT gen_random_uuid(), webhook_endpoints.id, sqlc.arg('event_id'), sqlc.arg('event_type'), sqlc.arg('payload'),
+	'pending', 0, NOW(), NOW(), NOW()
+FROM webhook_endpoints
+JOIN users ON users.id = webhook_endpoints.owner_id
+WHERE sqlc.arg('event_type')::text = ANY(webhook_endpoints.events)
+AND (webhook_endpoints.owner_id = sqlc.arg('user_id')
+	OR (webhook_endpoints.all_users AND users.role = 'admin'));
+
+-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (id, endpoint_id, event_id, event_type, payload, status, attempts,
+	created_at, updated_at, next_attempt_at)
+VALUES (
+	gen_random_uuid(),
+	$1,
+	$2,
+	$3,
+	$4,
+	'pending',
+	0,
+	NOW(),
+	NOW(),
+	NOW()
+	)
+	RETURNING *;
+
+-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries
+SET status = 'processing', attempts = attempts + 1,
+	next_attempt_at = NOW() + INTERVAL '5 minutes', updated_at = NOW()
+FROM webhook_endpoints
+WHERE webhook_endpoints.id = webhook_deliveries.endpoint_id
+AND webhook_deliveries.id IN (
+	SELECT id FROM webhook_deliveries
+	WHERE status IN ('pending', 'processing', 'failed') AND next_attempt_at <= NOW()
+	ORDER BY created_at
+	LIMIT $1
+	FOR UPDATE SKIP LOCKED
+	)
+RETURNING webhook_deliveries.*, webhook_endpoints.url, webhook_endpoints.secret;
+
+-- name: MarkWebhookDeliveryDelivered :exec
+UPDATE webhook_deliveries
+SET status = 'delivered', response_status = $2, last_error = NULL, delivered_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- name: RetryWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = 'failed', response_status = sqlc.narg('response_status'), last_error = sqlc.arg('last_error'),
+	next_attempt_at = NOW() + make_interval(secs => sqlc.arg('backoff_seconds')::float8),
+	updated_at = NOW()
+WHERE id = sqlc.arg('id');
+
+-- name: DeadLetterWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = 'dead', response_status = $2, last_error = $3, updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE endpoint_id = $1
+ORDER BY created_at DESC, id
+LIMIT $2 OFFSET $3;
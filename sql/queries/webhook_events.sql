@@ -0,0 +1,63 @@
+-- name: CreateWebhookEvent :execrows
+INSERT INTO webhook_events (id, source, event_id, event_type, payload, status, attempts,
+	received_at, updated_at, next_attempt_at)
+VALUES (
+	gen_random_uuid(),
+	$1,
+	$2,
+	$3,
+	$4,
+	'pending',
+	0,
+	NOW(),
+	NOW(),
+	NOW()
+	)
+ON CONFLICT (source, event_id) DO NOTHING;
+
+-- name: ClaimWebhookEvents :many
+UPDATE webhook_events
+SET status = 'processing', attempts = attempts + 1,
+	next_attempt_at = NOW() + INTERVAL '5 minutes', updated_at = NOW()
+WHERE id IN (
+	SELECT id FROM webhook_events
+	WHERE status IN ('pending', 'processing', 'failed') AND next_attempt_at <= NOW()
+	ORDER BY received_at
+	LIMIT $1
+	FOR UPDATE SKIP LOCKED
+	)
+RETURNING *;
+
+-- name: MarkWebhookEventProcessed :execrows
+UPDATE webhook_events
+SET status = 'processed', last_error = NULL, processed_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND status = 'processing' AND attempts = $2;
+
+-- name: RetryWebhookEvent :exec
+UPDATE webhook_events
+SET status = 'failed', last_error = sqlc.arg('last_error'),
+	next_attempt_at = NOW() + make_interval(secs => sqlc.arg('backoff_seconds')::float8),
+	updated_at = NOW()
+WHERE id = sqlc.arg('id');
+
+-- name: DeadLetterWebhookEvent :exec
+UPDATE webhook_events
+SET status = 'dead', last_error = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetWebhookEvent :one
+SELECT * FROM webhook_events
+WHERE id = $1;
+
+-- name: ListWebhookEvents :many
+SELECT * FROM webhook_events
+WHERE (sqlc.narg('source')::text IS NULL OR source = sqlc.narg('source'))
+AND (sqlc.narg('status')::text IS NULL OR status = sqlc.narg('status'))
+ORDER BY received_at DESC, id
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: ReplayWebhookEvent :one
+UPDATE webhook_events
+SET status = 'pending', attempts = 0, last_error = NULL, next_attempt_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND status IN ('failed', 'dead')
+RETURNING *;
@@ -0,0 +1,34 @@
+-- +goose Up
+CREATE TABLE audit_events(
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	action TEXT NOT NULL,
+	actor_id UUID,
+	target_type TEXT,
+	target_id TEXT,
+	ip TEXT NOT NULL,
+	request_id TEXT NOT NULL,
+	metadata JSONB NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX audit_events_created_at_idx ON audit_events (created_at);
+CREATE INDEX audit_events_actor_id_idx ON audit_events (actor_id);
+
+-- audit_events is append-only. actor_id deliberately has no foreign key so
+-- that deleting a user never needs to touch the log.
+-- +goose StatementBegin
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER audit_events_no_update_or_delete
+BEFORE UPDATE OR DELETE ON audit_events
+FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+-- +goose Down
+DROP TRIGGER audit_events_no_update_or_delete ON audit_events;
+DROP FUNCTION audit_events_append_only();
+DROP TABLE audit_events;
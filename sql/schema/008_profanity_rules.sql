@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE profanity_rules(
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	word TEXT UNIQUE NOT NULL,
+	mode TEXT NOT NULL DEFAULT 'censor'
+	CHECK (mode IN ('censor', 'reject', 'flag'))
+);
+
+INSERT INTO profanity_rules (id, created_at, updated_at, word, mode)
+VALUES
+	(gen_random_uuid(), NOW(), NOW(), 'kerfuffle', 'censor'),
+	(gen_random_uuid(), NOW(), NOW(), 'sharbert', 'censor'),
+	(gen_random_uuid(), NOW(), NOW(), 'fornax', 'censor');
+
+-- +goose Down
+DROP TABLE profanity_rules;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN hidden_at TIMESTAMP DEFAULT NULL;
+
+-- +goose Down
+ALTER TABLE chirps
+DROP COLUMN hidden_at;
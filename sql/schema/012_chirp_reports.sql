@@ -0,0 +1,33 @@
+-- +goose Up
+CREATE TABLE chirp_reports(
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	chirp_id UUID NOT NULL,
+	reporter_id UUID NOT NULL,
+	reason TEXT NOT NULL,
+	status TEXT NOT NULL DEFAULT 'open'
+	CHECK (status IN ('open', 'dismissed', 'actioned')),
+	resolution TEXT,
+	resolved_by UUID,
+	resolved_at TIMESTAMP,
+	CONSTRAINT fk_chirp_id
+	FOREIGN KEY (chirp_id)
+	REFERENCES chirps(id)
+	ON DELETE CASCADE,
+	CONSTRAINT fk_reporter_id
+	FOREIGN KEY (reporter_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE,
+	CONSTRAINT fk_resolved_by
+	FOREIGN KEY (resolved_by)
+	REFERENCES users(id)
+	ON DELETE SET NULL
+);
+
+-- a user can only have one open report against a chirp at a time
+CREATE UNIQUE INDEX chirp_reports_open_idx ON chirp_reports (chirp_id, reporter_id)
+WHERE status = 'open';
+
+-- +goose Down
+DROP TABLE chirp_reports;
@@ -0,0 +1,14 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN account_status TEXT NOT NULL DEFAULT 'active'
+CHECK (account_status IN ('active', 'suspended', 'banned', 'shadowbanned')),
+ADD COLUMN status_reason TEXT DEFAULT NULL;
+
+UPDATE users
+SET account_status = 'suspended'
+WHERE suspended_until > NOW();
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN status_reason,
+DROP COLUMN account_status;
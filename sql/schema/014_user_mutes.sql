@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE user_mutes(
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL,
+	phrase TEXT NOT NULL,
+	-- pattern is phrase as a case-insensitive POSIX regular expression that
+	-- only matches whole words, built by the server
+	pattern TEXT NOT NULL,
+	UNIQUE (user_id, phrase),
+	CONSTRAINT fk_user_id
+	FOREIGN KEY (user_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE user_mutes;
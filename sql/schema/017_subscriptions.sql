@@ -0,0 +1,24 @@
+-- +goose Up
+CREATE TABLE subscriptions(
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	user_id UUID UNIQUE NOT NULL,
+	plan TEXT NOT NULL,
+	status TEXT NOT NULL
+	CHECK (status IN ('active', 'past_due', 'canceled', 'expired', 'refunded')),
+	current_period_end TIMESTAMP NOT NULL,
+	canceled_at TIMESTAMP DEFAULT NULL,
+	CONSTRAINT fk_user_id
+	FOREIGN KEY (user_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE
+);
+
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan, status, current_period_end)
+SELECT gen_random_uuid(), NOW(), NOW(), id, 'chirpy_red', 'active', NOW() + INTERVAL '1 month'
+FROM users
+WHERE is_chirpy_red;
+
+-- +goose Down
+DROP TABLE subscriptions;
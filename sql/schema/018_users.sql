@@ -0,0 +1,14 @@
+-- +goose Up
+ALTER TABLE users
+DROP COLUMN is_chirpy_red;
+
+-- +goose Down
+ALTER TABLE users
+ADD COLUMN is_chirpy_red BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE users
+SET is_chirpy_red = TRUE
+WHERE id IN (
+	SELECT user_id FROM subscriptions
+	WHERE status IN ('active', 'past_due', 'canceled') AND current_period_end > NOW()
+);
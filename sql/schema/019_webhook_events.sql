@@ -0,0 +1,42 @@
+-- +goose Up
+CREATE TABLE webhook_events(
+	id UUID PRIMARY KEY,
+	source TEXT NOT NULL,
+	event_id TEXT NOT NULL,
+	event_type TEXT NOT NULL,
+	payload JSONB NOT NULL,
+	status TEXT NOT NULL DEFAULT 'pending'
+	CHECK (status IN ('pending', 'processing', 'failed', 'processed', 'dead')),
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT DEFAULT NULL,
+	received_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	next_attempt_at TIMESTAMP NOT NULL,
+	processed_at TIMESTAMP DEFAULT NULL,
+	UNIQUE (source, event_id)
+);
+
+CREATE INDEX webhook_events_due_idx ON webhook_events (next_attempt_at)
+WHERE status IN ('pending', 'processing', 'failed');
+
+INSERT INTO webhook_events (id, source, event_id, event_type, payload, status, attempts,
+	received_at, updated_at, next_attempt_at, processed_at)
+SELECT gen_random_uuid(), 'polka', event_id, event_type, '{}', 'processed', 1,
+	received_at, received_at, received_at, received_at
+FROM polka_events;
+
+DROP TABLE polka_events;
+
+-- +goose Down
+CREATE TABLE polka_events(
+	event_id TEXT PRIMARY KEY,
+	event_type TEXT NOT NULL,
+	received_at TIMESTAMP NOT NULL
+);
+
+INSERT INTO polka_events (event_id, event_type, received_at)
+SELECT event_id, event_type, received_at
+FROM webhook_events
+WHERE source = 'polka' AND status = 'processed';
+
+DROP TABLE webhook_events;
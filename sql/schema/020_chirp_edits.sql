@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE chirp_edits(
+	id UUID PRIMARY KEY,
+	chirp_id UUID NOT NULL,
+	body TEXT NOT NULL,
+	edited_at TIMESTAMP NOT NULL,
+	edited_by UUID,
+	CONSTRAINT fk_chirp_id
+	FOREIGN KEY (chirp_id)
+	REFERENCES chirps(id)
+	ON DELETE CASCADE,
+	CONSTRAINT fk_edited_by
+	FOREIGN KEY (edited_by)
+	REFERENCES users(id)
+	ON DELETE SET NULL
+);
+
+CREATE INDEX chirp_edits_chirp_id_idx ON chirp_edits (chirp_id, edited_at);
+
+-- +goose Down
+DROP TABLE chirp_edits;
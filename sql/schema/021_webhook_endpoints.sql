@@ -0,0 +1,45 @@
+-- +goose Up
+CREATE TABLE webhook_endpoints(
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	owner_id UUID NOT NULL,
+	url TEXT NOT NULL,
+	secret TEXT NOT NULL,
+	events TEXT[] NOT NULL,
+	all_users BOOLEAN NOT NULL DEFAULT FALSE,
+	CONSTRAINT fk_owner_id
+	FOREIGN KEY (owner_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE
+);
+
+CREATE TABLE webhook_deliveries(
+	id UUID PRIMARY KEY,
+	endpoint_id UUID NOT NULL,
+	event_id UUID NOT NULL,
+	event_type TEXT NOT NULL,
+	payload JSONB NOT NULL,
+	status TEXT NOT NULL DEFAULT 'pending'
+	CHECK (status IN ('pending', 'processing', 'failed', 'delivered', 'dead')),
+	attempts INTEGER NOT NULL DEFAULT 0,
+	response_status INTEGER DEFAULT NULL,
+	last_error TEXT DEFAULT NULL,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	next_attempt_at TIMESTAMP NOT NULL,
+	delivered_at TIMESTAMP DEFAULT NULL,
+	CONSTRAINT fk_endpoint_id
+	FOREIGN KEY (endpoint_id)
+	REFERENCES webhook_endpoints(id)
+	ON DELETE CASCADE
+);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at)
+WHERE status IN ('pending', 'processing', 'failed');
+
+CREATE INDEX webhook_deliveries_endpoint_id_idx ON webhook_deliveries (endpoint_id, created_at);
+
+-- +goose Down
+DROP TABLE webhook_deliveries;
+DROP TABLE webhook_endpoints;
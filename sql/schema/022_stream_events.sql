@@ -0,0 +1,5 @@
+-- +goose Up
+CREATE SEQUENCE stream_event_id_seq;
+
+-- +goose Down
+DROP SEQUENCE stream_event_id_seq;
@@ -0,0 +1,47 @@
+-- +goose Up
+CREATE TABLE notifications(
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL,
+	type TEXT NOT NULL,
+	event_id UUID NOT NULL,
+	event_type TEXT NOT NULL,
+	actor_id UUID,
+	chirp_id UUID,
+	data JSONB NOT NULL,
+	read_at TIMESTAMP DEFAULT NULL,
+	UNIQUE (user_id, event_id),
+	CONSTRAINT fk_user_id
+	FOREIGN KEY (user_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE,
+	CONSTRAINT fk_actor_id
+	FOREIGN KEY (actor_id)
+	REFERENCES users(id)
+	ON DELETE SET NULL,
+	CONSTRAINT fk_chirp_id
+	FOREIGN KEY (chirp_id)
+	REFERENCES chirps(id)
+	ON DELETE CASCADE
+);
+
+CREATE INDEX notifications_user_id_idx ON notifications (user_id, created_at DESC, id DESC);
+
+CREATE INDEX notifications_unread_idx ON notifications (user_id)
+WHERE read_at IS NULL;
+
+CREATE TABLE notification_preferences(
+	user_id UUID NOT NULL,
+	type TEXT NOT NULL,
+	enabled BOOLEAN NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (user_id, type),
+	CONSTRAINT fk_user_id
+	FOREIGN KEY (user_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE notification_preferences;
+DROP TABLE notifications;
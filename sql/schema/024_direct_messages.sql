@@ -0,0 +1,55 @@
+-- +goose Up
+CREATE TABLE conversations(
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	created_by UUID,
+	-- direct_key identifies one-to-one conversations by their two users, so
+	-- that each pair only ever has one.
+	direct_key TEXT UNIQUE,
+	CONSTRAINT fk_created_by
+	FOREIGN KEY (created_by)
+	REFERENCES users(id)
+	ON DELETE SET NULL
+);
+
+CREATE TABLE conversation_participants(
+	conversation_id UUID NOT NULL,
+	user_id UUID NOT NULL,
+	joined_at TIMESTAMP NOT NULL,
+	last_read_at TIMESTAMP DEFAULT NULL,
+	PRIMARY KEY (conversation_id, user_id),
+	CONSTRAINT fk_conversation_id
+	FOREIGN KEY (conversation_id)
+	REFERENCES conversations(id)
+	ON DELETE CASCADE,
+	CONSTRAINT fk_user_id
+	FOREIGN KEY (user_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE
+);
+
+CREATE INDEX conversation_participants_user_id_idx ON conversation_participants (user_id);
+
+CREATE TABLE messages(
+	id UUID PRIMARY KEY,
+	conversation_id UUID NOT NULL,
+	sender_id UUID,
+	created_at TIMESTAMP NOT NULL,
+	body TEXT NOT NULL,
+	CONSTRAINT fk_conversation_id
+	FOREIGN KEY (conversation_id)
+	REFERENCES conversations(id)
+	ON DELETE CASCADE,
+	CONSTRAINT fk_sender_id
+	FOREIGN KEY (sender_id)
+	REFERENCES users(id)
+	ON DELETE SET NULL
+);
+
+CREATE INDEX messages_conversation_id_idx ON messages (conversation_id, created_at DESC, id DESC);
+
+-- +goose Down
+DROP TABLE messages;
+DROP TABLE conversation_participants;
+DROP TABLE conversations;
@@ -0,0 +1,25 @@
+-- +goose Up
+CREATE TABLE scheduled_chirps(
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL,
+	body TEXT NOT NULL,
+	needs_review BOOLEAN NOT NULL DEFAULT FALSE,
+	publish_at TIMESTAMP NOT NULL,
+	status TEXT NOT NULL DEFAULT 'scheduled'
+	CHECK (status IN ('scheduled', 'failed')),
+	last_error TEXT DEFAULT NULL,
+	CONSTRAINT fk_user_id
+	FOREIGN KEY (user_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE
+);
+
+CREATE INDEX scheduled_chirps_due_idx ON scheduled_chirps (publish_at)
+WHERE status = 'scheduled';
+
+CREATE INDEX scheduled_chirps_user_id_idx ON scheduled_chirps (user_id, publish_at);
+
+-- +goose Down
+DROP TABLE scheduled_chirps;
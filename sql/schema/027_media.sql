@@ -0,0 +1,31 @@
+-- +goose Up
+CREATE TABLE media(
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL,
+	-- NULL until the upload is attached to a chirp.
+	chirp_id UUID,
+	position INTEGER NOT NULL DEFAULT 0,
+	key TEXT NOT NULL,
+	thumbnail_key TEXT NOT NULL,
+	content_type TEXT NOT NULL,
+	width INTEGER NOT NULL,
+	height INTEGER NOT NULL,
+	thumbnail_width INTEGER NOT NULL,
+	thumbnail_height INTEGER NOT NULL,
+	size_bytes INTEGER NOT NULL,
+	CONSTRAINT fk_user_id
+	FOREIGN KEY (user_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE,
+	CONSTRAINT fk_chirp_id
+	FOREIGN KEY (chirp_id)
+	REFERENCES chirps(id)
+	ON DELETE CASCADE
+);
+
+CREATE INDEX media_chirp_id_idx ON media (chirp_id, position) WHERE chirp_id IS NOT NULL;
+CREATE INDEX media_unattached_idx ON media (created_at) WHERE chirp_id IS NULL;
+
+-- +goose Down
+DROP TABLE media;
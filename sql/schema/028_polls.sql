@@ -0,0 +1,45 @@
+-- +goose Up
+CREATE TABLE polls(
+	chirp_id UUID PRIMARY KEY,
+	closes_at TIMESTAMP NOT NULL,
+	CONSTRAINT fk_chirp_id
+	FOREIGN KEY (chirp_id)
+	REFERENCES chirps(id)
+	ON DELETE CASCADE
+);
+
+CREATE TABLE poll_options(
+	chirp_id UUID NOT NULL,
+	position INTEGER NOT NULL,
+	text TEXT NOT NULL,
+	PRIMARY KEY (chirp_id, position),
+	CONSTRAINT fk_chirp_id
+	FOREIGN KEY (chirp_id)
+	REFERENCES polls(chirp_id)
+	ON DELETE CASCADE
+);
+
+CREATE TABLE poll_votes(
+	chirp_id UUID NOT NULL,
+	user_id UUID NOT NULL,
+	position INTEGER NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	-- One vote per user; voting again changes it.
+	PRIMARY KEY (chirp_id, user_id),
+	CONSTRAINT fk_option
+	FOREIGN KEY (chirp_id, position)
+	REFERENCES poll_options(chirp_id, position)
+	ON DELETE CASCADE,
+	CONSTRAINT fk_user_id
+	FOREIGN KEY (user_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE
+);
+
+CREATE INDEX poll_votes_option_idx ON poll_votes (chirp_id, position);
+
+-- +goose Down
+DROP TABLE poll_votes;
+DROP TABLE poll_options;
+DROP TABLE polls;
@@ -0,0 +1,37 @@
+-- +goose Up
+CREATE TABLE bookmarks(
+	user_id UUID NOT NULL,
+	chirp_id UUID NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (user_id, chirp_id),
+	CONSTRAINT fk_user_id
+	FOREIGN KEY (user_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE,
+	CONSTRAINT fk_chirp_id
+	FOREIGN KEY (chirp_id)
+	REFERENCES chirps(id)
+	ON DELETE CASCADE
+);
+
+CREATE INDEX bookmarks_user_id_idx ON bookmarks (user_id, created_at DESC);
+
+CREATE TABLE pinned_chirps(
+	chirp_id UUID PRIMARY KEY,
+	user_id UUID NOT NULL,
+	pinned_at TIMESTAMP NOT NULL,
+	CONSTRAINT fk_chirp_id
+	FOREIGN KEY (chirp_id)
+	REFERENCES chirps(id)
+	ON DELETE CASCADE,
+	CONSTRAINT fk_user_id
+	FOREIGN KEY (user_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE
+);
+
+CREATE INDEX pinned_chirps_user_id_idx ON pinned_chirps (user_id);
+
+-- +goose Down
+DROP TABLE pinned_chirps;
+DROP TABLE bookmarks;
@@ -0,0 +1,37 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN content_warning TEXT,
+ADD COLUMN sensitive BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE chirp_edits
+ADD COLUMN content_warning TEXT,
+ADD COLUMN sensitive BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE scheduled_chirps
+ADD COLUMN content_warning TEXT,
+ADD COLUMN sensitive BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE user_preferences(
+	user_id UUID PRIMARY KEY,
+	updated_at TIMESTAMP NOT NULL,
+	hide_sensitive BOOLEAN NOT NULL DEFAULT FALSE,
+	CONSTRAINT fk_user_id
+	FOREIGN KEY (user_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE user_preferences;
+
+ALTER TABLE scheduled_chirps
+DROP COLUMN sensitive,
+DROP COLUMN content_warning;
+
+ALTER TABLE chirp_edits
+DROP COLUMN sensitive,
+DROP COLUMN content_warning;
+
+ALTER TABLE chirps
+DROP COLUMN sensitive,
+DROP COLUMN content_warning;
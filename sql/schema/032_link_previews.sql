@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE link_previews(
+	url TEXT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	status TEXT NOT NULL CHECK (status IN ('pending', 'processing', 'ready', 'failed')),
+	title TEXT,
+	description TEXT,
+	image_url TEXT,
+	last_error TEXT,
+	fetched_at TIMESTAMP,
+	next_attempt_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX link_previews_due_idx ON link_previews (next_attempt_at)
+WHERE status IN ('pending', 'processing');
+
+-- +goose Down
+DROP TABLE link_previews;
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/lucoand/chirpy/internal/database"
+	"github.com/lucoand/chirpy/internal/events"
+	"github.com/lucoand/chirpy/internal/stream"
+)
+
+const (
+	streamChannel = "chirpy_stream"
+	// streamReplaySize is how many recent events are kept for clients
+	// resuming with Last-Event-ID.
+	streamReplaySize = 1000
+	// streamBufferSize is how far a client may fall behind before it is
+	// disconnected and has to resume.
+	streamBufferSize  = 64
+	streamHeartbeat   = 15 * time.Second
+	streamRetryMillis = 3000
+	streamResetEvent  = "stream.reset"
+	streamChirpPrefix = "chirp."
+)
+
+// streamPrivateEvents are forwarded to the stream too, but only reach the
+// user they are about, as realtime notifications.
+var streamPrivateEvents = map[string]bool{
+	events.NotificationCreated: true,
+}
+
+// streamNotification is the payload sent over Postgres NOTIFY. It is kept
+// small: each server loads the chirp itself, and only private events, whose
+// data is a few fields, carry it along.
+type streamNotification struct {
+	ID           int64           `json:"id"`
+	Type         string          `json:"type"`
+	ChirpID      uuid.NullUUID   `json:"chirp_id"`
+	UserID       uuid.UUID       `json:"user_id"`
+	AuthorStatus string          `json:"author_status"`
+	Data         json.RawMessage `json:"data"`
+}
+
+// notifyStream forwards chirp events and private events to every server's
+// stream through Postgres, which also assigns the event an ID that is the
+// same everywhere.
+func (cfg *apiConfig) notifyStream(ctx context.Context, e events.Event) {
+	params := database.NotifyStreamParams{
+		Type:   e.Type,
+		UserID: e.UserID,
+	}
+	var err error
+	switch data := e.Data.(type) {
+	case chirpJSON:
+		params.ChirpID = actor(data.ID)
+	case chirpDeletedJSON:
+		params.ChirpID = actor(data.ID)
+		// The author can't be looked up with the chirp once it is gone, nor
+		// can who the chirp was for.
+		params.AuthorStatus = sql.NullString{String: data.AuthorStatus, Valid: true}
+		params.Data, err = json.Marshal(streamDeletedChirp{
+			Visibility: data.Visibility,
+			Mentions:   data.Mentions,
+		})
+	default:
+		if !streamPrivateEvents[e.Type] {
+			return
+		}
+		params.Data, err = json.Marshal(e.Data)
+	}
+	if err != nil {
+		log.Printf("Error marshalling %s event %v for stream: %s", e.Type, e.ID, err)
+		return
+	}
+	err = cfg.dbQueries.NotifyStream(ctx, params)
+	if err != nil {
+		log.Printf("Error notifying stream of %s event %v: %s", e.Type, e.ID, err)
+	}
+}
+
+// runStreamListener listens for stream notifications from every server and
+// publishes them to this server's clients.
+func (cfg *apiConfig) runStreamListener(ctx context.Context, dbURL string) {
+	listener := pq.NewListener(dbURL, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Error in stream listener: %s", err)
+		}
+	})
+	defer listener.Close()
+	err := listener.Listen(streamChannel)
+	if err != nil {
+		log.Printf("Error listening for stream events: %s", err)
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-listener.Notify:
+			// A nil notification means the connection was re-established.
+			// Anything sent meanwhile is lost; clients resuming past the gap
+			// still get the events on either side of it.
+			if n == nil {
+				continue
+			}
+			cfg.publishStreamNotification(ctx, n.Extra)
+		}
+	}
+}
+
+// streamDeletedChirp is the data of a chirp.deleted notification: who the
+// chirp was for, which can't be looked up once it is gone.
+type streamDeletedChirp struct {
+	Visibility string      `json:"visibility"`
+	Mentions   []uuid.UUID `json:"mentions"`
+}
+
+// streamAudience decides who besides its author is sent an event about a
+// chirp. Streams are listings, so like GET /api/chirps they never carry
+// unlisted chirps to anyone else.
+func (cfg *apiConfig) streamAudience(ctx context.Context, authorID uuid.UUID, visibility string, mentions []uuid.UUID) (authorOnly bool, audience map[uuid.UUID]bool, err error) {
+	var followers []uuid.UUID
+	if visibility == visibilityFollowersOnly {
+		followers, err = cfg.dbQueries.GetFollowerIDs(ctx, authorID)
+		if err != nil {
+			return false, nil, err
+		}
+	}
+	authorOnly, audience = chirpAudience(visibility, followers, mentions)
+	return authorOnly, audience, nil
+}
+
+// chirpAudience is who may see a chirp besides its author: everyone, nobody
+// (authorOnly), or only the users in audience.
+func chirpAudience(visibility string, followers []uuid.UUID, mentions []uuid.UUID) (authorOnly bool, audience map[uuid.UUID]bool) {
+	switch visibility {
+	case visibilityPublic:
+		return false, nil
+	case visibilityFollowersOnly, visibilityMentionedOnly:
+		audience = map[uuid.UUID]bool{}
+		for _, id := range followers {
+			audience[id] = true
+		}
+		for _, id := range mentions {
+			audience[id] = true
+		}
+		return false, audience
+	}
+	return true, nil
+}
+
+func (cfg *apiConfig) publishStreamNotification(ctx context.Context, payload string) {
+	n := streamNotification{}
+	err := json.Unmarshal([]byte(payload), &n)
+	if err != nil {
+		log.Printf("Error decoding stream notification: %s", err)
+		return
+	}
+	e := stream.Event{
+		ID:       n.ID,
+		Type:     n.Type,
+		AuthorID: n.UserID,
+	}
+	switch n.Type {
+	case events.ChirpCreated:
+		chirp, err := cfg.dbQueries.GetChirpForStream(ctx, n.ChirpID.UUID)
+		if errors.Is(err, sql.ErrNoRows) {
+			// Deleted before we got to it; the deletion follows.
+			return
+		} else if err != nil {
+			log.Printf("Error retrieving chirp %v for stream: %s", n.ChirpID.UUID, err)
+			return
+		}
+		if chirp.HiddenAt.Valid {
+			return
+		}
+		e.Body = chirp.Body
+		e.Sensitive = chirp.Sensitive
+		resp := []chirpJSON{chirpToJSON(database.Chirp{
+			ID:             chirp.ID,
+			CreatedAt:      chirp.CreatedAt,
+			UpdatedAt:      chirp.UpdatedAt,
+			Body:           chirp.Body,
+			UserID:         chirp.UserID,
+			NeedsReview:    chirp.NeedsReview,
+			HiddenAt:       chirp.HiddenAt,
+			Visibility:     chirp.Visibility,
+			ContentWarning: chirp.ContentWarning,
+			Sensitive:      chirp.Sensitive,
+		})}
+		// Nobody has voted on a new chirp's poll, so there are no results
+		// to hide from anyone.
+		err = cfg.withAttachments(ctx, uuid.NullUUID{}, resp)
+		if err != nil {
+			log.Printf("Error retrieving attachments for chirp %v: %s", chirp.ID, err)
+			return
+		}
+		e.AuthorOnly, e.Audience, err = cfg.streamAudience(ctx, chirp.UserID, chirp.Visibility, resp[0].Mentions)
+		if err != nil {
+			log.Printf("Error retrieving audience of chirp %v: %s", chirp.ID, err)
+			return
+		}
+		e.AuthorOnly = e.AuthorOnly || chirp.AccountStatus == accountShadowbanned
+		e.Data, err = json.Marshal(resp[0])
+	case events.ChirpDeleted:
+		deleted := streamDeletedChirp{Visibility: visibilityPublic}
+		if len(n.Data) > 0 {
+			err = json.Unmarshal(n.Data, &deleted)
+			if err != nil {
+				log.Printf("Error decoding deleted chirp %v for stream: %s", n.ChirpID.UUID, err)
+				return
+			}
+		}
+		// Deleting a chirp must not reveal it to anyone who couldn't see it.
+		e.AuthorOnly, e.Audience, err = cfg.streamAudience(ctx, n.UserID, deleted.Visibility, deleted.Mentions)
+		if err != nil {
+			log.Printf("Error retrieving audience of chirp %v: %s", n.ChirpID.UUID, err)
+			return
+		}
+		e.AuthorOnly = e.AuthorOnly || n.AuthorStatus == accountShadowbanned
+		e.Data, err = json.Marshal(chirpDeletedJSON{
+			ID:         n.ChirpID.UUID,
+			UserID:     n.UserID,
+			Visibility: deleted.Visibility,
+		})
+	default:
+		if !streamPrivateEvents[n.Type] {
+			return
+		}
+		e.AuthorOnly = true
+		e.Data = n.Data
+	}
+	if err != nil {
+		log.Printf("Error marshalling stream event: %s", err)
+		return
+	}
+	cfg.stream.Publish(e)
+}
+
+// streamFilter builds the filter for a client. Blocks, mutes and preferences
+// are read when the client connects and apply until it reconnects.
+func (cfg *apiConfig) streamFilter(ctx context.Context, viewerID uuid.NullUUID, authorID uuid.NullUUID) (stream.Filter, error) {
+	blocked := map[uuid.UUID]bool{}
+	mutes := []*regexp.Regexp{}
+	hideSensitive := false
+	if viewerID.Valid {
+		blocks, err := cfg.dbQueries.GetUserBlocks(ctx, viewerID.UUID)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range blocks {
+			blocked[b.BlockedID] = true
+		}
+		userMutes, err := cfg.dbQueries.GetUserMutes(ctx, viewerID.UUID)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range userMutes {
+			// Mute patterns are written for Postgres's case-insensitive
+			// ~* operator, and only use syntax Go's regexp shares.
+			re, err := regexp.Compile("(?i)" + m.Pattern)
+			if err != nil {
+				log.Printf("Error compiling mute %v: %s", m.ID, err)
+				continue
+			}
+			mutes = append(mutes, re)
+		}
+		prefs, err := cfg.userPreferences(ctx, viewerID.UUID)
+		if err != nil {
+			return nil, err
+		}
+		hideSensitive = prefs.HideSensitive
+	}
+	return func(e stream.Event) bool {
+		// Private events go out as realtime notifications, never as chirps.
+		if !strings.HasPrefix(e.Type, streamChirpPrefix) {
+			return false
+		}
+		if authorID.Valid && e.AuthorID != authorID.UUID {
+			return false
+		}
+		isAuthor := viewerID.Valid && viewerID.UUID == e.AuthorID
+		if e.AuthorOnly && !isAuthor {
+			return false
+		}
+		if isAuthor {
+			return true
+		}
+		if e.Audience != nil && (!viewerID.Valid || !e.Audience[viewerID.UUID]) {
+			return false
+		}
+		if blocked[e.AuthorID] {
+			return false
+		}
+		if hideSensitive && e.Sensitive {
+			return false
+		}
+		for _, re := range mutes {
+			if re.MatchString(e.Body) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func writeStreamEvent(w http.ResponseWriter, e stream.Event) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+	return err
+}
+
+func (cfg *apiConfig) handleStream(w http.ResponseWriter, r *http.Request) {
+	viewerID, err := cfg.viewer(r)
+	if err != nil {
+		log.Printf("Error authenticating request: %s", err)
+		w.WriteHeader(401)
+		return
+	}
+	authorID := uuid.NullUUID{}
+	if s := r.URL.Query().Get("author_id"); s != "" {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			respondWithError(w, 400, "Invalid author_id")
+			return
+		}
+		authorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+	// Browsers send Last-Event-ID when they reconnect; the query parameter
+	// lets clients resume on their first connection too.
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	var lastID int64
+	resume := lastEventID != ""
+	if resume {
+		lastID, err = strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil {
+			respondWithError(w, 400, "Invalid Last-Event-ID")
+			return
+		}
+	}
+	filter, err := cfg.streamFilter(r.Context(), viewerID, authorID)
+	if err != nil {
+		log.Printf("Error loading stream filters: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	rc := http.NewResponseController(w)
+
+	sub, replay, complete := cfg.stream.Subscribe(filter, lastID, resume)
+	defer cfg.stream.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(200)
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetryMillis)
+	if !complete {
+		// Some events the client missed are no longer buffered, so it
+		// should reload with GET /api/chirps.
+		fmt.Fprintf(w, "event: %s\ndata: {}\n\n", streamResetEvent)
+	}
+	for _, e := range replay {
+		writeStreamEvent(w, e)
+	}
+	err = rc.Flush()
+	if err != nil {
+		log.Printf("Error flushing stream: %s", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sub.Dropped:
+			// The client fell behind. Closing the connection makes it
+			// reconnect and resume from the last event it received.
+			return
+		case e := <-sub.C:
+			err = writeStreamEvent(w, e)
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(w, ": heartbeat\n\n")
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
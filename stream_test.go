@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestChirpAudience(t *testing.T) {
+	follower := uuid.New()
+	mentioned := uuid.New()
+	tests := []struct {
+		visibility     string
+		wantAuthorOnly bool
+		want           []uuid.UUID
+	}{
+		{visibilityPublic, false, nil},
+		{visibilityUnlisted, true, nil},
+		{visibilityFollowersOnly, false, []uuid.UUID{follower, mentioned}},
+		{visibilityMentionedOnly, false, []uuid.UUID{mentioned}},
+	}
+	for _, tt := range tests {
+		var followers []uuid.UUID
+		if tt.visibility == visibilityFollowersOnly {
+			followers = []uuid.UUID{follower}
+		}
+		authorOnly, audience := chirpAudience(tt.visibility, followers, []uuid.UUID{mentioned})
+		if authorOnly != tt.wantAuthorOnly {
+			t.Errorf("TestChirpAudience %s: expected authorOnly to be %t, got %t", tt.visibility, tt.wantAuthorOnly, authorOnly)
+		}
+		if (audience == nil) != (tt.want == nil) || len(audience) != len(tt.want) {
+			t.Errorf("TestChirpAudience %s: expected audience %v, got %v", tt.visibility, tt.want, audience)
+			continue
+		}
+		for _, id := range tt.want {
+			if !audience[id] {
+				t.Errorf("TestChirpAudience %s: expected %v in audience", tt.visibility, id)
+			}
+		}
+	}
+}
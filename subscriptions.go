@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lucoand/chirpy/internal/database"
+	"github.com/lucoand/chirpy/internal/entitlements"
+	"github.com/lucoand/chirpy/internal/events"
+)
+
+// subscriptionExpiryInterval is how often lapsed subscriptions are marked as
+// expired. Access already ends at current_period_end regardless; the job
+// only keeps the status column honest.
+const subscriptionExpiryInterval = 10 * time.Minute
+
+const (
+	subscriptionActive   = "active"
+	subscriptionPastDue  = "past_due"
+	subscriptionCanceled = "canceled"
+	subscriptionExpired  = "expired"
+	subscriptionRefunded = "refunded"
+)
+
+const (
+	auditSubscriptionPrefix = "subscription."
+	auditSubscriptionExpire = "subscription.expired"
+)
+
+// entitlements returns what the user's current plan allows. Active, past
+// due and canceled subscriptions all keep their plan until the end of the
+// period that was paid for; anyone else is on the free plan.
+func (cfg *apiConfig) entitlements(ctx context.Context, userID uuid.UUID) (entitlements.Entitlements, error) {
+	sub, err := cfg.dbQueries.GetCurrentSubscription(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entitlements.ForPlan(entitlements.PlanFree), nil
+	} else if err != nil {
+		return entitlements.Entitlements{}, err
+	}
+	return entitlements.ForPlan(sub.Plan), nil
+}
+
+// nextPeriodEnd extends a subscription by a month from whichever is later:
+// now, or the end of the period already paid for.
+func nextPeriodEnd(ctx context.Context, q *database.Queries, userID uuid.UUID) (time.Time, error) {
+	start := time.Now().UTC()
+	sub, err := q.GetSubscriptionByUserID(ctx, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, err
+	}
+	if err == nil && sub.Status != subscriptionRefunded && sub.CurrentPeriodEnd.After(start) {
+		start = sub.CurrentPeriodEnd
+	}
+	return start.AddDate(0, 1, 0), nil
+}
+
+func (cfg *apiConfig) expireSubscriptions(ctx context.Context) {
+	expired, err := cfg.dbQueries.ExpireLapsedSubscriptions(ctx)
+	if err != nil {
+		log.Printf("Error expiring subscriptions: %s", err)
+		return
+	}
+	for _, sub := range expired {
+		log.Printf("Subscription for user %v expired", sub.UserID)
+		cfg.recordSystemAudit(ctx, auditEntry{
+			Action:     auditSubscriptionExpire,
+			TargetType: "user",
+			TargetID:   sub.UserID.String(),
+			Metadata:   map[string]interface{}{"plan": sub.Plan, "current_period_end": sub.CurrentPeriodEnd},
+		})
+	}
+}
+
+func (cfg *apiConfig) runSubscriptionExpiry(ctx context.Context) {
+	cfg.expireSubscriptions(ctx)
+	ticker := time.NewTicker(subscriptionExpiryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cfg.expireSubscriptions(ctx)
+		}
+	}
+}
+
+func (cfg *apiConfig) handleGetEntitlements(w http.ResponseWriter, r *http.Request, user database.User) {
+	ent, err := cfg.entitlements(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error checking entitlements for user %v: %s", user.ID, err)
+		w.WriteHeader(500)
+		return
+	}
+	type response struct {
+		Plan     string                     `json:"plan"`
+		Features []entitlements.Feature     `json:"features"`
+		Limits   map[entitlements.Limit]int `json:"limits"`
+	}
+	respondWithJSON(w, 200, response{
+		Plan:     ent.Plan,
+		Features: ent.Features(),
+		Limits:   ent.Limits(),
+	})
+}
+
+type subscriptionEventJSON struct {
+	UserID           uuid.UUID `json:"user_id"`
+	Plan             string    `json:"plan"`
+	Status           string    `json:"status"`
+	CurrentPeriodEnd time.Time `json:"current_period_end"`
+}
+
+func subscriptionEvent(eventType string, sub database.Subscription) events.Event {
+	return events.Event{
+		Type:   eventType,
+		UserID: sub.UserID,
+		Data: subscriptionEventJSON{
+			UserID:           sub.UserID,
+			Plan:             sub.Plan,
+			Status:           sub.Status,
+			CurrentPeriodEnd: sub.CurrentPeriodEnd,
+		},
+	}
+}
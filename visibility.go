@@ -0,0 +1,28 @@
+package main
+
+// Chirp visibility levels. Who can see a chirp is decided in the chirp
+// queries, so that pages of results are always full:
+//
+//   - public chirps are listed everywhere.
+//   - unlisted chirps can be seen by anyone with the link and on the author's
+//     profile, but aren't listed in GET /api/chirps or sent on streams.
+//   - followers_only chirps are for the author's followers and the users
+//     they mention.
+//   - mentioned_only chirps are only for the users they mention.
+//
+// A chirp the viewer can't see is reported as not found, rather than
+// forbidden, so that its existence isn't revealed.
+const (
+	visibilityPublic        = "public"
+	visibilityUnlisted      = "unlisted"
+	visibilityFollowersOnly = "followers_only"
+	visibilityMentionedOnly = "mentioned_only"
+)
+
+func validVisibility(v string) bool {
+	switch v {
+	case visibilityPublic, visibilityUnlisted, visibilityFollowersOnly, visibilityMentionedOnly:
+		return true
+	}
+	return false
+}
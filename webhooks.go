@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lucoand/chirpy/internal/database"
+	"github.com/lucoand/chirpy/internal/events"
+)
+
+const webhookSourcePolka = "polka"
+
+const (
+	webhookPending    = "pending"
+	webhookProcessing = "processing"
+	webhookFailed     = "failed"
+	webhookProcessed  = "processed"
+	webhookDead       = "dead"
+)
+
+const (
+	webhookBatchSize    = 10
+	webhookPollInterval = 5 * time.Second
+	// webhookMaxAttempts is how many times an event is tried before it is
+	// moved to the dead-letter state for an admin to look at.
+	webhookMaxAttempts = 8
+	webhookBaseBackoff = 30 * time.Second
+	webhookMaxBackoff  = time.Hour
+)
+
+const auditWebhookReplay = "admin.webhook_replayed"
+
+// errWebhookPermanent marks failures that retrying won't fix, such as an
+// event for a user that doesn't exist. Those events go straight to the
+// dead-letter state.
+var errWebhookPermanent = errors.New("permanent webhook failure")
+
+// webhookBackoff returns how long to wait before retrying an event that has
+// failed attempts times: 30s, 1m, 2m and so on, up to an hour.
+func webhookBackoff(attempts int32) time.Duration {
+	backoff := webhookBaseBackoff
+	for i := int32(1); i < attempts && backoff < webhookMaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, webhookMaxBackoff)
+}
+
+// wakeWebhookWorker asks the worker to look for new events now instead of
+// waiting for its next poll.
+func (cfg *apiConfig) wakeWebhookWorker() {
+	select {
+	case cfg.webhookWake <- struct{}{}:
+	default:
+	}
+}
+
+func (cfg *apiConfig) runWebhookWorker(ctx context.Context) {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+	for {
+		cfg.processWebhookEvents(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-cfg.webhookWake:
+		}
+	}
+}
+
+// processWebhookEvents works through every event that is due. Events are
+// claimed with SKIP LOCKED and a five minute lease, so several servers can
+// share the inbox, and an event claimed by a server that died is picked up
+// again once its lease runs out.
+func (cfg *apiConfig) processWebhookEvents(ctx context.Context) {
+	for {
+		claimed, err := cfg.dbQueries.ClaimWebhookEvents(ctx, webhookBatchSize)
+		if err != nil {
+			log.Printf("Error claiming webhook events: %s", err)
+			return
+		}
+		for _, event := range claimed {
+			cfg.processWebhookEvent(ctx, event)
+		}
+		if len(claimed) < webhookBatchSize {
+			return
+		}
+	}
+}
+
+func (cfg *apiConfig) processWebhookEvent(ctx context.Context, event database.WebhookEvent) {
+	effects, err := cfg.applyWebhookEvent(ctx, event)
+	if err == nil {
+		if effects.audit != nil {
+			cfg.recordSystemAudit(ctx, *effects.audit)
+		}
+		for _, e := range effects.events {
+			cfg.events.Publish(ctx, e)
+		}
+		return
+	}
+	log.Printf("Error processing %s event %s (attempt %d): %s", event.Source, event.EventID, event.Attempts, err)
+	lastError := sql.NullString{String: err.Error(), Valid: true}
+	if errors.Is(err, errWebhookPermanent) || event.Attempts >= webhookMaxAttempts {
+		err = cfg.dbQueries.DeadLetterWebhookEvent(ctx, database.DeadLetterWebhookEventParams{
+			ID:        event.ID,
+			LastError: lastError,
+		})
+	} else {
+		err = cfg.dbQueries.RetryWebhookEvent(ctx, database.RetryWebhookEventParams{
+			LastError:      lastError,
+			BackoffSeconds: webhookBackoff(event.Attempts).Seconds(),
+			ID:             event.ID,
+		})
+	}
+	if err != nil {
+		log.Printf("Error recording failure of %s event %s: %s", event.Source, event.EventID, err)
+	}
+}
+
+// webhookEffects are what processing an event leads to once its transaction
+// has committed: an audit entry and domain events to publish.
+type webhookEffects struct {
+	audit  *auditEntry
+	events []events.Event
+}
+
+// applyWebhookEvent applies an event and marks it processed in a single
+// transaction, so an event's effects are applied exactly once even if it is
+// delivered or claimed more than once.
+func (cfg *apiConfig) applyWebhookEvent(ctx context.Context, event database.WebhookEvent) (webhookEffects, error) {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return webhookEffects{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+	// Marking the event first locks it. If another worker claimed it after
+	// our lease ran out, the attempt count no longer matches and we leave
+	// the event to them.
+	marked, err := qtx.MarkWebhookEventProcessed(ctx, database.MarkWebhookEventProcessedParams{
+		ID:       event.ID,
+		Attempts: event.Attempts,
+	})
+	if err != nil {
+		return webhookEffects{}, err
+	}
+	if marked == 0 {
+		log.Printf("Skipping %s event %s, it was claimed again", event.Source, event.EventID)
+		return webhookEffects{}, nil
+	}
+	var effects webhookEffects
+	switch event.Source {
+	case webhookSourcePolka:
+		effects, err = processPolkaWebhook(ctx, qtx, event)
+	default:
+		err = fmt.Errorf("%w: unknown source %q", errWebhookPermanent, event.Source)
+	}
+	if err != nil {
+		return webhookEffects{}, err
+	}
+	return effects, tx.Commit()
+}
+
+type webhookEventJSON struct {
+	ID            uuid.UUID       `json:"id"`
+	Source        string          `json:"source"`
+	EventID       string          `json:"event_id"`
+	EventType     string          `json:"event_type"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Attempts      int32           `json:"attempts"`
+	LastError     *string         `json:"last_error"`
+	ReceivedAt    time.Time       `json:"received_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+	NextAttemptAt *time.Time      `json:"next_attempt_at"`
+	ProcessedAt   *time.Time      `json:"processed_at"`
+}
+
+func webhookEventToJSON(e database.WebhookEvent) webhookEventJSON {
+	j := webhookEventJSON{
+		ID:         e.ID,
+		Source:     e.Source,
+		EventID:    e.EventID,
+		EventType:  e.EventType,
+		Payload:    e.Payload,
+		Status:     e.Status,
+		Attempts:   e.Attempts,
+		ReceivedAt: e.ReceivedAt,
+		UpdatedAt:  e.UpdatedAt,
+	}
+	if e.LastError.Valid {
+		j.LastError = &e.LastError.String
+	}
+	if e.Status != webhookProcessed && e.Status != webhookDead {
+		j.NextAttemptAt = &e.NextAttemptAt
+	}
+	if e.ProcessedAt.Valid {
+		j.ProcessedAt = &e.ProcessedAt.Time
+	}
+	return j
+}
+
+func isValidWebhookStatus(status string) bool {
+	switch status {
+	case webhookPending, webhookProcessing, webhookFailed, webhookProcessed, webhookDead:
+		return true
+	}
+	return false
+}
+
+func (cfg *apiConfig) handleListWebhookEvents(w http.ResponseWriter, r *http.Request, admin database.User) {
+	q := r.URL.Query()
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	status := q.Get("status")
+	if status != "" && !isValidWebhookStatus(status) {
+		respondWithError(w, 400, "Invalid status")
+		return
+	}
+	inbox, err := cfg.dbQueries.ListWebhookEvents(r.Context(), database.ListWebhookEventsParams{
+		Source: sql.NullString{String: q.Get("source"), Valid: q.Get("source") != ""},
+		Status: sql.NullString{String: status, Valid: status != ""},
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		log.Printf("Error retrieving webhook events: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	resp := make([]webhookEventJSON, len(inbox))
+	for i, e := range inbox {
+		resp[i] = webhookEventToJSON(e)
+	}
+	respondWithJSON(w, 200, resp)
+}
+
+func (cfg *apiConfig) handleGetWebhookEvent(w http.ResponseWriter, r *http.Request, admin database.User) {
+	id, err := uuid.Parse(r.PathValue("event_id"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid event ID")
+		return
+	}
+	event, err := cfg.dbQueries.GetWebhookEvent(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Webhook event not found")
+		return
+	} else if err != nil {
+		log.Printf("Error retrieving webhook event %v: %s", id, err)
+		w.WriteHeader(500)
+		return
+	}
+	respondWithJSON(w, 200, webhookEventToJSON(event))
+}
+
+func (cfg *apiConfig) handleReplayWebhookEvent(w http.ResponseWriter, r *http.Request, admin database.User) {
+	id, err := uuid.Parse(r.PathValue("event_id"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid event ID")
+		return
+	}
+	event, err := cfg.dbQueries.ReplayWebhookEvent(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		_, err = cfg.dbQueries.GetWebhookEvent(r.Context(), id)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, 404, "Webhook event not found")
+		} else if err != nil {
+			log.Printf("Error retrieving webhook event %v: %s", id, err)
+			w.WriteHeader(500)
+		} else {
+			respondWithError(w, 409, "Only failed and dead events can be replayed")
+		}
+		return
+	} else if err != nil {
+		log.Printf("Error replaying webhook event %v: %s", id, err)
+		w.WriteHeader(500)
+		return
+	}
+	cfg.recordAudit(r, auditEntry{
+		Action:     auditWebhookReplay,
+		ActorID:    actor(admin.ID),
+		TargetType: "webhook_event",
+		TargetID:   event.ID.String(),
+		Metadata:   map[string]interface{}{"source": event.Source, "event_id": event.EventID},
+	})
+	cfg.wakeWebhookWorker()
+	respondWithJSON(w, 200, webhookEventToJSON(event))
+}